@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// CoveragePayload asks the mosaic service how well the tiles of IP cover the
+// colors of Original, cut into cells as a mosaic of the same tile size,
+// shape and edge policy would be.
+type CoveragePayload struct {
+	IP         string `json:"ip"`
+	Original   string `json:"original"`
+	TileWidth  int    `json:"tile_width"`
+	TileHeight int    `json:"tile_height,omitempty"`
+	Shape      string `json:"shape,omitempty"`
+	Edge       string `json:"edge,omitempty"`
+	Index      string `json:"index,omitempty"`
+
+	ExcludeVariants bool `json:"exclude_variants,omitempty"`
+}
+
+func (app *App) coverageHandler(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Original   string `json:"original"`
+		TileWidth  int    `json:"tile_width"`
+		TileHeight int    `json:"tile_height,omitempty"`
+		Shape      string `json:"shape,omitempty"`
+		Edge       string `json:"edge,omitempty"`
+		Index      string `json:"index,omitempty"`
+		TileSet    string `json:"tile_set,omitempty"`
+
+		ExcludeVariants bool `json:"exclude_variants,omitempty"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	err := dec.Decode(&payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = dec.Decode(&struct{}{})
+	if err != io.EOF {
+		app.badRequestResponse(w, r, errors.New("body must only contain a single JSON value"))
+		return
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.TileWidth <= 0 || payload.TileHeight < 0 {
+		app.badRequestResponse(w, r, errors.New("invalid tile size"))
+		return
+	}
+
+	set, err := tileSet(payload.TileSet, host)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	report, err := app.coverageRequest(CoveragePayload{
+		IP:         set,
+		Original:   payload.Original,
+		TileWidth:  payload.TileWidth,
+		TileHeight: payload.TileHeight,
+		Shape:      payload.Shape,
+		Edge:       payload.Edge,
+		Index:      payload.Index,
+
+		ExcludeVariants: payload.ExcludeVariants,
+	})
+	if err != nil {
+		app.serviceErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"coverage": report}, nil)
+	if err != nil {
+		app.logger.PrintError(err, nil)
+	}
+}
+
+// coverageRequest posts cp to the mosaic service and returns its coverage
+// report as is. Errors of the mosaic service are returned as *serviceError.
+func (app *App) coverageRequest(cp CoveragePayload) (json.RawMessage, error) {
+	jsonData, err := json.Marshal(&cp)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, app.service("coverage"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+
+	res, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, readServiceError("mosaic service", res)
+	}
+
+	var coverageServiceResponse struct {
+		Coverage json.RawMessage `json:"coverage,omitempty"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&coverageServiceResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	return coverageServiceResponse.Coverage, nil
+}
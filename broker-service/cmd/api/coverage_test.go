@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test_coverageRequest returns the coverage report of a fake mosaic service
+// as is, and the errors it answers with.
+func Test_coverageRequest(t *testing.T) {
+	mosaicService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("answer") {
+		case "missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":true,"message":"tile set does not exist"}`))
+		default:
+			w.Write([]byte(`{"coverage":{"cells":4}}`))
+		}
+	}))
+	defer mosaicService.Close()
+
+	app := &App{services: map[string]string{"coverage": mosaicService.URL}}
+	report, err := app.coverageRequest(CoveragePayload{IP: "172.18.0.1", TileWidth: 10})
+	if err != nil || string(report) != `{"cells":4}` {
+		t.Errorf("expected the report as is, got %s, %v", report, err)
+	}
+
+	app.services["coverage"] = mosaicService.URL + "?answer=missing"
+	_, err = app.coverageRequest(CoveragePayload{IP: "172.18.0.1", TileWidth: 10})
+	var se *serviceError
+	if !errors.As(err, &se) || se.status != http.StatusNotFound || se.message != "tile set does not exist" {
+		t.Errorf("expected the missing tile set answered with 404, got %v", err)
+	}
+}
@@ -39,3 +39,68 @@ func (app *App) downloadRandomNRequest(host string, n int) error {
 
 	return nil
 }
+
+// AcquirePayload asks the downloader for tiles of the colors of Original the
+// tile set of IP has no tile within Threshold of, downloading at most Budget
+// pictures. The downloader picks its own threshold when it is 0.
+type AcquirePayload struct {
+	IP         string  `json:"ip"`
+	Original   string  `json:"original"`
+	TileWidth  int     `json:"tile_width"`
+	TileHeight int     `json:"tile_height,omitempty"`
+	Threshold  float64 `json:"threshold,omitempty"`
+	Budget     int     `json:"budget"`
+}
+
+// acquisition is the report of the downloader on the pictures it downloaded
+// to fill the gaps of a tile set.
+type acquisition struct {
+	Gaps       int `json:"gaps"`
+	Downloaded int `json:"downloaded"`
+	Stored     int `json:"stored"`
+	Remaining  []struct {
+		Color [3]float64 `json:"color"`
+		Cells int        `json:"cells"`
+	} `json:"remaining"`
+}
+
+// acquireRequest has the downloader fill the gaps the tile set of ap.IP
+// leaves in the colors of the original. Errors of the downloader are
+// returned as *serviceError.
+func (app *App) acquireRequest(ap AcquirePayload) (*acquisition, error) {
+	jsonData, err := json.Marshal(&ap)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, app.service("acquire"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	client := http.Client{}
+
+	res, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, readServiceError("downloader", res)
+	}
+
+	var downloaderResponse struct {
+		Acquisition acquisition `json:"acquisition"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&downloaderResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	return &downloaderResponse.Acquisition, nil
+}
@@ -4,17 +4,46 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"image"
 	"image/png"
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 )
 
 func (app *App) mosaicHandler(w http.ResponseWriter, r *http.Request) {
 	var payload struct {
-		Original  string `json:"original"`
-		TileWidth int    `json:"tile_width,omitempty"`
+		Original    string  `json:"original"`
+		TileWidth   int     `json:"tile_width,omitempty"`
+		TileHeight  int     `json:"tile_height,omitempty"`
+		Shape       string  `json:"shape,omitempty"`
+		Edge        string  `json:"edge,omitempty"`
+		OutputScale float64 `json:"output_scale,omitempty"`
+		OutputWidth int     `json:"output_width,omitempty"`
+		Resample    string  `json:"resample,omitempty"`
+		Background  string  `json:"background,omitempty"`
+		Index       string  `json:"index,omitempty"`
+		Output      string  `json:"output,omitempty"`
+		Placements  bool    `json:"placements,omitempty"`
+		Metrics     bool    `json:"metrics,omitempty"`
+
+		// TileSet is the tile set the mosaic is rendered from and downloaded
+		// tiles are stored in, the one of the address of the client when
+		// unset.
+		TileSet string `json:"tile_set,omitempty"`
+
+		// ExcludeVariants renders the mosaic from the tiles downloaded only,
+		// leaving out their synthetic variants.
+		ExcludeVariants bool `json:"exclude_variants,omitempty"`
+
+		// MatchThreshold is how close a tile must be to the colors of the
+		// original for no more to be downloaded, DownloadBudget how many
+		// pictures may be downloaded trying, by default as many as there
+		// are cells.
+		MatchThreshold float64 `json:"match_threshold,omitempty"`
+		DownloadBudget *int    `json:"download_budget,omitempty"`
 	}
 
 	dec := json.NewDecoder(r.Body)
@@ -34,6 +63,11 @@ func (app *App) mosaicHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		//TODO: use json helper for errors...along with jsonResponse struct{}
 	}
+	set, err := tileSet(payload.TileSet, host)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
 
 	originalImg, err := base64StringToImage(payload.Original)
 	if err != nil {
@@ -41,20 +75,79 @@ func (app *App) mosaicHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//NOTE: approximate
-	dx := originalImg.Bounds().Dx() / payload.TileWidth
-	dy := originalImg.Bounds().Dy() / payload.TileWidth
-	tilesNeeded := dx * dy
+	if payload.TileWidth <= 0 || payload.TileHeight < 0 {
+		app.badRequestResponse(w, r, errors.New("invalid tile size"))
+		return
+	}
+
+	if payload.TileHeight == 0 {
+		payload.TileHeight = payload.TileWidth
+	}
+
+	if payload.MatchThreshold < 0 || payload.DownloadBudget != nil && *payload.DownloadBudget < 0 {
+		app.badRequestResponse(w, r, errors.New("invalid match threshold or download budget"))
+		return
+	}
+
+	mp := MosaicPayload{
+		IP:          set,
+		Original:    payload.Original,
+		TileWidth:   payload.TileWidth,
+		TileHeight:  payload.TileHeight,
+		Shape:       payload.Shape,
+		Edge:        payload.Edge,
+		OutputScale: payload.OutputScale,
+		OutputWidth: payload.OutputWidth,
+		Resample:    payload.Resample,
+		Background:  payload.Background,
+		Index:       payload.Index,
+		Output:      payload.Output,
+		Placements:  payload.Placements,
+		Metrics:     payload.Metrics,
+
+		ExcludeVariants: payload.ExcludeVariants,
+	}
+
+	var budget int
+	if payload.DownloadBudget != nil {
+		budget = *payload.DownloadBudget
+	} else if budget, err = app.tilesNeeded(originalImg.Bounds().Size(), mp); err != nil {
+		app.serviceErrorResponse(w, r, err)
+		return
+	}
 
-	err = app.downloadRandomNRequest(host, tilesNeeded)
+	acquired, err := app.acquireRequest(AcquirePayload{
+		IP:         set,
+		Original:   payload.Original,
+		TileWidth:  payload.TileWidth,
+		TileHeight: payload.TileHeight,
+		Threshold:  payload.MatchThreshold,
+		Budget:     budget,
+	})
 	if err != nil {
-		app.logger.PrintError(err, nil)
+		app.serviceErrorResponse(w, r, err)
+		return
+	}
+
+	app.logger.PrintInfo("Tiles acquired", map[string]string{
+		"ip":         set,
+		"gaps":       strconv.Itoa(acquired.Gaps),
+		"downloaded": strconv.Itoa(acquired.Downloaded),
+		"stored":     strconv.Itoa(acquired.Stored),
+		"remaining":  strconv.Itoa(len(acquired.Remaining)),
+	})
+
+	if mp.Output == "png" {
+		if err = app.streamMosaicRequest(w, mp); err != nil {
+			app.logger.PrintError(err, nil)
+			app.errorResponse(w, r, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 
-	mosaicStr, err := app.randomTilesMosaicCreateRequest(host, payload.Original, payload.TileWidth)
+	mosaic, err := app.randomTilesMosaicCreateRequest(mp)
 	if err != nil {
 		app.logger.PrintError(err, nil)
 		//TODO: specialize error handling
@@ -63,7 +156,20 @@ func (app *App) mosaicHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"mosaic": mosaicStr}, nil)
+	env := envelope{}
+	if mosaic.Pyramid != nil {
+		env["pyramid"] = mosaic.Pyramid
+	} else {
+		env["mosaic"] = mosaic.Mosaic
+	}
+	if mosaic.Placements != nil {
+		env["placements"] = mosaic.Placements
+	}
+	if mosaic.Metrics != nil {
+		env["metrics"] = mosaic.Metrics
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
 	if err != nil {
 		app.logger.PrintError(err, nil)
 		return
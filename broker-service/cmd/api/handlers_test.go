@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ChrisShia/jsonlog"
+)
+
+// Test_mosaicHandlerAcquireErrors answers the client when the downloader
+// fails to acquire tiles, instead of leaving it an empty response.
+func Test_mosaicHandlerAcquireErrors(t *testing.T) {
+	var original bytes.Buffer
+	if err := png.Encode(&original, image.NewNRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	body := fmt.Sprintf(`{"original":%q,"tile_width":2,"download_budget":1}`, base64.StdEncoding.EncodeToString(original.Bytes()))
+
+	var tt = []struct {
+		name     string
+		status   int
+		expected int
+	}{
+		{"refused", http.StatusBadRequest, http.StatusBadRequest},
+		{"failed", http.StatusInternalServerError, http.StatusBadGateway},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			downloader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "no tiles", tc.status)
+			}))
+			defer downloader.Close()
+
+			app := &App{
+				logger:   jsonlog.New(io.Discard, jsonlog.LevelInfo),
+				services: map[string]string{"acquire": downloader.URL},
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/mosaic", strings.NewReader(body))
+			app.mosaicHandler(w, r)
+
+			if w.Code != tc.expected || !strings.Contains(w.Body.String(), "no tiles") {
+				t.Errorf("expected %d with the message of the downloader, got %d %s", tc.expected, w.Code, w.Body)
+			}
+		})
+	}
+}
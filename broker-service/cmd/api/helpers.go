@@ -2,7 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
 )
 
 type envelope map[string]interface{}
@@ -39,3 +43,63 @@ func (app *App) errorResponse(w http.ResponseWriter, r *http.Request, status int
 		w.WriteHeader(500)
 	}
 }
+
+// serviceError is the error another service answered a request with.
+type serviceError struct {
+	service string
+	status  int
+	message any
+}
+
+func (e *serviceError) Error() string {
+	return fmt.Sprintf("%s: %v", e.service, e.message)
+}
+
+// readServiceError reads the error service answered res with. Its message is
+// the one of a JSON error envelope, as the mosaic service answers, or else
+// the body as is, as the downloader answers.
+func readServiceError(service string, res *http.Response) error {
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	var env struct {
+		Message any `json:"message"`
+	}
+	var message any = strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &env) == nil && env.Message != nil {
+		message = env.Message
+	}
+	if message == "" {
+		message = http.StatusText(res.StatusCode)
+	}
+
+	return &serviceError{service: service, status: res.StatusCode, message: message}
+}
+
+// serviceErrorResponse responds with the error met requesting another
+// service. Requests the service refused are answered with its message, 404
+// when it found nothing and 400 otherwise, a service unavailable with 503 and
+// other failures of the service with 502. Errors of the broker itself are
+// answered with 500.
+func (app *App) serviceErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var se *serviceError
+	if !errors.As(err, &se) {
+		app.logger.PrintError(err, nil)
+		app.errorResponse(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	switch {
+	case se.status == http.StatusNotFound:
+		app.errorResponse(w, r, http.StatusNotFound, se.message)
+	case se.status >= 400 && se.status < 500:
+		app.errorResponse(w, r, http.StatusBadRequest, se.message)
+	case se.status == http.StatusServiceUnavailable:
+		app.errorResponse(w, r, http.StatusServiceUnavailable, se.Error())
+	default:
+		app.logger.PrintError(err, nil)
+		app.errorResponse(w, r, http.StatusBadGateway, se.Error())
+	}
+}
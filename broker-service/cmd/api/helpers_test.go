@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ChrisShia/jsonlog"
+)
+
+// Test_serviceErrorResponse answers the errors of other services with the
+// status the client can act on, and their message.
+func Test_serviceErrorResponse(t *testing.T) {
+	app := &App{logger: jsonlog.New(io.Discard, jsonlog.LevelInfo)}
+
+	var tt = []struct {
+		name     string
+		status   int
+		body     string
+		expected int
+		message  string
+	}{
+		{"mosaic service refusal", http.StatusBadRequest, `{"error":true,"message":"unknown cell shape"}`, http.StatusBadRequest, "unknown cell shape"},
+		{"downloader refusal", http.StatusBadRequest, "invalid tile size, threshold or budget\n", http.StatusBadRequest, "invalid tile size, threshold or budget"},
+		{"unprocessable", http.StatusUnprocessableEntity, `{"error":true,"message":"too large"}`, http.StatusBadRequest, "too large"},
+		{"no tile set", http.StatusNotFound, `{"error":true,"message":"tile set does not exist"}`, http.StatusNotFound, "tile set does not exist"},
+		{"index not ready", http.StatusServiceUnavailable, `{"error":true,"message":"tile set index is still indexing"}`, http.StatusServiceUnavailable, "service: tile set index is still indexing"},
+		{"failure", http.StatusInternalServerError, "", http.StatusBadGateway, "service: Internal Server Error"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := readServiceError("service", &http.Response{StatusCode: tc.status, Body: io.NopCloser(strings.NewReader(tc.body))})
+
+			w := httptest.NewRecorder()
+			app.serviceErrorResponse(w, httptest.NewRequest(http.MethodPost, "/mosaic", nil), err)
+
+			var env struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&env); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tc.expected || env.Error != tc.message {
+				t.Errorf("expected %d %q, got %d %q", tc.expected, tc.message, w.Code, env.Error)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	app.serviceErrorResponse(w, httptest.NewRequest(http.MethodPost, "/mosaic", nil), errors.New("unreachable"))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected errors of the broker answered with 500, got %d", w.Code)
+	}
+}
@@ -55,7 +55,11 @@ func main() {
 func services() map[string]string {
 	srv := make(map[string]string)
 	srv["mosaic"] = "http://mosaic-service/create"
+	srv["pyramids"] = "http://mosaic-service"
+	srv["coverage"] = "http://mosaic-service/coverage"
+	srv["cells"] = "http://mosaic-service/cells"
 	srv["downloader"] = "http://downloader-service/pic.sum/random/download"
+	srv["acquire"] = "http://downloader-service/pic.sum/targeted/download"
 	return srv
 }
 
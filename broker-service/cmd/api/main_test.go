@@ -28,7 +28,7 @@ func Test_randomTilesMosaicCreateRequest(t *testing.T) {
 
 	originalStr := base64.StdEncoding.EncodeToString(buf.Bytes())
 
-	mosaicImgStr, err := mockApp.randomTilesMosaicCreateRequest("127.0.0.1", originalStr, 20)
+	mosaic, err := mockApp.randomTilesMosaicCreateRequest(MosaicPayload{IP: "127.0.0.1", Original: originalStr, TileWidth: 20})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -40,7 +40,7 @@ func Test_randomTilesMosaicCreateRequest(t *testing.T) {
 	}
 	defer resFile.Close()
 
-	resImage, err := base64StringToImage(*mosaicImgStr)
+	resImage, err := base64StringToImage(mosaic.Mosaic)
 	if err != nil {
 		t.Fatal(err)
 		return
@@ -3,22 +3,85 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 )
 
 type MosaicPayload struct {
-	IP        string `json:"ip"`
-	Original  string `json:"original,omitempty"`
-	TileWidth int    `json:"tile_width,omitempty"`
+	IP          string  `json:"ip"`
+	Original    string  `json:"original,omitempty"`
+	TileWidth   int     `json:"tile_width,omitempty"`
+	TileHeight  int     `json:"tile_height,omitempty"`
+	Shape       string  `json:"shape,omitempty"`
+	Edge        string  `json:"edge,omitempty"`
+	OutputScale float64 `json:"output_scale,omitempty"`
+	OutputWidth int     `json:"output_width,omitempty"`
+	Resample    string  `json:"resample,omitempty"`
+	Background  string  `json:"background,omitempty"`
+	Index       string  `json:"index,omitempty"`
+	Output      string  `json:"output,omitempty"`
+	Placements  bool    `json:"placements,omitempty"`
+	Metrics     bool    `json:"metrics,omitempty"`
+
+	ExcludeVariants bool `json:"exclude_variants,omitempty"`
+}
+
+// mosaicResult is the mosaic rendered by the mosaic service, or the pyramid
+// it was written to. Placements maps its areas to the tiles drawn there and
+// Metrics measures how closely it reproduces the original, when they were
+// requested.
+type mosaicResult struct {
+	Mosaic     string
+	Pyramid    json.RawMessage
+	Placements json.RawMessage
+	Metrics    json.RawMessage
+}
+
+func (app *App) randomTilesMosaicCreateRequest(mp MosaicPayload) (*mosaicResult, error) {
+	res, err := app.postMosaic(mp)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	return app.decodeMosaicResponse(res)
 }
 
-func (app *App) randomTilesMosaicCreateRequest(ip string, original string, tileWidth int) (*string, error) {
-	var mp = MosaicPayload{
-		IP:        ip,
-		Original:  original,
-		TileWidth: tileWidth,
+// streamMosaicRequest relays the PNG the mosaic service streams for mp to w
+// as it comes, mosaics requested with the "png" output are never held whole.
+func (app *App) streamMosaicRequest(w http.ResponseWriter, mp MosaicPayload) error {
+	res, err := app.postMosaic(mp)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.Header.Get("Content-Type") != "image/png" {
+		_, err = app.decodeMosaicResponse(res)
+		if err == nil {
+			err = errors.New("mosaic service: expected a png")
+		}
+		return err
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err = io.Copy(w, res.Body); err != nil {
+		app.logger.PrintError(err, nil)
+		panic(http.ErrAbortHandler)
 	}
 
+	return nil
+}
+
+// postMosaic posts mp to the mosaic service, the caller closes the body of
+// the response.
+func (app *App) postMosaic(mp MosaicPayload) (*http.Response, error) {
 	jsonData, err := json.Marshal(&mp)
 	if err != nil {
 		return nil, err
@@ -41,17 +104,36 @@ func (app *App) randomTilesMosaicCreateRequest(ip string, original string, tileW
 		return nil, err
 	}
 
+	return res, nil
+}
+
+// decodeMosaicResponse reads the JSON response of the mosaic service, its
+// errors included.
+func (app *App) decodeMosaicResponse(res *http.Response) (*mosaicResult, error) {
 	var mosaicServiceResponse struct {
-		Error  bool   `json:"error,omitempty"`
-		Mosaic string `json:"mosaic"`
+		Error      bool            `json:"error,omitempty"`
+		Message    string          `json:"message,omitempty"`
+		Mosaic     string          `json:"mosaic"`
+		Pyramid    json.RawMessage `json:"pyramid,omitempty"`
+		Placements json.RawMessage `json:"placements,omitempty"`
+		Metrics    json.RawMessage `json:"metrics,omitempty"`
 	}
 
 	decoder := json.NewDecoder(res.Body)
-	err = decoder.Decode(&mosaicServiceResponse)
+	err := decoder.Decode(&mosaicServiceResponse)
 	if err != nil {
 		app.logger.PrintError(err, nil)
 		return nil, err
 	}
 
-	return &mosaicServiceResponse.Mosaic, nil
+	if mosaicServiceResponse.Error {
+		return nil, fmt.Errorf("mosaic service: %s", mosaicServiceResponse.Message)
+	}
+
+	return &mosaicResult{
+		Mosaic:     mosaicServiceResponse.Mosaic,
+		Pyramid:    mosaicServiceResponse.Pyramid,
+		Placements: mosaicServiceResponse.Placements,
+		Metrics:    mosaicServiceResponse.Metrics,
+	}, nil
 }
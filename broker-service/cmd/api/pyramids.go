@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// pyramidsHandler forwards the requests for the files of deep zoom pyramids
+// to the mosaic service, which writes and serves them.
+func (app *App) pyramidsHandler() http.Handler {
+	target, err := url.Parse(app.service("pyramids"))
+	if err != nil {
+		app.logger.PrintFatal(err, nil)
+	}
+
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			app.logger.PrintError(err, nil)
+			app.errorResponse(w, r, http.StatusBadGateway, "the mosaic service could not be reached")
+		},
+	}
+}
@@ -8,6 +8,8 @@ func (app *App) Routes() http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/mosaic", app.mosaicHandler)
+	mux.HandleFunc("/coverage", app.coverageHandler)
+	mux.Handle("/pyramids/", app.pyramidsHandler())
 
 	return mux
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"net/http"
+	"strings"
+
+	"github.com/ChrisShia/tilestore"
+)
+
+// CellsPayload asks the mosaic service how many cells the mosaic of an
+// original of Width by Height pixels has, laid out as a mosaic of the same
+// tile size, shape and edge policy would be.
+type CellsPayload struct {
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	TileWidth  int    `json:"tile_width"`
+	TileHeight int    `json:"tile_height,omitempty"`
+	Shape      string `json:"shape,omitempty"`
+	Edge       string `json:"edge,omitempty"`
+}
+
+// tileSet returns the tile set a request names, or the one of the address of
+// the client, host, when it names none. The colons of IPv6 addresses, which
+// separate the keys of tile sets, are replaced by dashes.
+func tileSet(name, host string) (string, error) {
+	if name == "" {
+		name = strings.ReplaceAll(host, ":", "-")
+	}
+	return name, tilestore.ValidateName(name)
+}
+
+// tilesNeeded asks the mosaic service for the number of cells of the mosaic
+// mp renders from an original of the given size, one tile per cell at most.
+// Errors of the mosaic service are returned as *serviceError.
+func (app *App) tilesNeeded(size image.Point, mp MosaicPayload) (int, error) {
+	jsonData, err := json.Marshal(&CellsPayload{
+		Width:      size.X,
+		Height:     size.Y,
+		TileWidth:  mp.TileWidth,
+		TileHeight: mp.TileHeight,
+		Shape:      mp.Shape,
+		Edge:       mp.Edge,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, app.service("cells"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+
+	res, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return 0, readServiceError("mosaic service", res)
+	}
+
+	var cellsServiceResponse struct {
+		Cells int `json:"cells"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&cellsServiceResponse)
+	if err != nil {
+		return 0, err
+	}
+
+	return cellsServiceResponse.Cells, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ChrisShia/tilestore"
+)
+
+// Test_tilesNeeded budgets the tiles of a mosaic from the cell count of a
+// fake mosaic service, and reports the errors it answers with.
+func Test_tilesNeeded(t *testing.T) {
+	var received CellsPayload
+	mosaicService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = CellsPayload{}
+		if r.URL.Path != "/cells" || json.NewDecoder(r.Body).Decode(&received) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if received.Shape == "circle" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":true,"message":"invalid cell shape"}`))
+			return
+		}
+		w.Write([]byte(`{"cells":77}`))
+	}))
+	defer mosaicService.Close()
+
+	app := &App{services: map[string]string{"cells": mosaicService.URL + "/cells"}}
+
+	var tt = []struct {
+		name     string
+		mp       MosaicPayload
+		expected int
+		err      string
+	}{
+		{"rect", MosaicPayload{TileWidth: 10, TileHeight: 10, Edge: "pad"}, 77, ""},
+		{"invalid shape", MosaicPayload{TileWidth: 10, TileHeight: 10, Shape: "circle"}, 0, "invalid cell shape"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := app.tilesNeeded(image.Pt(101, 67), tc.mp)
+			if tc.err != "" {
+				var se *serviceError
+				if !errors.As(err, &se) || se.status != http.StatusBadRequest || se.message != tc.err {
+					t.Fatalf("expected %q answered with 400, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual != tc.expected {
+				t.Errorf("expected %d, got %d", tc.expected, actual)
+			}
+			expected := CellsPayload{Width: 101, Height: 67, TileWidth: 10, TileHeight: 10, Edge: "pad"}
+			if received != expected {
+				t.Errorf("expected %+v, got %+v", expected, received)
+			}
+		})
+	}
+}
+
+func Test_tileSet(t *testing.T) {
+	var tt = []struct {
+		name, requested, host string
+		expected              string
+		err                   error
+	}{
+		{"client address", "", "172.18.0.1", "172.18.0.1", nil},
+		{"ipv6 client address", "", "::1", "--1", nil},
+		{"named", "landscapes", "172.18.0.1", "landscapes", nil},
+		{"pattern", "img*", "172.18.0.1", "", tilestore.ErrInvalidName},
+		{"separator", "a:b", "172.18.0.1", "", tilestore.ErrInvalidName},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			set, err := tileSet(tc.requested, tc.host)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+			if err == nil && set != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, set)
+			}
+		})
+	}
+}
@@ -2,4 +2,15 @@ module broker
 
 go 1.25.1
 
-require github.com/ChrisShia/jsonlog v0.0.0-20251003103116-b71c25828da7
+require (
+	github.com/ChrisShia/jsonlog v0.0.0-20251003103116-b71c25828da7
+	github.com/ChrisShia/tilestore v0.0.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/redis/go-redis/v9 v9.16.0 // indirect
+)
+
+replace github.com/ChrisShia/tilestore => ../tilestore
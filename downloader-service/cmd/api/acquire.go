@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"downloader/picsum"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image"
+	_ "image/png"
+	"net/http"
+	"slices"
+
+	"downloader/cmd/internal"
+	"github.com/ChrisShia/tilestore"
+)
+
+const (
+	// defaultMatchThreshold is the distance within which a tile is taken to
+	// cover the cells of a bucket, when the request sets none.
+	defaultMatchThreshold = 20
+
+	// neutralChroma is the largest difference between the channels of the
+	// colors grayscale pictures are downloaded for.
+	neutralChroma = 24
+
+	acquireWorkers = 8
+)
+
+// TargetedDownloadHandler downloads pictures for the colors of an original
+// the tile set of the requestor has no tile close to, until every color has
+// one or the budget is spent, and reports what it downloaded.
+func (app *App) TargetedDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		IP         string  `json:"ip"`
+		Original   string  `json:"original"`
+		TileWidth  int     `json:"tile_width"`
+		TileHeight int     `json:"tile_height"`
+		Threshold  float64 `json:"threshold"`
+		Budget     int     `json:"budget"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if requestData.TileHeight == 0 {
+		requestData.TileHeight = requestData.TileWidth
+	}
+	if requestData.TileWidth <= 0 || requestData.TileHeight < 0 || requestData.Budget < 0 || requestData.Threshold < 0 {
+		http.Error(w, "invalid tile size, threshold or budget", http.StatusBadRequest)
+		return
+	}
+	if requestData.Threshold == 0 {
+		requestData.Threshold = defaultMatchThreshold
+	}
+
+	data, err := base64.StdEncoding.DecodeString(requestData.Original)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	originalImg, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	set, err := app.openSet(r.Context(), requestData.IP)
+	if errors.Is(err, tilestore.ErrInvalidName) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{
+			"request":      r.URL.String(),
+			"requestor_ip": requestData.IP,
+		})
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	histogram := internal.CellHistogram(originalImg, image.Pt(requestData.TileWidth, requestData.TileHeight), internal.PaletteBucket)
+	gaps, err := app.paletteGaps(r.Context(), set, histogram, requestData.Threshold)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{
+			"request":      r.URL.String(),
+			"requestor_ip": requestData.IP,
+		})
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	save := func(ctx context.Context, t tilestore.Tile, img image.Image) error {
+		_, err := internal.SaveTile(ctx, set, t, img, app.cfg.Variants)
+		return err
+	}
+	acquirer := internal.NewAcquirer(app.picsumSource(), save, acquireWorkers, app.logger)
+
+	acquisition, err := acquirer.Acquire(r.Context(), gaps, requestData.Budget)
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{
+			"request":      r.URL.String(),
+			"requestor_ip": requestData.IP,
+		})
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(map[string]any{"acquisition": acquisition})
+	if err != nil {
+		app.logger.PrintError(err, nil)
+	}
+}
+
+// openSet opens the tile set of ip, creating it and its index with the
+// configured one when it has none.
+func (app *App) openSet(ctx context.Context, ip string) (*tilestore.Set, error) {
+	set := tilestore.New(app.cfg.Redis.Client, ip)
+	set.Index = app.cfg.Index
+	if err := internal.NewRedisIndex(set).FTCREATE(ctx); err != nil {
+		return nil, err
+	}
+
+	// tiles are stored the way the set was created, which may predate the
+	// configured index
+	return tilestore.Open(ctx, app.cfg.Redis.Client, ip)
+}
+
+// paletteGaps returns the buckets of histogram the nearest tile of set is
+// farther than threshold from.
+func (app *App) paletteGaps(ctx context.Context, set *tilestore.Set, histogram []internal.Bucket, threshold float64) (*internal.Gaps, error) {
+	colors := make([][3]float64, len(histogram))
+	for i, b := range histogram {
+		colors[i] = b.Color
+	}
+
+	distances, err := internal.NewRedisIndex(set).Distances(ctx, colors)
+	if err != nil {
+		return nil, err
+	}
+
+	gaps := internal.NewGaps(threshold)
+	for i, b := range histogram {
+		if distances[i] > threshold {
+			gaps.Add(b)
+		}
+	}
+
+	return gaps, nil
+}
+
+// picsumSource downloads grayscale pictures for neutral colors, which random
+// pictures rarely average to, and plain ones for the others, all blurred as
+// configured.
+func (app *App) picsumSource() internal.Source {
+	return func(gap internal.Bucket) *http.Request {
+		return picsum.Random200300Variant(picsum.Variant{
+			Grayscale: slices.Max(gap.Color[:])-slices.Min(gap.Color[:]) <= neutralChroma,
+			Blur:      app.cfg.PicsumBlur,
+		})
+	}
+}
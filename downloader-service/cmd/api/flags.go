@@ -2,14 +2,33 @@ package main
 
 import (
 	"flag"
+	"strings"
+
+	"github.com/ChrisShia/tilestore"
 )
 
 func (c *Config) flags() {
+	var algorithm, vector string
+
 	flag.IntVar(&c.Port, "p", 80, "server port")
 	flag.BoolVar(&c.Fs, "file-storage", false, "Start NATS as an embedded server")
 	flag.BoolVar(&c.Nats.Embedded, "embed-nats", false, "Start NATS as an embedded server")
 	flag.StringVar(&c.Nats.Url, "nats", "", "Nats server URL")
 	flag.StringVar(&c.Redis.Addr, "redis", "", "Redis server address")
 
+	defaultIndex := tilestore.DefaultIndex()
+	flag.StringVar(&algorithm, "index-algorithm", string(defaultIndex.Algorithm), "Vector index of new tile sets, HNSW or FLAT")
+	flag.StringVar(&vector, "index-vector", string(defaultIndex.Vector), "Vector type of new tile sets, FLOAT32 or FLOAT64")
+	flag.IntVar(&c.Index.M, "hnsw-m", 0, "HNSW edges per node, 0 keeps the redis default")
+	flag.IntVar(&c.Index.EFConstruction, "hnsw-ef-construction", 0, "HNSW candidates kept while indexing, 0 keeps the redis default")
+	flag.IntVar(&c.Index.EFRuntime, "hnsw-ef-runtime", 0, "HNSW candidates kept while searching, 0 keeps the redis default")
+
+	flag.IntVar(&c.PicsumBlur, "picsum-blur", 0, "Blur of the pictures downloaded to fill palette gaps, 1 to 10, 0 for none")
+
+	flag.IntVar(&c.Variants, "variants", 0, "Synthetic variants stored with every tile downloaded, up to 12, 0 for none")
+
 	flag.Parse()
+
+	c.Index.Algorithm = tilestore.Algorithm(strings.ToUpper(algorithm))
+	c.Index.Vector = tilestore.VectorType(strings.ToUpper(vector))
 }
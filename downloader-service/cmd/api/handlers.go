@@ -1,15 +1,18 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"downloader/picsum"
 	"encoding/json"
+	"errors"
 	"image"
 	"io"
 	"net/http"
 	"os"
 
 	"downloader/cmd/internal"
+	"github.com/ChrisShia/tilestore"
 )
 
 func (app *App) DownloadNRandomPicsFromPicSumHandler(w http.ResponseWriter, r *http.Request) {
@@ -36,12 +39,22 @@ func (app *App) DownloadNRandomPicsFromPicSumHandler(w http.ResponseWriter, r *h
 	}
 
 	//TODO: Ip address as a field since the request is essentially made from the broker(?)
-	requestorIndexPrefix := app.redisIndexPrefix(requestData.IP)
-	redisIndex := internal.NewRedisIndex(requestData.IP, requestorIndexPrefix, app.cfg.Redis.Client)
-	redisIndex.FTCREATE()
+	set, err := app.openSet(r.Context(), requestData.IP)
+	if errors.Is(err, tilestore.ErrInvalidName) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		app.logger.PrintError(err, map[string]string{
+			"request":      r.URL.String(),
+			"requestor_ip": requestData.IP,
+		})
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	d := internal.NewDownloader(app.saveToRedis, app.logger)
-	d.DownloadN(app.cfg.Nats.Client, requestData.IP, requestorIndexPrefix, requestData.N, picSumRandomPicRequest)
+	d := internal.NewDownloader(app.saveToRedis(set), app.logger)
+	d.DownloadN(app.cfg.Nats.Client, requestData.IP, set.Prefix, requestData.N, picSumRandomPicRequest)
 }
 
 func (app *App) saveToFile(from io.Reader) error {
@@ -62,21 +75,36 @@ func (app *App) saveToFile(from io.Reader) error {
 	return nil
 }
 
-func (app *App) saveToRedis(ip, key string, from io.Reader) {
-	img, err := app.Image(from)
-	if err != nil {
-		app.logger.PrintError(err, nil)
-		return
+func (app *App) saveToRedis(set *tilestore.Set) internal.To {
+	return func(ip, _ string, from io.Reader) {
+		data, err := io.ReadAll(from)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+			return
+		}
+
+		if app.cfg.Variants > 0 {
+			err = app.saveWithVariants(set, data)
+		} else {
+			err = internal.SaveToRedis(data, set, internal.ImageAverageRGB, context.Background())
+		}
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{
+				"requestor_ip": ip,
+			})
+		}
 	}
-
-	_ = internal.SaveToRedis(img, app.cfg.Redis.Client, ip, key, internal.ImageAverageRGB, context.Background())
 }
 
-func (app *App) Image(r io.Reader) (image.Image, error) {
-	img, _, err := image.Decode(r)
+// saveWithVariants stores the encoded image data in the tile set along with
+// as many variants of it as configured.
+func (app *App) saveWithVariants(set *tilestore.Set, data []byte) error {
+	img, _, err := image.Decode(bytes.NewReader(data))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return img, nil
+	tile := tilestore.Tile{Image: data, Vector: internal.ImageAverageRGB(img)}
+	_, err = internal.SaveTile(context.Background(), set, tile, img, app.cfg.Variants)
+	return err
 }
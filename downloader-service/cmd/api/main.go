@@ -8,6 +8,7 @@ import (
 	"os"
 
 	"github.com/ChrisShia/jsonlog"
+	"github.com/ChrisShia/tilestore"
 	"github.com/nats-io/nats.go"
 	"github.com/redis/go-redis/v9"
 )
@@ -26,6 +27,15 @@ type Config struct {
 		Addr   string
 		Client *redis.Client
 	}
+	// Index is the vector index tile sets are created with.
+	Index tilestore.Index
+	// PicsumBlur blurs the pictures downloaded to fill the gaps of tile
+	// sets, from 1 to 10: blurred tiles read as flat patches of their
+	// average color.
+	PicsumBlur int
+	// Variants is the number of synthetic variants stored with every tile
+	// downloaded, none when 0.
+	Variants int
 }
 
 type App struct {
@@ -43,6 +53,11 @@ func main() {
 		cfg:    cfg,
 	}
 
+	if err := cfg.Index.Validate(); err != nil {
+		app.logger.PrintError(err, nil)
+		return
+	}
+
 	redisClose, err := app.connectToRedis(cfg)
 	if err != nil {
 		app.logger.PrintError(err, nil)
@@ -126,7 +141,3 @@ func (app *App) connectToRedis(cfg Config) (func(), error) {
 		}
 	}
 }
-
-func (app *App) redisIndexPrefix(ip string) string {
-	return fmt.Sprintf("img:%s", ip)
-}
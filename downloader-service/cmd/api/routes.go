@@ -6,6 +6,7 @@ func (app *App) routes() http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/pic.sum/random/download", app.DownloadNRandomPicsFromPicSumHandler)
+	mux.HandleFunc("/pic.sum/targeted/download", app.TargetedDownloadHandler)
 
 	return mux
 }
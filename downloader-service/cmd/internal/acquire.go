@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+
+	"github.com/ChrisShia/jsonlog"
+	"github.com/ChrisShia/tilestore"
+)
+
+// Source builds the request of a picture meant to fill gap.
+type Source func(gap Bucket) *http.Request
+
+// Acquisition reports what an Acquirer downloaded: Gaps is the number of
+// gaps there were to fill and Remaining the gaps left unfilled once the
+// budget was spent.
+type Acquisition struct {
+	Gaps       int      `json:"gaps"`
+	Downloaded int      `json:"downloaded"`
+	Stored     int      `json:"stored"`
+	Remaining  []Bucket `json:"remaining"`
+}
+
+// Acquirer downloads pictures from Source, Workers at a time, and saves the
+// ones that fill a gap of a tile set with Save.
+type Acquirer struct {
+	By      http.Client
+	Source  Source
+	Save    func(ctx context.Context, t tilestore.Tile, img image.Image) error
+	Workers int
+	logger  *jsonlog.Logger
+}
+
+func NewAcquirer(source Source, save func(context.Context, tilestore.Tile, image.Image) error, workers int, logger *jsonlog.Logger) *Acquirer {
+	return &Acquirer{
+		By:      http.Client{},
+		Source:  source,
+		Save:    save,
+		Workers: max(workers, 1),
+		logger:  logger,
+	}
+}
+
+// download is a picture downloaded for a gap, with its average color.
+type download struct {
+	tile tilestore.Tile
+	img  image.Image
+	err  error
+}
+
+// Acquire downloads pictures until every gap is filled or budget pictures
+// were downloaded. Pictures that fill no gap are dropped. Failed downloads
+// are logged and count against the budget, failing to save stops the
+// acquisition.
+func (a *Acquirer) Acquire(ctx context.Context, gaps *Gaps, budget int) (Acquisition, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	acquisition := Acquisition{Gaps: gaps.Len()}
+
+	results := make(chan download)
+	inFlight := 0
+	var saveErr error
+
+	dispatch := func() {
+		for saveErr == nil && inFlight < a.Workers && acquisition.Downloaded < budget && gaps.Len() > 0 {
+			req := a.Source(gaps.Target(acquisition.Downloaded)).WithContext(ctx)
+			acquisition.Downloaded++
+			inFlight++
+			go func() {
+				results <- a.get(req)
+			}()
+		}
+	}
+
+	for dispatch(); inFlight > 0; dispatch() {
+		d := <-results
+		inFlight--
+
+		if d.err != nil {
+			a.logger.PrintError(d.err, nil)
+			continue
+		}
+		if saveErr != nil || gaps.Fill(d.tile.Vector) == 0 {
+			continue
+		}
+
+		if err := a.Save(ctx, d.tile, d.img); err != nil {
+			saveErr = err
+			cancel()
+			continue
+		}
+		acquisition.Stored++
+	}
+
+	acquisition.Remaining = gaps.Buckets()
+
+	return acquisition, saveErr
+}
+
+// get downloads the picture of req and averages its color. The tile is
+// sourced from the URL the picture was served from, after redirects.
+func (a *Acquirer) get(req *http.Request) download {
+	res, err := a.By.Do(req)
+	if err != nil {
+		return download{err: err}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return download{err: fmt.Errorf("%s: %s", req.URL, res.Status)}
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return download{err: err}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return download{err: fmt.Errorf("%s: %w", res.Request.URL, err)}
+	}
+
+	return download{
+		tile: tilestore.Tile{
+			Image:  data,
+			Vector: ImageAverageRGB(img),
+			Source: res.Request.URL.String(),
+		},
+		img: img,
+	}
+}
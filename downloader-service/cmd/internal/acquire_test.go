@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ChrisShia/jsonlog"
+	"github.com/ChrisShia/tilestore"
+)
+
+func Test_Acquire(t *testing.T) {
+	// serves uniform pictures of the gray asked for
+	var served atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+		v, err := strconv.Atoi(r.URL.Query().Get("gray"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+		fill(img, img.Bounds(), color.NRGBA{R: uint8(v), G: uint8(v), B: uint8(v), A: 255})
+		_ = png.Encode(w, img)
+	}))
+	defer srv.Close()
+
+	// every other request misses its gap by 50
+	var requests atomic.Int32
+	source := func(gap Bucket) *http.Request {
+		v := int(gap.Color[0])
+		if requests.Add(1)%2 == 0 {
+			v = (v + 50) % 256
+		}
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"?gray="+strconv.Itoa(v), nil)
+		return req
+	}
+
+	newGaps := func() *Gaps {
+		gaps := NewGaps(5)
+		for _, v := range []float64{10, 60, 120, 180, 240} {
+			gaps.Add(Bucket{Color: [3]float64{v, v, v}, Cells: 1})
+		}
+		return gaps
+	}
+
+	var tt = []struct {
+		name       string
+		budget     int
+		downloaded int
+		stored     int
+		remaining  int
+	}{
+		{"no budget", 0, 0, 0, 5},
+		{"budget", 3, 3, 2, 3},
+		// five hits with the misses in between
+		{"filled", 50, 9, 5, 0},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			requests.Store(0)
+			served.Store(0)
+
+			var saved []tilestore.Tile
+			save := func(_ context.Context, tile tilestore.Tile, _ image.Image) error {
+				saved = append(saved, tile)
+				return nil
+			}
+
+			// a single worker so that downloads follow each other
+			a := NewAcquirer(source, save, 1, jsonlog.New(&bytes.Buffer{}, jsonlog.LevelInfo))
+			acquisition, err := a.Acquire(context.Background(), newGaps(), tc.budget)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if acquisition.Gaps != 5 || acquisition.Downloaded != tc.downloaded || acquisition.Stored != tc.stored || len(acquisition.Remaining) != tc.remaining {
+				t.Errorf("expected %d downloaded, %d stored and %d remaining, got %+v", tc.downloaded, tc.stored, tc.remaining, acquisition)
+			}
+			if len(saved) != acquisition.Stored || int(served.Load()) != acquisition.Downloaded {
+				t.Errorf("expected %d tiles saved of %d served, got %d of %d", acquisition.Stored, acquisition.Downloaded, len(saved), served.Load())
+			}
+			for _, tile := range saved {
+				if tile.Source == "" || len(tile.Image) == 0 {
+					t.Errorf("expected tiles with their source and image, got %q", tile.Source)
+				}
+			}
+		})
+	}
+}
+
+func Test_AcquireSaveError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = png.Encode(w, image.NewNRGBA(image.Rect(0, 0, 2, 2)))
+	}))
+	defer srv.Close()
+
+	source := func(Bucket) *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		return req
+	}
+	errSave := errors.New("save")
+	save := func(context.Context, tilestore.Tile, image.Image) error {
+		return errSave
+	}
+
+	gaps := NewGaps(5)
+	gaps.Add(Bucket{Cells: 1})
+
+	a := NewAcquirer(source, save, 4, jsonlog.New(&bytes.Buffer{}, jsonlog.LevelInfo))
+	if _, err := a.Acquire(context.Background(), gaps, 100); !errors.Is(err, errSave) {
+		t.Errorf("expected the save error, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"cmp"
+	"image"
+	"math"
+	"slices"
+
+	"github.com/ChrisShia/tilestore"
+)
+
+// PaletteBucket is the width, per channel, of the color buckets the cells of
+// an original are grouped in.
+const PaletteBucket = 32
+
+// Bucket is a group of cells of similar colors, Color is their average color.
+type Bucket struct {
+	Color [3]float64 `json:"color"`
+	Cells int        `json:"cells"`
+}
+
+// CellHistogram cuts img into cells of size, left to right and top to
+// bottom, and groups their average colors into buckets width wide per
+// channel, the buckets with the most cells first. Transparent cells are left
+// out. Mosaics of brick or hex cells are cut the same way, their cells being
+// the same size.
+func CellHistogram(img image.Image, size image.Point, width int) []Bucket {
+	type sum struct {
+		color [3]float64
+		cells int
+	}
+	sums := make(map[[3]int]*sum)
+
+	r := img.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y += size.Y {
+		for x := r.Min.X; x < r.Max.X; x += size.X {
+			avg, coverage := tilestore.AverageArea(img, image.Rect(x, y, min(x+size.X, r.Max.X), min(y+size.Y, r.Max.Y)))
+			if coverage == 0 {
+				continue
+			}
+
+			key := [3]int{int(avg[0]) / width, int(avg[1]) / width, int(avg[2]) / width}
+			s, ok := sums[key]
+			if !ok {
+				s = &sum{}
+				sums[key] = s
+			}
+			for i := range avg {
+				s.color[i] += avg[i]
+			}
+			s.cells++
+		}
+	}
+
+	buckets := make([]Bucket, 0, len(sums))
+	for _, s := range sums {
+		n := float64(s.cells)
+		buckets = append(buckets, Bucket{
+			Color: [3]float64{s.color[0] / n, s.color[1] / n, s.color[2] / n},
+			Cells: s.cells,
+		})
+	}
+	slices.SortFunc(buckets, func(a, b Bucket) int {
+		return cmp.Or(cmp.Compare(b.Cells, a.Cells), slices.Compare(a.Color[:], b.Color[:]))
+	})
+
+	return buckets
+}
+
+// Gaps are the buckets of a histogram no tile is within Threshold of, in the
+// order they were added.
+type Gaps struct {
+	Threshold float64
+	buckets   []Bucket
+}
+
+func NewGaps(threshold float64) *Gaps {
+	return &Gaps{Threshold: threshold}
+}
+
+func (g *Gaps) Add(b Bucket) {
+	g.buckets = append(g.buckets, b)
+}
+
+func (g *Gaps) Len() int {
+	return len(g.buckets)
+}
+
+// Buckets returns the gaps left.
+func (g *Gaps) Buckets() []Bucket {
+	return slices.Clone(g.buckets)
+}
+
+// Target is the gap the i-th download is meant for, the gaps being taken in
+// turn so that the larger ones are not the only ones tried.
+func (g *Gaps) Target(i int) Bucket {
+	return g.buckets[i%len(g.buckets)]
+}
+
+// Fill removes the gaps a tile of color is within the threshold of and
+// returns how many it filled.
+func (g *Gaps) Fill(color [3]float64) int {
+	n := len(g.buckets)
+	g.buckets = slices.DeleteFunc(g.buckets, func(b Bucket) bool {
+		return Distance(b.Color, color) <= g.Threshold
+	})
+	return n - len(g.buckets)
+}
+
+// Distance is the euclidean distance between two colors, the distance tile
+// sets are searched by.
+func Distance(a, b [3]float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func Test_CellHistogram(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	fill(img, image.Rect(0, 0, 40, 30), color.NRGBA{R: 200, G: 10, B: 10, A: 255})
+	fill(img, image.Rect(0, 0, 10, 20), color.NRGBA{R: 100, G: 100, B: 100, A: 255})
+	fill(img, image.Rect(30, 20, 40, 30), color.NRGBA{})
+	// one cell split between two grays of the same bucket
+	fill(img, image.Rect(10, 20, 20, 30), color.NRGBA{R: 96, G: 96, B: 96, A: 255})
+	fill(img, image.Rect(15, 20, 20, 30), color.NRGBA{R: 110, G: 110, B: 110, A: 255})
+
+	buckets := CellHistogram(img, image.Pt(10, 10), PaletteBucket)
+
+	cells := make([]int, len(buckets))
+	for i, b := range buckets {
+		cells[i] = b.Cells
+	}
+	// 8 red cells, 3 gray ones and a transparent one left out
+	if !reflect.DeepEqual(cells, []int{8, 3}) {
+		t.Fatalf("expected buckets of 8 and 3 cells, got %v", buckets)
+	}
+
+	if buckets[0].Color[0] < 199 || buckets[0].Color[1] > 11 {
+		t.Errorf("expected red, got %v", buckets[0].Color)
+	}
+	gray := buckets[1].Color
+	if gray[0] < 96 || gray[0] > 110 || gray[0] != gray[1] || gray[1] != gray[2] {
+		t.Errorf("expected a gray between 96 and 110, got %v", gray)
+	}
+}
+
+func Test_Gaps(t *testing.T) {
+	gaps := NewGaps(10)
+	gaps.Add(Bucket{Color: [3]float64{0, 0, 0}, Cells: 5})
+	gaps.Add(Bucket{Color: [3]float64{8, 0, 0}, Cells: 3})
+	gaps.Add(Bucket{Color: [3]float64{200, 0, 0}, Cells: 1})
+
+	if target := gaps.Target(4); target.Cells != 3 {
+		t.Errorf("expected the gaps in turn, got %v", target)
+	}
+
+	if n := gaps.Fill([3]float64{100, 100, 100}); n != 0 {
+		t.Errorf("expected a far color to fill nothing, filled %d", n)
+	}
+	if n := gaps.Fill([3]float64{4, 3, 0}); n != 2 {
+		t.Errorf("expected 2 gaps filled, got %d", n)
+	}
+	if left := gaps.Buckets(); len(left) != 1 || left[0].Cells != 1 {
+		t.Errorf("expected the red gap left, got %v", left)
+	}
+
+	if d := Distance([3]float64{0, 3, 0}, [3]float64{4, 0, 0}); math.Abs(d-5) > 1e-12 {
+		t.Errorf("expected a distance of 5, got %v", d)
+	}
+}
+
+func fill(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
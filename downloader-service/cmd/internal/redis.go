@@ -3,146 +3,99 @@ package internal
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
-	"encoding/binary"
 	"fmt"
 	"image"
-	"image/jpeg"
-	"io"
-	"log"
+	_ "image/jpeg"
 	"math"
+	"strconv"
 	"time"
 
+	"github.com/ChrisShia/tilestore"
 	"github.com/redis/go-redis/v9"
 )
 
-func SaveToRedis(img image.Image, c *redis.Client, ip, indexPrefix string, indexer func(image.Image) [3]float64, ctx context.Context) error {
-	float64Vector := indexer(img)
-
-	avColorBinary, err := binaryFloat64bit(float64Vector)
-	if err != nil {
-		return err
-	}
-
-	imgBase64String, err := imageToBase64String(img)
-	if err != nil {
-		return err
-	}
-
-	data := struct {
-		Img          string `redis:"img"`
-		AverageColor []byte `redis:"average_color"`
-	}{
-		Img:          imgBase64String,
-		AverageColor: avColorBinary,
-	}
-
-	counterKey := fmt.Sprintf("%s:counter", ip)
-
-	id, err := c.Incr(ctx, counterKey).Result()
+// SaveToRedis stores the encoded image data in the tile set as downloaded,
+// searchable by the vector indexer computes from the decoded image.
+func SaveToRedis(data []byte, set *tilestore.Set, indexer func(image.Image) [3]float64, ctx context.Context) error {
+	img, _, err := image.Decode(bytes.NewReader(data))
 	if err != nil {
 		return err
 	}
 
-	key := dbKey(indexPrefix, id)
+	_, err = set.Add(ctx, tilestore.Tile{
+		Image:  data,
+		Vector: indexer(img),
+	})
 
-	if err = c.HSet(ctx, key, data).Err(); err != nil {
-		return err
-	}
-
-	return nil
+	return err
 }
 
-func dbKey(indexPrefix string, id int64) string {
-	return fmt.Sprintf("%s:%d", indexPrefix, id)
+type RedisIndex struct {
+	Set *tilestore.Set
 }
 
-func binaryFloat64bit(indexVector [3]float64) ([]byte, error) {
-	avColorBinary := make([]byte, 8*len(indexVector))
-	for i, f := range indexVector {
-		binary.LittleEndian.PutUint64(avColorBinary[i*8:], math.Float64bits(f))
+func NewRedisIndex(set *tilestore.Set) *RedisIndex {
+	return &RedisIndex{
+		Set: set,
 	}
-
-	return avColorBinary, nil
 }
 
-func imageToBase64String(img image.Image) (string, error) {
-	jpegEncoder := func(w io.Writer, img image.Image) error {
-		return jpeg.Encode(w, img, nil)
-	}
-
-	imgBuf, err := imageToBytes(img, jpegEncoder)
-	if err != nil {
-		return "", err
-	}
-
-	base64Str := base64.StdEncoding.EncodeToString(imgBuf.Bytes())
-
-	return base64Str, nil
+// FTCREATE creates the index of the tile set, or checks the one it has, and
+// is safe to call on every download.
+func (ri *RedisIndex) FTCREATE(ctx context.Context) error {
+	return ri.Set.EnsureIndex(ctx)
 }
 
-func imageToBytes(img image.Image, encoder func(io.Writer, image.Image) error) (*bytes.Buffer, error) {
-	imgBuf := new(bytes.Buffer)
+func (ri *RedisIndex) FTSEARCH(ctx context.Context, searchFor [3]float64) (*tilestore.SearchResult, error) {
+	args := ri.Set.SearchArgs(searchFor, 5, tilestore.FieldVector, tilestore.FieldScore)
 
-	err := encoder(imgBuf, img)
+	result, err := ri.Set.Client.Do(ctx, args...).Result()
 	if err != nil {
 		return nil, err
 	}
-	return imgBuf, nil
-}
 
-type RedisIndex struct {
-	Name   string
-	Prefix string
-	Client *redis.Client
+	return tilestore.ParseSearchResult(result, false)
 }
 
-func NewRedisIndex(name string, prefix string, client *redis.Client) *RedisIndex {
-	return &RedisIndex{
-		Name:   name,
-		Prefix: prefix,
-		Client: client,
+// Distances searches, in a single pipeline, the tile nearest to every color
+// and returns how far it is, or +Inf when the set has no tiles.
+func (ri *RedisIndex) Distances(ctx context.Context, colors [][3]float64) ([]float64, error) {
+	pipe := ri.Set.Client.Pipeline()
+	cmds := make([]*redis.Cmd, len(colors))
+	for i, c := range colors {
+		cmds[i] = pipe.Do(ctx, ri.Set.SearchArgs(c, 1, tilestore.FieldScore)...)
 	}
-}
 
-// FTCREATE TODO: error propagation
-func (ri *RedisIndex) FTCREATE() {
-	_, err := ri.Client.Do(context.Background(),
-		"FT.CREATE", ri.Name,
-		"ON", "HASH",
-		"PREFIX", "1", ri.Prefix,
-		"SCHEMA", "average_color", "VECTOR", "HNSW", "6",
-		"TYPE", "FLOAT64",
-		"DIM", "3",
-		"DISTANCE_METRIC", "L2", //Euclidean distance
-	).Result()
-	if err != nil {
-		// ignore error if index already exists
-		if err.Error() != "Index already exists" {
-			log.Fatalf("FTCreate failed: %s", err)
+	// the error of every command is checked below
+	_, _ = pipe.Exec(ctx)
+
+	distances := make([]float64, len(colors))
+	for i, cmd := range cmds {
+		reply, err := cmd.Result()
+		if err != nil {
+			return nil, err
 		}
-	}
-}
 
-func (ri *RedisIndex) FTSEARCH(searchFor [3]float64, c *redis.Client) (interface{}, error) {
-	searchForBinary, err := binaryFloat64bit(searchFor)
-	if err != nil {
-		return nil, err
-	}
+		result, err := tilestore.ParseSearchResult(reply, false)
+		if err != nil {
+			return nil, err
+		}
 
-	result, err := c.Do(context.Background(),
-		"FT.SEARCH", ri.Name,
-		"*=>[KNN 5 @average_color $vec]",
-		"PARAMS", "2", "vec", searchForBinary,
-		"SORTBY", "average_color",
-		"RETURN", "2", "img", "average_color",
-		"DIALECT", "2",
-	).Result()
-	if err != nil {
-		return nil, err
+		if len(result.Docs) == 0 {
+			distances[i] = math.Inf(1)
+			continue
+		}
+
+		// the score of L2 indexes is the squared distance
+		score := result.Docs[0].Fields[tilestore.FieldScore]
+		squared, err := strconv.ParseFloat(score, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: score %q", tilestore.ErrUnexpectedReply, score)
+		}
+		distances[i] = math.Sqrt(squared)
 	}
 
-	return result, nil
+	return distances, nil
 }
 
 func EstablishRedisConnAndPing(addr string) (*redis.Client, error) {
@@ -169,184 +122,3 @@ func RedisClient(addr string) (*redis.Client, error) {
 	client := redis.NewClient(opt)
 	return client, nil
 }
-
-func splitFields(v []byte) <-chan []byte {
-	out := make(chan []byte)
-
-	go func() {
-		defer close(out)
-		remainder := v
-		for len(remainder) > 0 {
-			keyValue, rem := FirstInLineKeyValue(remainder)
-			remainder = rem
-			out <- keyValue
-		}
-	}()
-
-	return out
-}
-
-func RedisResults(s []byte) []*Result {
-	allResults, _ := redisResultsField(s)
-
-	return Results(allResults)
-}
-
-func Results(s []byte) []*Result {
-	r := make([]*Result, 0)
-
-	for res := range bridge(resultsStream(s, nil), nil) {
-		r = append(r, res)
-	}
-
-	return r
-}
-
-func bridge(chanStream <-chan <-chan *Result, done <-chan struct{}) <-chan *Result {
-	out := make(chan *Result)
-
-	go func() {
-		defer close(out)
-		for {
-			var stream <-chan *Result
-			select {
-			case v, ok := <-chanStream:
-				if !ok {
-					return
-				}
-				stream = v
-			case <-done:
-				return
-			}
-
-			for v := range stream {
-				select {
-				case out <- v:
-				case <-done:
-					return
-				}
-			}
-		}
-	}()
-
-	return out
-}
-
-func resultsStream(s []byte, done <-chan struct{}) <-chan <-chan *Result {
-	mapIdentifier := "map"
-	f := func(bs []byte) (int, int) {
-		return 0, bytes.Index(bs, []byte(mapIdentifier)) + len(mapIdentifier)
-	}
-	outQueue := queuedStreamsFunc(s, f, done)
-
-	out := make(chan (<-chan *Result))
-	go func() {
-		defer close(out)
-		for _, c := range outQueue {
-			out <- c
-		}
-	}()
-
-	return out
-}
-
-func queuedStreamsFunc(s []byte, f func(bs []byte) (int, int), done <-chan struct{}) []<-chan *Result {
-	outQueue := make([]<-chan *Result, 0)
-
-	s = s[1 : len(s)-1]
-
-	keyValue := make([]byte, 0)
-	for len(s) > 0 {
-		if len(s) == 0 {
-			break
-		}
-
-		keyValue, s = firstAvailableFunc(s, f)
-
-		c := make(chan *Result)
-		outQueue = append(outQueue, c)
-		go func(kv []byte, o chan<- *Result) {
-			defer close(o)
-			select {
-			case o <- NewResult(kv):
-			case <-done:
-				return
-			}
-		}(keyValue, c)
-	}
-
-	return outQueue
-}
-
-type Result struct {
-	Attributes string
-	//Attributes map[string]string
-}
-
-func NewResult(s []byte) *Result {
-	//m := make(map[string]string)
-
-	return &Result{
-		Attributes: string(s),
-	}
-}
-
-func redisResultsField(s []byte) ([]byte, []byte) {
-	f := func(bs []byte) (int, int) {
-		identifier := []byte("results:")
-		i := bytes.Index(s, identifier)
-		if i == -1 {
-			return -1, -1
-		}
-		return i, i + len(identifier)
-	}
-
-	return firstAvailableFunc(s, f)
-}
-
-func FirstInLineKeyValue(s []byte) ([]byte, []byte) {
-	f := func(bs []byte) (int, int) {
-		return 0, bytes.IndexByte(bs, ':') + 1
-	}
-
-	return firstAvailableFunc(s, f)
-}
-
-func firstAvailableFunc(s []byte, f func(bs []byte) (int, int)) ([]byte, []byte) {
-	if len(s) == 0 {
-		return []byte{}, []byte{}
-	}
-
-	identifierStart, valueStart := f(s)
-	if identifierStart == -1 {
-		return []byte{}, []byte{}
-	}
-
-	valueEnd := findValueEnd(s, valueStart)
-	keyValue := s[identifierStart : valueEnd+1]
-
-	remainder := bytes.TrimLeft(s[valueEnd+1:], " ")
-	return keyValue, remainder
-}
-
-func findValueEnd(s []byte, start int) int {
-	var end int
-
-	switch {
-	case isBra(s[start]):
-		end = matchingKetIndex(s, start)
-	default:
-		end = start + indexBeforeFirstWhiteSpaceOrEndOfSequence(s[start:])
-	}
-
-	return end
-}
-
-func indexBeforeFirstWhiteSpaceOrEndOfSequence(s []byte) int {
-	firstWhiteSpace := bytes.IndexRune(s, ' ')
-	if firstWhiteSpace == -1 {
-		return len(s) - 1
-	}
-
-	return firstWhiteSpace - 1
-}
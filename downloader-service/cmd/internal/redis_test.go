@@ -1,16 +1,16 @@
 package internal
 
 import (
+	"bytes"
 	"context"
-	"encoding/binary"
 	"fmt"
 	"image"
 	"log"
-	"math"
 	"os"
 	"reflect"
 	"testing"
 
+	"github.com/ChrisShia/tilestore"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -24,29 +24,33 @@ func Test_RedisHGetAll(t *testing.T) {
 	redisClient, closer := redisTestClient()
 	defer closer()
 
-	ip := "0.0.0.0"
-	indexPrefix := "img" + ip
+	set := tilestore.New(redisClient, "0.0.0.0")
 
-	redisIndex := NewRedisIndex(ip, indexPrefix, redisClient)
-	redisIndex.FTCREATE()
+	redisIndex := NewRedisIndex(set)
+	if err := redisIndex.FTCREATE(context.Background()); err != nil {
+		t.Fatal(err)
+	}
 
 	testImg := testImage()
 
-	expectedAverageColorVector := averageColor(testImg)
+	expectedAverageColorVector := storedVector(averageColor(testImg))
 
-	err := SaveToRedis(testImg, redisClient, ip, indexPrefix, averageColor, context.Background())
+	err := SaveToRedis(testImageData(), set, averageColor, context.Background())
 	if err != nil {
 		t.Error(err)
 	}
 
-	result, err := redisClient.HGetAll(context.Background(), dbKey(indexPrefix, 1)).Result()
+	result, err := redisClient.HGetAll(context.Background(), set.Key(1)).Result()
 	if err != nil {
 		t.Error(err)
 	}
 
 	averageBinary := result["average_color"]
 
-	redisAverageColorVector := float64Vector([]byte(averageBinary))
+	redisAverageColorVector, err := tilestore.DecodeVector([]byte(averageBinary))
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if !reflect.DeepEqual(expectedAverageColorVector, redisAverageColorVector) {
 		t.Errorf("expected average %v, got %v", expectedAverageColorVector, redisAverageColorVector)
@@ -57,51 +61,48 @@ func Test_RedisDoFTSearch(t *testing.T) {
 	redisClient, closer := redisTestClient()
 	defer closer()
 
-	ip := "0.0.0.0"
-	indexPrefix := fmt.Sprintf("%s:img", ip)
+	set := tilestore.New(redisClient, "0.0.0.0")
 
-	redisIndex := NewRedisIndex(ip, indexPrefix, redisClient)
-	redisIndex.FTCREATE()
+	redisIndex := NewRedisIndex(set)
+	if err := redisIndex.FTCREATE(context.Background()); err != nil {
+		t.Fatal(err)
+	}
 
 	testImg := testImage()
 
-	expectedAverageColorVector := averageColor(testImg)
+	expectedAverageColorVector := storedVector(averageColor(testImg))
 
-	err := SaveToRedis(testImg, redisClient, ip, indexPrefix, averageColor, context.Background())
+	err := SaveToRedis(testImageData(), set, averageColor, context.Background())
 	if err != nil {
 		t.Error(err)
 	}
 
-	redisDoResult, err := redisIndex.FTSEARCH(expectedAverageColorVector, redisClient)
+	redisDoResult, err := redisIndex.FTSEARCH(context.Background(), expectedAverageColorVector)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	redisDoResultMap := redisDoResult.(map[interface{}]interface{})
-
-	allResults := redisDoResultMap["results"].([]interface{})
-	firstResult := allResults[0]
-
-	firstResultMap := firstResult.(map[interface{}]interface{})
-	firstResultExtraAttributesMap := firstResultMap["extra_attributes"].(map[interface{}]interface{})
-	actualAverageColor := firstResultExtraAttributesMap["average_color"]
+	if len(redisDoResult.Docs) == 0 {
+		t.Fatal("Expected a result")
+	}
+	firstResultFields := redisDoResult.Docs[0].Fields
 
-	actualAverageColorFloat64Vector := float64Vector([]byte(actualAverageColor.(string)))
+	actualAverageColorFloat64Vector, err := tilestore.DecodeVector([]byte(firstResultFields["average_color"]))
+	if err != nil {
+		t.Fatal(err)
+	}
 	for i, f := range actualAverageColorFloat64Vector {
 		if f != expectedAverageColorVector[i] {
 			t.Errorf("expected %v, got %v", expectedAverageColorVector[i], f)
 		}
 	}
-	actualImg := firstResultExtraAttributesMap["img"]
-
-	if actualImg == nil {
-		t.Error("Expected an image")
+	actualImg, err := set.Image(context.Background(), redisDoResult.Docs[0].ID)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	expectedBase64StringImage, _ := imageToBase64String(testImg)
-	actualImgString := actualImg.(string)
-	if expectedBase64StringImage != actualImgString {
-		t.Errorf("expected %v, got %v", expectedBase64StringImage, actualImgString)
+	if !bytes.Equal(testImageData(), actualImg) {
+		t.Errorf("expected the image as downloaded, got %d bytes", len(actualImg))
 	}
 }
 
@@ -109,11 +110,12 @@ func Test_RedisDoFTSearchApproximate(t *testing.T) {
 	redisClient, closer := redisTestClient()
 	defer closer()
 
-	ip := "0.0.0.0"
-	indexPrefix := fmt.Sprintf("%s:img", ip)
+	set := tilestore.New(redisClient, "0.0.0.0")
 
-	redisIndex := NewRedisIndex(ip, indexPrefix, redisClient)
-	redisIndex.FTCREATE()
+	redisIndex := NewRedisIndex(set)
+	if err := redisIndex.FTCREATE(context.Background()); err != nil {
+		t.Fatal(err)
+	}
 
 	testImg := testImage()
 
@@ -122,56 +124,44 @@ func Test_RedisDoFTSearchApproximate(t *testing.T) {
 	expectedAverageColorVector[1] += 100
 	expectedAverageColorVector[2] += 100
 
-	err := SaveToRedis(testImg, redisClient, ip, indexPrefix, averageColor, context.Background())
+	err := SaveToRedis(testImageData(), set, averageColor, context.Background())
 	if err != nil {
 		t.Error(err)
 	}
 
-	redisDoResult, err := redisIndex.FTSEARCH(expectedAverageColorVector, redisClient)
+	redisDoResult, err := redisIndex.FTSEARCH(context.Background(), expectedAverageColorVector)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	redisDoResultMap := redisDoResult.(map[interface{}]interface{})
-
-	allResults := redisDoResultMap["results"].([]interface{})
-	firstResult := allResults[0]
-
-	firstResultMap := firstResult.(map[interface{}]interface{})
-	firstResultExtraAttributesMap := firstResultMap["extra_attributes"].(map[interface{}]interface{})
-	actualAverageColor := firstResultExtraAttributesMap["average_color"]
+	if len(redisDoResult.Docs) == 0 {
+		t.Fatal("Expected a result")
+	}
+	firstResultFields := redisDoResult.Docs[0].Fields
 
-	actualAverageColorFloat64Vector := float64Vector([]byte(actualAverageColor.(string)))
+	actualAverageColorFloat64Vector, err := tilestore.DecodeVector([]byte(firstResultFields["average_color"]))
+	if err != nil {
+		t.Fatal(err)
+	}
 	for i, f := range actualAverageColorFloat64Vector {
 		if f != expectedAverageColorVector[i] {
 			t.Errorf("expected %v, got %v", expectedAverageColorVector[i], f)
 		}
 	}
-	actualImg := firstResultExtraAttributesMap["img"]
-
-	if actualImg == nil {
-		t.Error("Expected an image")
+	actualImg, err := set.Image(context.Background(), redisDoResult.Docs[0].ID)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	expectedBase64StringImage, _ := imageToBase64String(testImg)
-	actualImgString := actualImg.(string)
-	if expectedBase64StringImage != actualImgString {
-		t.Errorf("expected %v, got %v", expectedBase64StringImage, actualImgString)
+	if !bytes.Equal(testImageData(), actualImg) {
+		t.Errorf("expected the image as downloaded, got %d bytes", len(actualImg))
 	}
 }
 
-func float64Vector(avBinaryVector []byte) [3]float64 {
-	avColor := [3]float64{}
-	for i, _ := range avColor {
-		start := i * 8
-		end := start + 8
-		float64bits := avBinaryVector[start:end]
-		uInt64 := binary.LittleEndian.Uint64(float64bits)
-		float64Frombits := math.Float64frombits(uInt64)
-		avColor[i] = float64Frombits
-	}
-
-	return avColor
+// storedVector rounds v to the precision tile sets store vectors at.
+func storedVector(v [3]float64) [3]float64 {
+	stored, _ := tilestore.DecodeVector(tilestore.EncodeVector(v, tilestore.DefaultIndex().Vector))
+	return stored
 }
 
 func redisTestClient() (*redis.Client, func()) {
@@ -203,6 +193,14 @@ func averageColor(img image.Image) [3]float64 {
 	return ImageAverageRGB(img)
 }
 
+func testImageData() []byte {
+	data, err := os.ReadFile(testImage200300)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return data
+}
+
 func testImage() image.Image {
 	file, err := os.Open(testImage200300)
 	if err != nil {
@@ -217,189 +215,6 @@ func testImage() image.Image {
 	return img
 }
 
-func Test_splitFields(t *testing.T) {
-	var tt = []struct {
-		name     string
-		input    string
-		expected []string
-	}{
-		{"",
-			"attributes:[] format:STRING results:[map[extra_attributes:map[average_color:Y�c�Pe�@\u001B�^)#@�@m{�Z.��@ img:/9j/2wCEAAgGBgcGBQgHBw] id:img:0.0.0.0:1 values:[]]] total_results:1 warning:[]",
-			[]string{
-				"attributes:[]",
-				"format:STRING",
-				"results:[map[extra_attributes:map[average_color:Y�c�Pe�@\u001B�^)#@�@m{�Z.��@ img:/9j/2wCEAAgGBgcGBQgHBw] id:img:0.0.0.0:1 values:[]]]",
-				"total_results:1",
-				"warning:[]",
-			},
-		},
-	}
-
-	for _, tc := range tt {
-		t.Run(tc.name, func(t *testing.T) {
-			res := make([]string, 0)
-			for f := range splitFields([]byte(tc.input)) {
-				res = append(res, string(f))
-			}
-
-			if len(res) != len(tc.expected) {
-				t.Errorf("wrong number of results, expected %d, got %d", len(tc.expected), len(res))
-			}
-
-			for i, e := range tc.expected {
-				if e != res[i] {
-					t.Errorf("got: %s, expected: %s", res[i], e)
-				}
-			}
-		})
-	}
-}
-
-func Test_Results(t *testing.T) {
-	var tt = []struct {
-		name     string
-		input    string
-		expected []string
-	}{
-		{"",
-			"[map[key1:value1 key2:value2] map[key3:value3] map[key4:value4] map[key5:value5] map[]]",
-			[]string{
-				"map[key1:value1 key2:value2]",
-				"map[key3:value3]",
-				"map[key4:value4]",
-				"map[key5:value5]",
-				"map[]",
-			},
-		},
-		{"",
-			"[map[extra_attributes:map[average_color:Y�c�Pe�@\u001B�^)#@�@m{�Z.��@ img:/9j/2wCEAAgGBgcGBQgHBw] id:img:0.0.0.0:1 field3:[]] map[extra_attributes:map[average_color:Y�c�Pe�@{�Z.��@ img:/9j/2wCEcGBQgHBwAAgGBgcGBQgHBw] id:img:0.0.0.0:2 field5:[]]]",
-			[]string{
-				"map[extra_attributes:map[average_color:Y�c�Pe�@\u001B�^)#@�@m{�Z.��@ img:/9j/2wCEAAgGBgcGBQgHBw] id:img:0.0.0.0:1 field3:[]]",
-				"map[extra_attributes:map[average_color:Y�c�Pe�@{�Z.��@ img:/9j/2wCEcGBQgHBwAAgGBgcGBQgHBw] id:img:0.0.0.0:2 field5:[]]",
-			},
-		},
-	}
-
-	for _, tc := range tt {
-		t.Run(tc.name, func(t *testing.T) {
-			res := RedisResults([]byte(tc.input))
-
-			if len(res) != len(tc.expected) {
-				t.Errorf("wrong number of results, expected %d, got %d", len(tc.expected), len(res))
-			}
-
-			for i, e := range tc.expected {
-				if res[i].Attributes != e {
-					t.Errorf("got: %s, expected: %s", res[i].Attributes, e)
-				}
-			}
-		})
-	}
-}
-
-func Test_resultsField(t *testing.T) {
-	var tt = []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{"",
-			"attributes:[] format:STRING results:[map[extra_attributes:map[average_color:Y�c�Pe�@\u001B�^)#@�@m{�Z.��@ img:/9j/2wCEAAgGBgcGBQgHBw] id:img:0.0.0.0:1 values:[]]] total_results:1 warning:[]",
-			"results:[map[extra_attributes:map[average_color:Y�c�Pe�@\u001B�^)#@�@m{�Z.��@ img:/9j/2wCEAAgGBgcGBQgHBw] id:img:0.0.0.0:1 values:[]]]",
-		},
-	}
-
-	for _, tc := range tt {
-		ss := make([]map[string]string, 0)
-		fmt.Println(ss)
-		t.Run(tc.name, func(t *testing.T) {
-			res, _ := redisResultsField([]byte(tc.input))
-			if len(res) != len(tc.expected) {
-				t.Errorf("wrong length of results slice, expected %d, got %d", len(tc.expected), len(res))
-			} else if len(res) > 0 {
-				for i := 0; i < len(tc.expected); i++ {
-					if tc.expected[i] != res[i] {
-						t.Errorf("actual and expected differ at position %d", i)
-					}
-				}
-			}
-		})
-	}
-}
-
-func Test_FindValueEnd(t *testing.T) {
-	var tt = []struct {
-		name                  string
-		inputString           string
-		valueStartIndex       int
-		expectedValueEndIndex int
-	}{
-		{"", "key1:value1 key2:value2", 5, 10},
-		{"", "key1:value1 key2:value2", 17, 22},
-		{"", "key1:value1 key2:[value2a, value2b]", 17, 34},
-		{"", "key1:value1", 5, 10},
-		//contains special characters, the 60th byte is not the 60th char.
-		{"", "[map[extra_attributes:map[average_color:Y�c�Pe�@\u001B�]]]", 0, 60},
-	}
-
-	for _, tc := range tt {
-		t.Run(tc.name, func(t *testing.T) {
-			end := findValueEnd([]byte(tc.inputString), tc.valueStartIndex)
-
-			if end != tc.expectedValueEndIndex {
-				t.Errorf("got %d, want %d", end, tc.expectedValueEndIndex)
-			}
-		})
-	}
-}
-
-func Test_FirstAvailableKeyValuePair(t *testing.T) {
-	var tt = []struct {
-		name                 string
-		input                string
-		expectedKeyValuePair string
-		expectedRemainder    string
-	}{
-		{"", "key1:value1 key2:value2", "key1:value1", "key2:value2"},
-		{"", "key1:value1 ", "key1:value1", ""},
-		{"", "key1:[value1, value1a, value1b] key2:value2", "key1:[value1, value1a, value1b]", "key2:value2"},
-		{"", "results:[map[extra_attributes:map[average_color:Y�c�Pe�@\u001B�^)#@�@m{�Z.��@ img:/9j/2wCEAAgGBgcGBQgHBw] id:img:0.0.0.0:1 values:[]]] total_results:1 warning:[]",
-			"results:[map[extra_attributes:map[average_color:Y�c�Pe�@\u001B�^)#@�@m{�Z.��@ img:/9j/2wCEAAgGBgcGBQgHBw] id:img:0.0.0.0:1 values:[]]]",
-			"total_results:1 warning:[]",
-		},
-		{"",
-			"results:[map[extra_attributes:map[] id:img:0.0.0.0:1 values:[]] map[extra_attributes:map[] id:img:0.0.0.0:1 values:[]]] total_results:1 warning:[]",
-			"results:[map[extra_attributes:map[] id:img:0.0.0.0:1 values:[]] map[extra_attributes:map[] id:img:0.0.0.0:1 values:[]]]",
-			"total_results:1 warning:[]",
-		},
-	}
-
-	for _, tc := range tt {
-		t.Run(tc.name, func(t *testing.T) {
-			pair, remainder := FirstInLineKeyValue([]byte(tc.input))
-			if len(pair) != len(tc.expectedKeyValuePair) {
-				t.Errorf("got KeyValue %s, want %s", pair, tc.expectedKeyValuePair)
-			} else if len(pair) > 0 {
-				for i := 0; i < len(pair); i++ {
-					if pair[i] != tc.expectedKeyValuePair[i] {
-						t.Errorf("difference in KeyValue at %d: got %s, want %s", i, pair, tc.expectedKeyValuePair)
-					}
-				}
-			}
-
-			if len(remainder) != len(tc.expectedRemainder) {
-				t.Errorf("got Remainder %s, want %s", remainder, tc.expectedRemainder)
-			} else if len(remainder) > 0 {
-				for i := 0; i < len(remainder); i++ {
-					if remainder[i] != tc.expectedRemainder[i] {
-						t.Errorf("difference in Remainder at %d: got %s, want %s", i, remainder, tc.expectedRemainder)
-					}
-				}
-			}
-		})
-	}
-}
-
 // Example of FTSearch result slice:
 
 //[
@@ -423,30 +238,26 @@ func Test_FirstAvailableKeyValuePair(t *testing.T) {
 func Test_RedisFTSearch(t *testing.T) {
 	redisClient, closer := redisTestClient()
 	defer closer()
-	indexPrefix := "img"
-	ip := "0.0.0.0"
-
-	indexName := "average_color_index"
+	set := tilestore.New(redisClient, "average_color_index")
 
-	index := NewRedisIndex(indexName, indexPrefix, redisClient)
-	index.FTCREATE()
+	index := NewRedisIndex(set)
+	if err := index.FTCREATE(context.Background()); err != nil {
+		t.Fatal(err)
+	}
 
 	testImg := testImage()
 
 	expectedAverageColorVector := averageColor(testImg)
 
-	expectedVectorBinaryRepresentation, err := binaryFloat64bit(expectedAverageColorVector)
-	if err != nil {
-		t.Errorf("Error calculating binary representation of expected average color: %v", err)
-	}
+	expectedVectorBinaryRepresentation := tilestore.EncodeVector(expectedAverageColorVector, set.Index.Vector)
 
-	err = SaveToRedis(testImg, redisClient, ip, indexPrefix, averageColor, context.Background())
+	err := SaveToRedis(testImageData(), set, averageColor, context.Background())
 	if err != nil {
 		t.Error(err)
 	}
 
 	//NOTE: Unstable command, should set the flag UnstableResp3.
-	FTSearch(expectedVectorBinaryRepresentation, redisClient, indexName, context.Background())
+	FTSearch(expectedVectorBinaryRepresentation, redisClient, set.Name, context.Background())
 }
 
 // FTSearch: Not runnable. Unstable redis command
@@ -473,3 +284,48 @@ func FTSearch(search []byte, c *redis.Client, indexName string, ctx context.Cont
 		fmt.Println(doc.Fields)
 	}
 }
+
+func Test_SaveVariants(t *testing.T) {
+	redisClient, closer := redisTestClient()
+	defer closer()
+
+	set := tilestore.New(redisClient, "0.0.0.0")
+	if err := NewRedisIndex(set).FTCREATE(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	parent := tilestore.Tile{Image: testImageData(), Vector: averageColor(testImage()), Source: "https://picsum.photos/id/1"}
+	key, err := SaveTile(context.Background(), set, parent, testImage(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// saving more variants only adds the ones missing
+	added, err := SaveVariants(context.Background(), set, key, parent, testImage(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 2 {
+		t.Errorf("expected 2 variants added, got %d", len(added))
+	}
+
+	variants, err := set.Variants(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := set.Records(context.Background(), variants)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transforms := make(map[string]bool)
+	for _, record := range records {
+		if record.Parent != key || record.Source != parent.Source {
+			t.Errorf("expected a variant of %s, got %+v", key, *record)
+		}
+		transforms[record.Transform] = true
+	}
+	if len(transforms) != 5 {
+		t.Errorf("expected 5 distinct variants, got %v", transforms)
+	}
+}
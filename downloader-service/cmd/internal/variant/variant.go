@@ -0,0 +1,173 @@
+// Package variant makes synthetic variants of tiles, recolored, reshaded or
+// flipped copies that let a small tile set cover more colors and repeat
+// itself less.
+//
+// Color transforms work on 8 bit sRGB values with the matrices of the CSS
+// filter effects, alpha is kept as is.
+package variant
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/ChrisShia/tilestore/pixel"
+)
+
+// Transform makes a variant of a tile, Name tells it apart from the other
+// variants of the tile.
+type Transform struct {
+	Name  string
+	Apply func(img image.Image) *image.NRGBA
+}
+
+// Transforms are the transforms variants are made with, the ones that move
+// the average color the farthest first so that capping the number of
+// variants keeps the most useful ones.
+var Transforms = []Transform{
+	{"hue120", colorMatrix(hueRotation(120))},
+	{"hue240", colorMatrix(hueRotation(240))},
+	{"grayscale", colorMatrix(grayscale)},
+	{"sepia", colorMatrix(sepia)},
+	{"brighter", linear(1.3, 0)},
+	{"darker", linear(0.7, 0)},
+	{"contrast", contrast(1.5)},
+	{"flat", contrast(0.6)},
+	{"hue60", colorMatrix(hueRotation(60))},
+	{"hue300", colorMatrix(hueRotation(300))},
+	{"fliph", flip(true)},
+	{"flipv", flip(false)},
+}
+
+// Select returns the first n transforms not named in skip, the ones a tile
+// already has variants of, none when n is not positive.
+func Select(n int, skip []string) []Transform {
+	n = max(n, 0)
+
+	skipped := make(map[string]bool, len(skip))
+	for _, name := range skip {
+		skipped[name] = true
+	}
+
+	selected := make([]Transform, 0, n)
+	for _, t := range Transforms {
+		if len(selected) == n {
+			break
+		}
+		if !skipped[t.Name] {
+			selected = append(selected, t)
+		}
+	}
+
+	return selected
+}
+
+type matrix [3][3]float64
+
+var (
+	grayscale = matrix{
+		{0.2126, 0.7152, 0.0722},
+		{0.2126, 0.7152, 0.0722},
+		{0.2126, 0.7152, 0.0722},
+	}
+	sepia = matrix{
+		{0.393, 0.769, 0.189},
+		{0.349, 0.686, 0.168},
+		{0.272, 0.534, 0.131},
+	}
+)
+
+// hueRotation rotates hues by deg degrees, keeping luminance.
+func hueRotation(deg float64) matrix {
+	c, s := math.Cos(deg*math.Pi/180), math.Sin(deg*math.Pi/180)
+	return matrix{
+		{0.213 + c*0.787 - s*0.213, 0.715 - c*0.715 - s*0.715, 0.072 - c*0.072 + s*0.928},
+		{0.213 - c*0.213 + s*0.143, 0.715 + c*0.285 + s*0.140, 0.072 - c*0.072 - s*0.283},
+		{0.213 - c*0.213 - s*0.787, 0.715 - c*0.715 + s*0.715, 0.072 + c*0.928 + s*0.072},
+	}
+}
+
+func colorMatrix(m matrix) func(image.Image) *image.NRGBA {
+	return perPixel(func(c [3]float64) [3]float64 {
+		var out [3]float64
+		for i := range out {
+			out[i] = m[i][0]*c[0] + m[i][1]*c[1] + m[i][2]*c[2]
+		}
+		return out
+	})
+}
+
+// linear scales every channel by slope and adds intercept, from 0 to 255.
+func linear(slope, intercept float64) func(image.Image) *image.NRGBA {
+	return perPixel(func(c [3]float64) [3]float64 {
+		for i := range c {
+			c[i] = slope*c[i] + intercept
+		}
+		return c
+	})
+}
+
+// contrast scales the channels around their middle, flattening the tile
+// below 1.
+func contrast(amount float64) func(image.Image) *image.NRGBA {
+	return linear(amount, 127.5*(1-amount))
+}
+
+// perPixel applies f to the unpremultiplied channels of every pixel, from 0
+// to 255, and clamps the results.
+func perPixel(f func([3]float64) [3]float64) func(image.Image) *image.NRGBA {
+	return func(img image.Image) *image.NRGBA {
+		r := img.Bounds()
+		out := image.NewNRGBA(r)
+
+		row := make([]uint16, r.Dx()*4)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			pixel.Row(img, y, r.Min.X, r.Max.X, row)
+			dst := out.Pix[out.PixOffset(r.Min.X, y):]
+			for i := 0; i < len(row); i += 4 {
+				a := row[i+3]
+				if a == 0 {
+					continue
+				}
+
+				c := color.NRGBAModel.Convert(color.RGBA64{R: row[i], G: row[i+1], B: row[i+2], A: a}).(color.NRGBA)
+				v := f([3]float64{float64(c.R), float64(c.G), float64(c.B)})
+				dst[i], dst[i+1], dst[i+2], dst[i+3] = clamp(v[0]), clamp(v[1]), clamp(v[2]), c.A
+			}
+		}
+
+		return out
+	}
+}
+
+// flip mirrors the tile left to right, or top to bottom.
+func flip(horizontal bool) func(image.Image) *image.NRGBA {
+	return func(img image.Image) *image.NRGBA {
+		r := img.Bounds()
+		out := image.NewNRGBA(r)
+
+		row := make([]uint16, r.Dx()*4)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			pixel.Row(img, y, r.Min.X, r.Max.X, row)
+
+			dy := y
+			if !horizontal {
+				dy = r.Max.Y - 1 - (y - r.Min.Y)
+			}
+			for x := 0; x < r.Dx(); x++ {
+				dx := r.Min.X + x
+				if horizontal {
+					dx = r.Max.X - 1 - x
+				}
+				p := row[x*4:]
+				out.Set(dx, dy, color.RGBA64{R: p[0], G: p[1], B: p[2], A: p[3]})
+			}
+		}
+
+		return out
+	}
+}
+
+func clamp(v float64) uint8 {
+	return uint8(math.Round(min(max(v, 0), 255)))
+}
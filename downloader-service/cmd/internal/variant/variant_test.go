@@ -0,0 +1,93 @@
+package variant
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func Test_Select(t *testing.T) {
+	var tt = []struct {
+		name     string
+		n        int
+		skip     []string
+		expected []string
+	}{
+		{"first", 3, nil, []string{"hue120", "hue240", "grayscale"}},
+		{"skip", 3, []string{"hue240", "sepia"}, []string{"hue120", "grayscale", "brighter"}},
+		{"none", -1, nil, []string{}},
+		{"all", 100, nil, names(Transforms)},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := names(Select(tc.n, tc.skip))
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_Transforms(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 200, G: 40, B: 40, A: 255})
+	img.SetNRGBA(1, 0, color.NRGBA{R: 10, G: 20, B: 30, A: 255})
+	img.SetNRGBA(0, 1, color.NRGBA{R: 100, G: 150, B: 200, A: 128})
+
+	var tt = []struct {
+		name string
+		// at is the pixel of the variant read, expected its color
+		at       image.Point
+		expected color.NRGBA
+	}{
+		// red turned green, clamped
+		{"hue120", image.Pt(0, 0), color.NRGBA{G: 111, A: 255}},
+		{"grayscale", image.Pt(0, 0), color.NRGBA{R: 74, G: 74, B: 74, A: 255}},
+		{"brighter", image.Pt(0, 0), color.NRGBA{R: 255, G: 52, B: 52, A: 255}},
+		{"darker", image.Pt(1, 0), color.NRGBA{R: 7, G: 14, B: 21, A: 255}},
+		{"flat", image.Pt(1, 0), color.NRGBA{R: 57, G: 63, B: 69, A: 255}},
+		// translucent pixels keep their alpha, transparent ones stay so
+		{"contrast", image.Pt(0, 1), color.NRGBA{R: 86, G: 161, B: 236, A: 128}},
+		{"sepia", image.Pt(1, 1), color.NRGBA{}},
+		{"fliph", image.Pt(1, 0), color.NRGBA{R: 200, G: 40, B: 40, A: 255}},
+		{"flipv", image.Pt(0, 0), color.NRGBA{R: 100, G: 150, B: 200, A: 128}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			transform := transform(t, tc.name)
+			actual := transform.Apply(img).NRGBAAt(tc.at.X, tc.at.Y)
+			if !near(actual, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func transform(t *testing.T, name string) Transform {
+	for _, tr := range Transforms {
+		if tr.Name == name {
+			return tr
+		}
+	}
+	t.Fatalf("no transform %s", name)
+	return Transform{}
+}
+
+func names(transforms []Transform) []string {
+	n := make([]string, len(transforms))
+	for i, t := range transforms {
+		n[i] = t.Name
+	}
+	return n
+}
+
+// near compares colors allowing for the rounding of translucent pixels.
+func near(a, b color.NRGBA) bool {
+	d := func(x, y uint8) bool {
+		return x-y <= 1 || y-x <= 1
+	}
+	return d(a.R, b.R) && d(a.G, b.G) && d(a.B, b.B) && a.A == b.A
+}
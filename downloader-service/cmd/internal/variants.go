@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"downloader/cmd/internal/variant"
+	"github.com/ChrisShia/tilestore"
+)
+
+// variantQuality is the JPEG quality opaque variants are encoded at, the
+// others are encoded as PNG to keep their alpha.
+const variantQuality = 90
+
+// SaveTile stores t, img being its decoded image, and up to variants
+// variants of it.
+func SaveTile(ctx context.Context, set *tilestore.Set, t tilestore.Tile, img image.Image, variants int) (string, error) {
+	key, err := set.Add(ctx, t)
+	if err != nil || variants <= 0 {
+		return key, err
+	}
+
+	_, err = SaveVariants(ctx, set, key, t, img, variants)
+	return key, err
+}
+
+// SaveVariants stores variants of the tile parent stored under key, img being
+// its decoded image, until it has n. Variants keep the source and author of
+// their parent and the transforms the tile has variants of already are not
+// applied again, so saving variants twice does not duplicate them. It
+// returns the keys of the variants stored.
+func SaveVariants(ctx context.Context, set *tilestore.Set, key string, parent tilestore.Tile, img image.Image, n int) ([]string, error) {
+	existing, err := set.Variants(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	records, err := set.Records(ctx, existing)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := make([]string, 0, len(records))
+	for _, record := range records {
+		if record != nil {
+			skip = append(skip, record.Transform)
+		}
+	}
+
+	keys := make([]string, 0, n)
+	for _, t := range variant.Select(n-len(skip), skip) {
+		v := t.Apply(img)
+
+		var buf bytes.Buffer
+		if v.Opaque() {
+			err = jpeg.Encode(&buf, v, &jpeg.Options{Quality: variantQuality})
+		} else {
+			err = png.Encode(&buf, v)
+		}
+		if err != nil {
+			return keys, err
+		}
+
+		variantKey, err := set.Add(ctx, tilestore.Tile{
+			Image:     buf.Bytes(),
+			Vector:    ImageAverageRGB(v),
+			Source:    parent.Source,
+			Author:    parent.Author,
+			Parent:    key,
+			Transform: t.Name,
+		})
+		if err != nil {
+			return keys, err
+		}
+		keys = append(keys, variantKey)
+	}
+
+	return keys, nil
+}
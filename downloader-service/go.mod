@@ -4,6 +4,7 @@ go 1.25
 
 require (
 	github.com/ChrisShia/jsonlog v0.0.0-20251003103116-b71c25828da7
+	github.com/ChrisShia/tilestore v0.0.0
 	github.com/nats-io/nats-server/v2 v2.12.1
 	github.com/nats-io/nats.go v1.47.0
 	github.com/redis/go-redis/v9 v9.16.0
@@ -74,3 +75,5 @@ require (
 	golang.org/x/time v0.14.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/ChrisShia/tilestore => ../tilestore
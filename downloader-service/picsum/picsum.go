@@ -19,6 +19,32 @@ func Random200300() *http.Request {
 	}
 }
 
+// Variant alters the pictures picsum serves: Grayscale desaturates them and
+// Blur, from 1 to 10, blurs them, 0 leaving them sharp.
+type Variant struct {
+	Grayscale bool
+	Blur      int
+}
+
+// Query is the query string of v, empty for the plain pictures.
+func (v Variant) Query() string {
+	var params []string
+	if v.Grayscale {
+		params = append(params, "grayscale")
+	}
+	if v.Blur > 0 {
+		params = append(params, "blur="+strconv.Itoa(min(v.Blur, 10)))
+	}
+	return strings.Join(params, "&")
+}
+
+// Random200300Variant is Random200300 for the pictures of variant v.
+func Random200300Variant(v Variant) *http.Request {
+	req := Random200300()
+	req.URL.RawQuery = v.Query()
+	return req
+}
+
 type PicUrlParameters struct {
 	X          int
 	Y          int
@@ -40,6 +40,32 @@ func Test_AttributesString(t *testing.T) {
 	}
 }
 
+func Test_VariantQuery(t *testing.T) {
+	var tt = []struct {
+		variant  Variant
+		expected string
+	}{
+		{Variant{}, ""},
+		{Variant{Grayscale: true}, "grayscale"},
+		{Variant{Blur: 2}, "blur=2"},
+		{Variant{Grayscale: true, Blur: 12}, "grayscale&blur=10"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.expected, func(t *testing.T) {
+			actual := tc.variant.Query()
+			if actual != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+
+	req := Random200300Variant(Variant{Grayscale: true})
+	if actual := req.URL.String(); actual != "https://picsum.photos/200/300?grayscale" {
+		t.Errorf("expected the grayscale url, got %s", actual)
+	}
+}
+
 func a(s ...string) Attributes {
 	m := make(map[string]string)
 	for i := 0; i < len(s); i = i + 2 {
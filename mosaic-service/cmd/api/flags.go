@@ -1,10 +1,23 @@
 package main
 
-import "flag"
+import (
+	"flag"
+	"time"
+
+	"github.com/ChrisShia/mosaic/cmd/internal"
+)
 
 func (c *Config) flags() {
 	flag.IntVar(&c.Port, "p", 80, "port to listen on")
 	flag.StringVar(&c.Redis.Addr, "redis", "redis://localhost:6378", "redis server URL")
+	flag.IntVar(&c.Redis.BatchSize, "lookup-batch", internal.DefaultBatchSize, "KNN queries sent per redis pipeline")
+	flag.IntVar(&c.Redis.Concurrency, "lookup-concurrency", internal.DefaultConcurrency, "redis pipelines in flight per mosaic")
+	flag.DurationVar(&c.Redis.ReadyTimeout, "index-ready-timeout", 5*time.Second, "wait for redis to index a tile set before rendering from it")
+	flag.StringVar(&c.TilesDir, "tiles-dir", "", "directory of tile images to render from, in memory and without redis")
+	flag.IntVar(&c.BandHeight, "band-height", 256, "rows of the bands streamed mosaics are rendered in")
+	flag.StringVar(&c.PyramidDir, "pyramid-dir", "pyramids", "directory deep zoom pyramids are written to and served from")
+	flag.IntVar(&c.TileCacheMB, "tile-cache-mb", 64, "memory of the resized tile cache in MiB (0 = disabled)")
+	flag.IntVar(&c.Workers, "workers", 0, "goroutines rendering a mosaic (0 = GOMAXPROCS)")
 
 	flag.Parse()
 }
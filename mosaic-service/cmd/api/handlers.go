@@ -1,17 +1,38 @@
 package main
 
 import (
+	"cmp"
 	"encoding/json"
+	"errors"
+	"image"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/ChrisShia/mosaic/cmd/internal"
+	"github.com/ChrisShia/mosaic/cmd/internal/mosaic"
+	"github.com/ChrisShia/mosaic/cmd/internal/pyramid"
+	"github.com/ChrisShia/tilestore"
 )
 
 func (app *App) createMosaicHandler(writer http.ResponseWriter, request *http.Request) {
 	var input struct {
-		IP        string `json:"ip"`
-		TileWidth int    `json:"tile_width"`
-		Original  string `json:"original"`
+		IP          string  `json:"ip"`
+		TileWidth   int     `json:"tile_width"`
+		TileHeight  int     `json:"tile_height,omitempty"`
+		Shape       string  `json:"shape,omitempty"`
+		Edge        string  `json:"edge,omitempty"`
+		OutputScale float64 `json:"output_scale,omitempty"`
+		OutputWidth int     `json:"output_width,omitempty"`
+		Resample    string  `json:"resample,omitempty"`
+		Background  string  `json:"background,omitempty"`
+		Index       string  `json:"index,omitempty"`
+		Output      string  `json:"output,omitempty"`
+		Placements  bool    `json:"placements,omitempty"`
+		Metrics     bool    `json:"metrics,omitempty"`
+		Original    string  `json:"original"`
+
+		ExcludeVariants bool `json:"exclude_variants,omitempty"`
 	}
 
 	decoder := json.NewDecoder(request.Body)
@@ -21,35 +42,136 @@ func (app *App) createMosaicHandler(writer http.ResponseWriter, request *http.Re
 		app.logger.PrintError(err, nil)
 	}
 
-	//TODO: this should get the index if it exists
-	redisIndex := internal.NewRedisIndex(input.IP, redisIndexPrefix(input.IP), app.redisClient)
-
 	originalImg, err := internal.Base64StringToImage(input.Original)
 	if err != nil {
 		app.logger.PrintError(err, nil)
 		return
 	}
-	b := NewMosaicBuilder(redisIndex, originalImg, input.TileWidth)
+	if input.TileHeight == 0 {
+		input.TileHeight = input.TileWidth
+	}
 
-	mosaicImg, err := b.Mosaic()
+	cellShape, err := mosaic.ParseShape(input.Shape)
 	if err != nil {
-		app.logger.PrintError(err, nil)
+		app.badRequestResponse(writer, request, err)
 		return
 	}
 
-	base64StringImg, err := internal.ImageToBase64String(mosaicImg)
+	edge, err := mosaic.ParseEdgePolicy(input.Edge)
 	if err != nil {
-		app.logger.PrintError(err, nil)
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	filter, err := mosaic.ParseFilter(input.Resample)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	background, err := mosaic.ParseBackground(input.Background)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	output, err := parseOutput(input.Output)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	//TODO: this should get the index if it exists
+	tiles, err := app.tileRepository(request.Context(), input.IP, input.Index, input.ExcludeVariants)
+	if err != nil {
+		app.tileRepositoryErrorResponse(writer, request, err)
+		return
+	}
+
+	b, err := mosaic.NewBuilder(tiles, originalImg, mosaic.Options{
+		TileSize:    image.Pt(input.TileWidth, input.TileHeight),
+		Shape:       cellShape,
+		Edge:        edge,
+		Scale:       input.OutputScale,
+		OutputWidth: input.OutputWidth,
+		Filter:      filter,
+		Background:  background,
+		Workers:     app.cfg.Workers,
+		Cache:       app.tileCache,
+	})
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
 		return
 	}
 
 	var response struct {
-		Error  bool   `json:"error"`
-		Mosaic string `json:"mosaic"`
+		Error      bool               `json:"error"`
+		Mosaic     string             `json:"mosaic,omitempty"`
+		Pyramid    *pyramidResult     `json:"pyramid,omitempty"`
+		Placements []mosaic.Placement `json:"placements,omitempty"`
+		Metrics    *mosaic.Quality    `json:"metrics,omitempty"`
+	}
+
+	start := time.Now()
+	switch output {
+	case outputJSON:
+		var mosaicImg image.Image
+		if mosaicImg, err = b.Mosaic(); err == nil {
+			response.Mosaic, err = internal.ImageToBase64String(mosaicImg)
+		}
+	case outputPNG:
+		err = app.streamPNG(writer, b, app.cfg.BandHeight)
+	default:
+		response.Pyramid, err = app.writePyramid(b, pyramid.Format(output))
+	}
+	if err != nil {
+		app.logger.PrintError(err, nil)
+		app.errorResponse(writer, request, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	report := map[string]string{
+		"ip":        input.IP,
+		"output":    cmp.Or(string(output), "json"),
+		"width":     strconv.Itoa(b.Size().X),
+		"height":    strconv.Itoa(b.Size().Y),
+		"cells":     strconv.Itoa(b.Cells()),
+		"render_ms": strconv.FormatInt(time.Since(start).Milliseconds(), 10),
+	}
+
+	if input.Metrics {
+		q, err := b.Quality()
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		} else {
+			response.Metrics = &q
+			report["psnr"] = strconv.FormatFloat(q.PSNR, 'f', 2, 64)
+			report["ssim"] = strconv.FormatFloat(q.SSIM, 'f', 4, 64)
+			report["delta_e_mean"] = strconv.FormatFloat(q.DeltaE.Mean, 'f', 2, 64)
+			report["delta_e_max"] = strconv.FormatFloat(q.DeltaE.Max, 'f', 2, 64)
+		}
+	}
+
+	app.logger.PrintInfo("Mosaic report", report)
+
+	if app.tileCache != nil {
+		stats := app.tileCache.Stats()
+		app.logger.PrintInfo("Tile cache", map[string]string{
+			"hits":    strconv.FormatInt(stats.Hits, 10),
+			"misses":  strconv.FormatInt(stats.Misses, 10),
+			"entries": strconv.Itoa(stats.Entries),
+			"bytes":   strconv.FormatInt(stats.Bytes, 10),
+		})
+	}
+
+	if output == outputPNG {
+		return
 	}
 
-	response.Mosaic = base64StringImg
 	response.Error = false
+	if input.Placements {
+		response.Placements = b.Placements()
+	}
 
 	writer.Header().Set("Content-Type", "application/json")
 	marshalledResponse, err := json.Marshal(response)
@@ -63,3 +185,145 @@ func (app *App) createMosaicHandler(writer http.ResponseWriter, request *http.Re
 		app.logger.PrintError(err, nil)
 	}
 }
+
+// cellsHandler counts the cells of the mosaic of an original of the given
+// size, so that callers budgeting tiles need neither send the original nor
+// know the layouts.
+func (app *App) cellsHandler(writer http.ResponseWriter, request *http.Request) {
+	var input struct {
+		Width      int    `json:"width"`
+		Height     int    `json:"height"`
+		TileWidth  int    `json:"tile_width"`
+		TileHeight int    `json:"tile_height,omitempty"`
+		Shape      string `json:"shape,omitempty"`
+		Edge       string `json:"edge,omitempty"`
+	}
+
+	err := json.NewDecoder(request.Body).Decode(&input)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	if input.TileHeight == 0 {
+		input.TileHeight = input.TileWidth
+	}
+
+	cellShape, err := mosaic.ParseShape(input.Shape)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	edge, err := mosaic.ParseEdgePolicy(input.Edge)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	cells, err := mosaic.CountCells(image.Pt(input.Width, input.Height), mosaic.Options{
+		TileSize: image.Pt(input.TileWidth, input.TileHeight),
+		Shape:    cellShape,
+		Edge:     edge,
+	})
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"cells": cells}, nil)
+	if err != nil {
+		app.logger.PrintError(err, nil)
+	}
+}
+
+// coverageHandler reports how well the tile set of the client covers the
+// colors of an original, cut into cells as a mosaic would be, without
+// rendering it.
+func (app *App) coverageHandler(writer http.ResponseWriter, request *http.Request) {
+	var input struct {
+		IP         string `json:"ip"`
+		TileWidth  int    `json:"tile_width"`
+		TileHeight int    `json:"tile_height,omitempty"`
+		Shape      string `json:"shape,omitempty"`
+		Edge       string `json:"edge,omitempty"`
+		Index      string `json:"index,omitempty"`
+		Original   string `json:"original"`
+
+		ExcludeVariants bool `json:"exclude_variants,omitempty"`
+	}
+
+	err := json.NewDecoder(request.Body).Decode(&input)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	originalImg, err := internal.Base64StringToImage(input.Original)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+	if input.TileHeight == 0 {
+		input.TileHeight = input.TileWidth
+	}
+
+	cellShape, err := mosaic.ParseShape(input.Shape)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	edge, err := mosaic.ParseEdgePolicy(input.Edge)
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	tiles, err := app.tileRepository(request.Context(), input.IP, input.Index, input.ExcludeVariants)
+	if err != nil {
+		app.tileRepositoryErrorResponse(writer, request, err)
+		return
+	}
+
+	b, err := mosaic.NewBuilder(tiles, originalImg, mosaic.Options{
+		TileSize: image.Pt(input.TileWidth, input.TileHeight),
+		Shape:    cellShape,
+		Edge:     edge,
+		Workers:  app.cfg.Workers,
+		Cache:    app.tileCache,
+	})
+	if err != nil {
+		app.badRequestResponse(writer, request, err)
+		return
+	}
+
+	report, err := b.Coverage()
+	if err != nil {
+		app.logger.PrintError(err, nil)
+		app.errorResponse(writer, request, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	err = app.writeJSON(writer, http.StatusOK, envelope{"coverage": report}, nil)
+	if err != nil {
+		app.logger.PrintError(err, nil)
+	}
+}
+
+// tileRepositoryErrorResponse responds with the error met opening the tile
+// set of a request.
+func (app *App) tileRepositoryErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidIndex), errors.Is(err, tilestore.ErrInvalidName):
+		app.badRequestResponse(w, r, err)
+	case errors.Is(err, tilestore.ErrNoTileSet):
+		app.errorResponse(w, r, http.StatusNotFound, err.Error())
+	case errors.Is(err, tilestore.ErrNoIndex), errors.Is(err, tilestore.ErrIndexNotReady),
+		errors.Is(err, tilestore.ErrOutdatedSchema):
+		app.errorResponse(w, r, http.StatusServiceUnavailable, err.Error())
+	default:
+		app.logger.PrintError(err, nil)
+		app.errorResponse(w, r, http.StatusInternalServerError, err.Error())
+	}
+}
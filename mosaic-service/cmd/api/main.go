@@ -1,11 +1,13 @@
 package main
 
 import (
-	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/ChrisShia/jsonlog"
+	"github.com/ChrisShia/mosaic/cmd/internal"
+	"github.com/ChrisShia/mosaic/cmd/internal/mosaic"
 	"github.com/ChrisShia/serve"
 	"github.com/redis/go-redis/v9"
 )
@@ -13,8 +15,37 @@ import (
 type Config struct {
 	Port int
 
+	// Workers is the number of goroutines rendering a mosaic, GOMAXPROCS
+	// when zero.
+	Workers int
+
+	// TileCacheMB is the memory, in MiB, of the cache of resized tiles
+	// shared by all renders, zero disables it.
+	TileCacheMB int
+
+	// BandHeight is the height, in rows, of the bands mosaics streamed to a
+	// PNG or a pyramid are rendered in.
+	BandHeight int
+
+	// PyramidDir is the directory deep zoom pyramids are written to and
+	// served from.
+	PyramidDir string
+
+	// TilesDir, when set, is a directory of tile images loaded in memory at
+	// start up and used for every mosaic instead of redis.
+	TilesDir string
+
 	Redis struct {
 		Addr string
+
+		// BatchSize is the number of KNN queries pipelined together and
+		// Concurrency the number of pipelines in flight per mosaic.
+		BatchSize   int
+		Concurrency int
+
+		// ReadyTimeout bounds the wait for redis to finish indexing a tile
+		// set before rendering from it.
+		ReadyTimeout time.Duration
 	}
 
 	mode mode
@@ -31,6 +62,15 @@ type App struct {
 	logger      *jsonlog.Logger
 	cfg         Config
 	redisClient *redis.Client
+
+	// offlineTiles is the tile set loaded from Config.TilesDir.
+	offlineTiles *internal.MemoryIndex
+
+	// memoryIndexes holds the tile sets copied from redis by the "memory"
+	// index.
+	memoryIndexes memoryIndexes
+
+	tileCache *mosaic.TileCache
 }
 
 func main() {
@@ -43,6 +83,10 @@ func main() {
 		cfg:    cfg,
 	}
 
+	if cfg.TileCacheMB > 0 {
+		app.tileCache = mosaic.NewTileCache(int64(cfg.TileCacheMB) << 20)
+	}
+
 	closerFunc, err := app.setupTileImageRepository()
 	if err != nil {
 		app.logger.PrintError(err, nil)
@@ -61,10 +105,9 @@ func (app *App) Routes() http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/create", app.createMosaicHandler)
+	mux.HandleFunc("/coverage", app.coverageHandler)
+	mux.HandleFunc("/cells", app.cellsHandler)
+	mux.Handle("/pyramids/", app.pyramidsHandler())
 
 	return mux
 }
-
-func redisIndexPrefix(ip string) string {
-	return fmt.Sprintf("img:%s", ip)
-}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"image"
+	"net/http"
+
+	"github.com/ChrisShia/mosaic/cmd/internal/mosaic"
+	"github.com/ChrisShia/mosaic/cmd/internal/pngstream"
+	"github.com/ChrisShia/mosaic/cmd/internal/pyramid"
+)
+
+// output is where a mosaic is rendered to.
+type output string
+
+const (
+	// outputJSON renders the whole mosaic and returns it base64 encoded in
+	// the JSON response.
+	outputJSON output = ""
+
+	// outputPNG renders the mosaic in bands and streams it as the PNG body
+	// of the response, placements are not returned.
+	outputPNG output = "png"
+
+	// outputDZI and outputXYZ render the mosaic in bands into a pyramid of
+	// that format, served by pyramidsHandler.
+	outputDZI = output(pyramid.DZI)
+	outputXYZ = output(pyramid.XYZ)
+)
+
+// parseOutput reads the output of a request, the JSON response when unset.
+func parseOutput(s string) (output, error) {
+	switch o := output(s); o {
+	case outputJSON, outputPNG:
+		return o, nil
+	}
+
+	f, err := pyramid.ParseFormat(s)
+	return output(f), err
+}
+
+// streamPNG renders the mosaic of b in bands of bandHeight rows, encoding each
+// to the response as it is rendered. Errors met before the first band are
+// returned for the caller to respond with, the response is aborted on those
+// met after it.
+func (app *App) streamPNG(w http.ResponseWriter, b *mosaic.Builder, bandHeight int) error {
+	var e *pngstream.Encoder
+
+	err := b.Bands(bandHeight, func(band *image.NRGBA) error {
+		if e == nil {
+			w.Header().Set("Content-Type", "image/png")
+
+			var err error
+			if e, err = pngstream.NewEncoder(w, b.Size()); err != nil {
+				return err
+			}
+		}
+
+		return e.WriteRows(band)
+	})
+	if err == nil {
+		err = e.Close()
+	}
+	if err != nil && e != nil {
+		app.logger.PrintError(err, nil)
+		panic(http.ErrAbortHandler)
+	}
+
+	return err
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ChrisShia/mosaic/cmd/internal"
+	"github.com/ChrisShia/mosaic/cmd/internal/mosaic"
+	"github.com/ChrisShia/mosaic/cmd/internal/pyramid"
+)
+
+func Test_parseOutput(t *testing.T) {
+	var tt = []struct {
+		name     string
+		expected output
+		err      error
+	}{
+		{"", outputJSON, nil},
+		{"png", outputPNG, nil},
+		{"dzi", outputDZI, nil},
+		{"xyz", outputXYZ, nil},
+		{"tiff", "", pyramid.ErrUnknownFormat},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			o, err := parseOutput(tc.name)
+			if !errors.Is(err, tc.err) || o != tc.expected {
+				t.Errorf("expected %q (%v), got %q (%v)", tc.expected, tc.err, o, err)
+			}
+		})
+	}
+}
+
+func Test_streamPNG(t *testing.T) {
+	original := gradient(image.Rect(0, 0, 60, 40))
+	opts := mosaic.Options{TileSize: image.Pt(10, 10), Shape: mosaic.ShapeRect, Scale: 1.5}
+
+	b, err := mosaic.NewBuilder(&texturedTileRepository{}, original, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := b.Mosaic()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err = mosaic.NewBuilder(&texturedTileRepository{}, original, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &App{}
+	rec := httptest.NewRecorder()
+	if err = app.streamPNG(rec, b, 16); err != nil {
+		t.Fatal(err)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("expected an image/png response, got %q", ct)
+	}
+
+	streamed, err := png.Decode(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streamed.Bounds() != expected.Bounds() {
+		t.Fatalf("expected bounds %v, got %v", expected.Bounds(), streamed.Bounds())
+	}
+	for y := 0; y < expected.Bounds().Dy(); y++ {
+		for x := 0; x < expected.Bounds().Dx(); x++ {
+			if c, e := color.NRGBAModel.Convert(streamed.At(x, y)), color.NRGBAModel.Convert(expected.At(x, y)); c != e {
+				t.Fatalf("(%d,%d): expected %v, got %v", x, y, e, c)
+			}
+		}
+	}
+}
+
+// Test_streamPNGLookupError responds with the errors met before streaming.
+func Test_streamPNGLookupError(t *testing.T) {
+	b, err := mosaic.NewBuilder(&panickingTileRepository{}, gradient(image.Rect(0, 0, 40, 40)), mosaic.Options{TileSize: image.Pt(10, 10), Shape: mosaic.ShapeRect})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	if err = (&App{}).streamPNG(rec, b, 16); err == nil {
+		t.Fatal("expected the lookup error")
+	}
+	if rec.Body.Len() != 0 || rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "" {
+		t.Errorf("expected nothing written, got %d bytes", rec.Body.Len())
+	}
+}
+
+// texturedTileRepository answers every lookup with the same gradient, for
+// tiles whose pixels differ across their cells.
+type texturedTileRepository struct{}
+
+func (r *texturedTileRepository) Tile(ac [3]float64) (internal.Tile, image.Image, error) {
+	return internal.Tile{Descriptor: ac}, gradient(image.Rect(0, 0, 30, 30)), nil
+}
+
+// panickingTileRepository panics on every lookup.
+type panickingTileRepository struct{}
+
+func (r *panickingTileRepository) Tile([3]float64) (internal.Tile, image.Image, error) {
+	panic("tile repository failure")
+}
+
+// gradient returns an opaque image with the given bounds whose red and green
+// channels grow along x and y, so every cell averages to a different color.
+func gradient(r image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(255 * (x - r.Min.X) / r.Dx()),
+				G: uint8(255 * (y - r.Min.Y) / r.Dy()),
+				B: uint8((x * y) % 256),
+				A: 0xff,
+			})
+		}
+	}
+	return img
+}
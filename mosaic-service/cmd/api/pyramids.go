@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ChrisShia/mosaic/cmd/internal/mosaic"
+	"github.com/ChrisShia/mosaic/cmd/internal/pyramid"
+)
+
+// pyramidPrefix is the prefix of the directories of the pyramids being
+// written, hidden from pyramidsHandler until they are complete.
+const pyramidPrefix = ".pyramid-"
+
+// pyramidResult is a pyramid written to the store, URL is its entry point
+// as served by pyramidsHandler.
+type pyramidResult struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	pyramid.Pyramid
+}
+
+// writePyramid renders the mosaic of b in bands into a pyramid in the format
+// f, in a new directory of Config.PyramidDir. It is written under a hidden
+// name first and renamed once complete, so that viewers never load a
+// partial pyramid.
+func (app *App) writePyramid(b *mosaic.Builder, f pyramid.Format) (*pyramidResult, error) {
+	if err := os.MkdirAll(app.cfg.PyramidDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.MkdirTemp(app.cfg.PyramidDir, pyramidPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := writePyramidBands(tmp, b, f, app.cfg.BandHeight, app.cfg.Workers)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+
+	id := strings.TrimPrefix(filepath.Base(tmp), pyramidPrefix)
+	if err = os.Rename(tmp, filepath.Join(app.cfg.PyramidDir, id)); err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+
+	return &pyramidResult{
+		ID:      id,
+		URL:     path.Join("/pyramids", id, p.Path),
+		Pyramid: p,
+	}, nil
+}
+
+// writePyramidBands renders the mosaic of b to a pyramid in dir, band by band.
+func writePyramidBands(dir string, b *mosaic.Builder, f pyramid.Format, bandHeight, workers int) (pyramid.Pyramid, error) {
+	w, err := pyramid.NewWriter(dir, b.Size(), f, workers)
+	if err != nil {
+		return pyramid.Pyramid{}, err
+	}
+
+	if err = b.Bands(bandHeight, w.WriteRows); err != nil {
+		return pyramid.Pyramid{}, err
+	}
+
+	return w.Close()
+}
+
+// pyramidsHandler serves the files of the pyramids under /pyramids/. Written
+// pyramids never change, so they are cached for good, and they are fetched by
+// viewers embedded in other sites. Directories are not listed.
+func (app *App) pyramidsHandler() http.Handler {
+	files := http.StripPrefix("/pyramids/", http.FileServer(http.Dir(app.cfg.PyramidDir)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") || strings.Contains(r.URL.Path, "/.") {
+			app.errorResponse(w, r, http.StatusNotFound, "the requested resource could not be found")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ChrisShia/mosaic/cmd/internal/mosaic"
+	"github.com/ChrisShia/mosaic/cmd/internal/pyramid"
+)
+
+// Test_pyramidsHandler writes a pyramid and fetches its entry point and a
+// tile, but neither directories nor pyramids being written.
+func Test_pyramidsHandler(t *testing.T) {
+	app := &App{cfg: Config{PyramidDir: filepath.Join(t.TempDir(), "pyramids"), BandHeight: 64}}
+
+	b, err := mosaic.NewBuilder(&texturedTileRepository{}, gradient(image.Rect(0, 0, 300, 200)), mosaic.Options{TileSize: image.Pt(10, 10), Shape: mosaic.ShapeRect})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := app.writePyramid(b, pyramid.DZI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.URL != "/pyramids/"+p.ID+"/"+pyramid.DZIName || p.MaxLevel != 9 {
+		t.Fatalf("unexpected pyramid %+v", p)
+	}
+
+	hidden := filepath.Join(app.cfg.PyramidDir, pyramidPrefix+"partial")
+	if err = os.Mkdir(hidden, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(hidden, pyramid.DZIName), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var tt = []struct {
+		path   string
+		status int
+	}{
+		{p.URL, http.StatusOK},
+		{"/pyramids/" + p.ID + "/mosaic_files/9/1_0.png", http.StatusOK},
+		{"/pyramids/" + p.ID + "/mosaic_files/9/2_0.png", http.StatusNotFound},
+		{"/pyramids/", http.StatusNotFound},
+		{"/pyramids/" + p.ID + "/", http.StatusNotFound},
+		{"/pyramids/" + pyramidPrefix + "partial/" + pyramid.DZIName, http.StatusNotFound},
+		{"/pyramids/../" + p.ID + "/" + pyramid.DZIName, http.StatusNotFound},
+	}
+
+	handler := app.pyramidsHandler()
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, rec.Code)
+			}
+		})
+	}
+}
@@ -2,12 +2,44 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/ChrisShia/mosaic/cmd/internal"
+	"github.com/ChrisShia/tilestore"
 	"github.com/redis/go-redis/v9"
 )
 
+var ErrInvalidIndex = errors.New("invalid tile index")
+
+const (
+	indexRedis  = "redis"
+	indexMemory = "memory"
+)
+
+// setupTileImageRepository loads the offline tile set when one is configured
+// and connects to redis otherwise.
 func (app *App) setupTileImageRepository() (func(), error) {
+	if app.cfg.TilesDir != "" {
+		tiles, err := internal.LoadMemoryIndexFromDir(app.cfg.TilesDir, func(name string, err error) {
+			app.logger.PrintError(err, map[string]string{"file": name})
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		app.offlineTiles = tiles
+		app.logger.PrintInfo("Loaded offline tile set", map[string]string{
+			"dir":   app.cfg.TilesDir,
+			"tiles": strconv.Itoa(tiles.Len()),
+		})
+
+		return func() {}, nil
+	}
+
 	redisClose, err := app.connectToRedis(app.cfg)
 	if err != nil {
 		app.logger.PrintError(err, nil)
@@ -17,6 +49,139 @@ func (app *App) setupTileImageRepository() (func(), error) {
 	return redisClose, nil
 }
 
+// tileRepository returns the repository a mosaic for ip is rendered from.
+// The "memory" index copies the tile set of ip from redis into memory and
+// keeps it until the set changes, "redis" (the default) queries redis for
+// every lookup batch.
+// Either leaves the variants of the tiles out when excludeVariants is set.
+func (app *App) tileRepository(ctx context.Context, ip, index string, excludeVariants bool) (internal.TileRepository, error) {
+	if app.offlineTiles != nil {
+		return app.offlineTiles, nil
+	}
+
+	if index != "" && index != indexRedis && index != indexMemory {
+		return nil, ErrInvalidIndex
+	}
+
+	set, err := tilestore.Open(ctx, app.redisClient, ip)
+	if err != nil {
+		return nil, err
+	}
+	set.ExcludeVariants = excludeVariants
+
+	// cells are averaged in linear light, which older descriptors are not
+	if !set.Schema.Linear {
+		return nil, fmt.Errorf("%w: version %d", tilestore.ErrOutdatedSchema, set.Schema.Version)
+	}
+
+	if index == indexMemory {
+		m, err := app.memoryIndex(ctx, set)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+
+	if err = app.waitIndexReady(ctx, set); err != nil {
+		return nil, err
+	}
+
+	redisIndex := internal.NewRedisIndex(set)
+	redisIndex.BatchSize = app.cfg.Redis.BatchSize
+	redisIndex.Concurrency = app.cfg.Redis.Concurrency
+	return redisIndex, nil
+}
+
+// memoryIndex returns the copy of set held in memory, loading it from redis
+// when there is none yet or the set was migrated or added to since.
+func (app *App) memoryIndex(ctx context.Context, set *tilestore.Set) (*internal.MemoryIndex, error) {
+	revision, err := set.Revision(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key := memoryIndexKey{set: set.Name, excludeVariants: set.ExcludeVariants}
+	stamp := memoryIndexStamp{version: set.Schema.Version, revision: revision}
+	if m, ok := app.memoryIndexes.get(key, stamp); ok {
+		return m, nil
+	}
+
+	m, err := internal.LoadMemoryIndexFromRedis(ctx, set, app.cfg.Redis.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	app.memoryIndexes.put(key, stamp, m)
+	return m, nil
+}
+
+// memoryIndexes holds a copy of each tile set, with or without its variants,
+// along with the stamp of the set it was loaded at.
+type memoryIndexes struct {
+	mu      sync.Mutex
+	entries map[memoryIndexKey]memoryIndexEntry
+}
+
+type memoryIndexKey struct {
+	set             string
+	excludeVariants bool
+}
+
+// memoryIndexStamp is the schema version and revision of a set, a copy
+// loaded at another stamp is stale.
+type memoryIndexStamp struct {
+	version  int
+	revision int64
+}
+
+type memoryIndexEntry struct {
+	stamp memoryIndexStamp
+	index *internal.MemoryIndex
+}
+
+func (m *memoryIndexes) get(key memoryIndexKey, stamp memoryIndexStamp) (*internal.MemoryIndex, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok || e.stamp != stamp {
+		return nil, false
+	}
+	return e.index, true
+}
+
+// put replaces the copy of the set, a copy loaded concurrently at an older
+// stamp never replaces a newer one.
+func (m *memoryIndexes) put(key memoryIndexKey, stamp memoryIndexStamp, index *internal.MemoryIndex) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.entries == nil {
+		m.entries = make(map[memoryIndexKey]memoryIndexEntry)
+	}
+	if e, ok := m.entries[key]; ok && newer(e.stamp, stamp) {
+		return
+	}
+	m.entries[key] = memoryIndexEntry{stamp: stamp, index: index}
+}
+
+// newer reports whether a is a later stamp of a set than b.
+func newer(a, b memoryIndexStamp) bool {
+	if a.version != b.version {
+		return a.version > b.version
+	}
+	return a.revision > b.revision
+}
+
+// waitIndexReady checks the index of set and waits for redis to finish
+// indexing its tiles, up to the configured timeout.
+func (app *App) waitIndexReady(ctx context.Context, set *tilestore.Set) error {
+	ctx, cancel := context.WithTimeout(ctx, app.cfg.Redis.ReadyTimeout)
+	defer cancel()
+
+	return set.WaitReady(ctx, 50*time.Millisecond)
+}
+
 func (app *App) connectToRedis(cfg Config) (func(), error) {
 	counts := 0
 	for {
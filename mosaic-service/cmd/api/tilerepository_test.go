@@ -0,0 +1,58 @@
+package main
+
+import (
+	"image"
+	"testing"
+
+	"github.com/ChrisShia/mosaic/cmd/internal"
+)
+
+// Test_memoryIndexes keeps one copy of each set, dropped once the set is
+// migrated or added to and never replaced by an older one.
+func Test_memoryIndexes(t *testing.T) {
+	newIndex := func() *internal.MemoryIndex {
+		m, err := internal.NewMemoryIndex([]string{"a"}, []image.Image{image.NewNRGBA(image.Rect(0, 0, 1, 1))}, [][3]float64{{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	var m memoryIndexes
+	key := memoryIndexKey{set: "holidays"}
+	first, second := newIndex(), newIndex()
+
+	if _, ok := m.get(key, memoryIndexStamp{version: 5, revision: 10}); ok {
+		t.Fatal("expected no copy of an unknown set")
+	}
+
+	m.put(key, memoryIndexStamp{version: 5, revision: 10}, first)
+
+	var tt = []struct {
+		name     string
+		key      memoryIndexKey
+		stamp    memoryIndexStamp
+		expected *internal.MemoryIndex
+	}{
+		{"same stamp", key, memoryIndexStamp{version: 5, revision: 10}, first},
+		{"added to", key, memoryIndexStamp{version: 5, revision: 11}, nil},
+		{"migrated", key, memoryIndexStamp{version: 6, revision: 10}, nil},
+		{"without variants", memoryIndexKey{set: "holidays", excludeVariants: true}, memoryIndexStamp{version: 5, revision: 10}, nil},
+		{"other set", memoryIndexKey{set: "10.0.0.1"}, memoryIndexStamp{version: 5, revision: 10}, nil},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, ok := m.get(tc.key, tc.stamp)
+			if ok != (tc.expected != nil) || actual != tc.expected {
+				t.Errorf("expected %p, got %p", tc.expected, actual)
+			}
+		})
+	}
+
+	m.put(key, memoryIndexStamp{version: 5, revision: 11}, second)
+	m.put(key, memoryIndexStamp{version: 5, revision: 10}, first)
+	if actual, ok := m.get(key, memoryIndexStamp{version: 5, revision: 11}); !ok || actual != second {
+		t.Errorf("expected the newer copy to be kept, got %p", actual)
+	}
+}
@@ -0,0 +1,69 @@
+// Command gosaic renders mosaics and manages tile sets from the command line.
+//
+// Mosaics are rendered by the broker service, which downloads the tiles the
+// tile set is missing first, and written to disk. Only the progress of the
+// upload and of the download of the mosaic is reported on stderr, the broker
+// reports none while it renders, and the time rendering took once it is done.
+// With -offline the mosaic is rendered in-process from a directory of tile
+// images instead, neither the services nor redis are needed, and the progress
+// of the rendering itself is reported.
+//
+//	gosaic -tile-width 20 -set holidays -o mosaic.png photo.jpg
+//	gosaic -offline -tiles ./tiles -tile-width 20 -shape hex -o mosaic.jpg photo.jpg
+//
+// Tile sets are stored in redis, the tiles subcommands read and write them
+// directly. Sets are ingested from a directory of tile images or from an
+// archive exported by tilestore.
+//
+//	gosaic tiles list
+//	gosaic tiles ingest holidays ./tiles
+//	gosaic tiles ingest holidays holidays.tar.gz
+//	gosaic tiles delete holidays
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "tiles" {
+		err = tiles(os.Args[2:])
+	} else {
+		err = render(os.Args[1:])
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gosaic:", err)
+		os.Exit(1)
+	}
+}
+
+// parseInterspersed parses the flags of args wherever they are, before or
+// after the operands, and returns the operands. Arguments after "--" are
+// operands.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var operands []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return operands, nil
+		}
+
+		// Parse consumes the "--" ending the flags
+		if len(args) > fs.NArg() && args[len(args)-fs.NArg()-1] == "--" {
+			return append(operands, fs.Args()...), nil
+		}
+
+		operands = append(operands, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
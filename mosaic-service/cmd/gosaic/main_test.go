@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ChrisShia/tilestore"
+)
+
+func Test_parseInterspersed(t *testing.T) {
+	var tt = []struct {
+		args     string
+		operands []string
+		output   string
+		quiet    bool
+	}{
+		{"photo.jpg", []string{"photo.jpg"}, "", false},
+		{"-o mosaic.png photo.jpg", []string{"photo.jpg"}, "mosaic.png", false},
+		{"photo.jpg -o mosaic.png -q", []string{"photo.jpg"}, "mosaic.png", true},
+		{"ingest -q holidays ./tiles", []string{"ingest", "holidays", "./tiles"}, "", true},
+		{"-q -- -photo.jpg -o", []string{"-photo.jpg", "-o"}, "", true},
+		{"", nil, "", false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.args, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			output := fs.String("o", "", "")
+			quiet := fs.Bool("q", false, "")
+
+			operands, err := parseInterspersed(fs, strings.Fields(tc.args))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(operands, tc.operands) || *output != tc.output || *quiet != tc.quiet {
+				t.Errorf("expected %q, -o %q, -q %v, got %q, -o %q, -q %v", tc.operands, tc.output, tc.quiet, operands, *output, *quiet)
+			}
+		})
+	}
+}
+
+// Test_tilesInvalidName checks that set names are refused before redis is
+// reached.
+func Test_tilesInvalidName(t *testing.T) {
+	var tt = [][]string{
+		{"-q", "delete", "img:*"},
+		{"-q", "delete", ""},
+		{"-q", "ingest", "a?", t.TempDir()},
+	}
+
+	for _, args := range tt {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			if err := tiles(args); !errors.Is(err, tilestore.ErrInvalidName) {
+				t.Errorf("expected %v, got %v", tilestore.ErrInvalidName, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// progressInterval is the least time between two updates of a line.
+const progressInterval = 100 * time.Millisecond
+
+// progress reports the steps of a command on w, a line per step, rewriting
+// the line of the step in progress as it advances.
+type progress struct {
+	w io.Writer
+}
+
+func newProgress(w io.Writer, quiet bool) *progress {
+	if quiet {
+		w = io.Discard
+	}
+	return &progress{w: w}
+}
+
+// printf reports a finished step on a line of its own.
+func (p *progress) printf(format string, args ...any) {
+	fmt.Fprintf(p.w, format+"\n", args...)
+}
+
+// step starts a step counting up to total units, bytes when unit is empty.
+// Steps of an unknown total count without a percentage.
+func (p *progress) step(label string, total int64, unit string) *counter {
+	return &counter{p: p, label: label, total: total, unit: unit}
+}
+
+// counter is a step in progress. It counts the bytes read or written through
+// it, or the units added to it, and ends its line once total is reached or
+// done is called.
+type counter struct {
+	p     *progress
+	label string
+	total int64
+	unit  string
+
+	n       int64
+	printed time.Time
+	ended   bool
+}
+
+func (c *counter) add(n int64) {
+	c.n += n
+	if c.total > 0 && c.n >= c.total {
+		c.done()
+		return
+	}
+	if time.Since(c.printed) >= progressInterval {
+		c.print()
+	}
+}
+
+// done ends the line of the step, once.
+func (c *counter) done() {
+	if c.ended {
+		return
+	}
+	c.print()
+	fmt.Fprintln(c.p.w)
+	c.ended = true
+}
+
+func (c *counter) print() {
+	c.printed = time.Now()
+
+	amount := fmt.Sprintf("%d %s", c.n, c.unit)
+	if c.unit == "" {
+		amount = byteSize(c.n)
+	}
+	if c.total > 0 {
+		fmt.Fprintf(c.p.w, "\r%s %s %3d%%", c.label, amount, 100*c.n/c.total)
+		return
+	}
+	fmt.Fprintf(c.p.w, "\r%s %s", c.label, amount)
+}
+
+func (c *counter) reader(r io.Reader) io.Reader {
+	return &countingReader{r: r, c: c}
+}
+
+func (c *counter) writer(w io.Writer) io.Writer {
+	return &countingWriter{w: w, c: c}
+}
+
+type countingReader struct {
+	r io.Reader
+	c *counter
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.c.add(int64(n))
+	return n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	c *counter
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.c.add(int64(n))
+	return n, err
+}
+
+// byteSize formats n bytes in the largest unit it makes at least one of.
+func byteSize(n int64) string {
+	const unit = 1 << 10
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
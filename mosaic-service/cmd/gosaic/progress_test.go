@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_byteSize(t *testing.T) {
+	var tt = []struct {
+		n        int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 << 20, "5.0 MiB"},
+		{3 << 30, "3.0 GiB"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.expected, func(t *testing.T) {
+			if s := byteSize(tc.n); s != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, s)
+			}
+		})
+	}
+}
+
+// Test_counter counts the bytes read through a step and ends its line once,
+// when the total is reached.
+func Test_counter(t *testing.T) {
+	out := new(bytes.Buffer)
+	c := newProgress(out, false).step("uploading", 2048, "")
+
+	if _, err := io.Copy(io.Discard, c.reader(bytes.NewReader(make([]byte, 2048)))); err != nil {
+		t.Fatal(err)
+	}
+	c.done()
+
+	if !strings.HasSuffix(out.String(), "\ruploading 2.0 KiB 100%\n") || strings.Count(out.String(), "\n") != 1 {
+		t.Errorf("unexpected progress %q", out)
+	}
+
+	out.Reset()
+	newProgress(out, true).step("rendering", 10, "rows").add(10)
+	if out.Len() != 0 {
+		t.Errorf("expected quiet progress to print nothing, got %q", out)
+	}
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ChrisShia/mosaic/cmd/internal"
+	"github.com/ChrisShia/mosaic/cmd/internal/mosaic"
+	"github.com/ChrisShia/mosaic/cmd/internal/pngstream"
+)
+
+var ErrUnknownOutputFormat = errors.New("output must be a .png, .jpg or .jpeg file")
+
+// format is the encoding of the written mosaic, chosen by the extension of
+// the output file.
+type format string
+
+const (
+	formatPNG  format = "png"
+	formatJPEG format = "jpeg"
+)
+
+const jpegQuality = 90
+
+func parseFormat(path string) (format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		return formatPNG, nil
+	case ".jpg", ".jpeg":
+		return formatJPEG, nil
+	default:
+		return "", ErrUnknownOutputFormat
+	}
+}
+
+// renderConfig is a mosaic to render: the original it is made of, the file
+// it is written to and the options of the broker's /mosaic endpoint. Offline
+// renders read their tiles from TilesDir and ignore the options about tile
+// sets.
+type renderConfig struct {
+	Original string
+	Output   string
+	Format   format
+
+	TileWidth   int
+	TileHeight  int
+	Shape       string
+	Edge        string
+	OutputScale float64
+	OutputWidth int
+	Resample    string
+	Background  string
+	Metrics     bool
+
+	Broker          string
+	TileSet         string
+	Index           string
+	ExcludeVariants bool
+	MatchThreshold  float64
+	DownloadBudget  int
+
+	Offline    bool
+	TilesDir   string
+	Workers    int
+	BandHeight int
+}
+
+func render(args []string) error {
+	var cfg renderConfig
+	var quiet bool
+
+	fs := flag.NewFlagSet("gosaic", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gosaic [flags] <original>")
+		fmt.Fprintln(fs.Output(), "       gosaic tiles [flags] list|ingest|delete ...")
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&cfg.Output, "o", "", "file the mosaic is written to, .png or .jpg (default <original>_mosaic.png)")
+	fs.IntVar(&cfg.TileWidth, "tile-width", 0, "width of the cells in pixels of the original")
+	fs.IntVar(&cfg.TileHeight, "tile-height", 0, "height of the cells (default the width)")
+	fs.StringVar(&cfg.Shape, "shape", "", "shape of the cells: rect, brick or hex")
+	fs.StringVar(&cfg.Edge, "edge", "", "what to do with the edges of the original: partial, crop or pad")
+	fs.Float64Var(&cfg.OutputScale, "scale", 0, "size of the mosaic relative to the original (default 1)")
+	fs.IntVar(&cfg.OutputWidth, "width", 0, "width of the mosaic in pixels, overrides -scale")
+	fs.StringVar(&cfg.Resample, "resample", "", "filter resizing the tiles to the cells")
+	fs.StringVar(&cfg.Background, "background", "", "color showing through empty cells: transparent, #rrggbb or #rrggbbaa")
+	fs.BoolVar(&cfg.Metrics, "metrics", false, "print how closely the mosaic reproduces the original, as JSON")
+	fs.BoolVar(&quiet, "q", false, "do not report progress: of the upload and download only, or of the rendering with -offline")
+
+	fs.StringVar(&cfg.Broker, "broker", "http://localhost:4000", "URL of the broker service")
+	fs.StringVar(&cfg.TileSet, "set", "", "tile set to render from and download tiles to (default the one of your address)")
+	fs.StringVar(&cfg.Index, "index", "", "how the tile set is searched: redis or memory")
+	fs.BoolVar(&cfg.ExcludeVariants, "exclude-variants", false, "render from the downloaded tiles only, without their variants")
+	fs.Float64Var(&cfg.MatchThreshold, "match-threshold", 0, "distance within which a tile covers a color, no more are downloaded for it")
+	fs.IntVar(&cfg.DownloadBudget, "download-budget", -1, "pictures downloaded at most for the missing colors, -1 for one per cell")
+
+	fs.BoolVar(&cfg.Offline, "offline", false, "render in-process from -tiles, without the services")
+	fs.StringVar(&cfg.TilesDir, "tiles", "", "directory of tile images offline mosaics are rendered from")
+	fs.IntVar(&cfg.Workers, "workers", 0, "goroutines rendering an offline mosaic (0 = GOMAXPROCS)")
+	fs.IntVar(&cfg.BandHeight, "band-height", 256, "rows of the bands offline mosaics are rendered in")
+
+	operands, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(operands) != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	cfg.Original = operands[0]
+	if cfg.Output == "" {
+		cfg.Output = strings.TrimSuffix(cfg.Original, filepath.Ext(cfg.Original)) + "_mosaic.png"
+	}
+
+	if cfg.Format, err = parseFormat(cfg.Output); err != nil {
+		return err
+	}
+	if cfg.TileWidth <= 0 || cfg.TileHeight < 0 {
+		return errors.New("-tile-width is required and the tile size must be positive")
+	}
+	if cfg.TileHeight == 0 {
+		cfg.TileHeight = cfg.TileWidth
+	}
+	if cfg.Offline && cfg.TilesDir == "" {
+		return errors.New("-offline needs a -tiles directory")
+	}
+
+	originalImg, err := decodeFile(cfg.Original)
+	if err != nil {
+		return err
+	}
+
+	p := newProgress(os.Stderr, quiet)
+	if cfg.Offline {
+		return renderOffline(cfg, originalImg, p, os.Stdout)
+	}
+	return renderOnline(cfg, originalImg, p, os.Stdout)
+}
+
+// mosaicPayload is the body of a request to the broker's /mosaic endpoint.
+type mosaicPayload struct {
+	Original    string  `json:"original"`
+	TileWidth   int     `json:"tile_width"`
+	TileHeight  int     `json:"tile_height,omitempty"`
+	Shape       string  `json:"shape,omitempty"`
+	Edge        string  `json:"edge,omitempty"`
+	OutputScale float64 `json:"output_scale,omitempty"`
+	OutputWidth int     `json:"output_width,omitempty"`
+	Resample    string  `json:"resample,omitempty"`
+	Background  string  `json:"background,omitempty"`
+	Index       string  `json:"index,omitempty"`
+	Output      string  `json:"output,omitempty"`
+	Metrics     bool    `json:"metrics,omitempty"`
+	TileSet     string  `json:"tile_set,omitempty"`
+
+	ExcludeVariants bool `json:"exclude_variants,omitempty"`
+
+	MatchThreshold float64 `json:"match_threshold,omitempty"`
+	DownloadBudget *int    `json:"download_budget,omitempty"`
+}
+
+// renderOnline uploads the original to the broker and writes the mosaic it
+// responds with. The mosaic is streamed as a PNG, unless metrics are asked
+// for, which only come with the JSON response, and are printed to stdout.
+func renderOnline(cfg renderConfig, originalImg image.Image, p *progress, stdout io.Writer) error {
+	// the broker only decodes PNG originals
+	original, err := internal.ImageToBase64String(originalImg)
+	if err != nil {
+		return err
+	}
+
+	payload := mosaicPayload{
+		Original:        original,
+		TileWidth:       cfg.TileWidth,
+		TileHeight:      cfg.TileHeight,
+		Shape:           cfg.Shape,
+		Edge:            cfg.Edge,
+		OutputScale:     cfg.OutputScale,
+		OutputWidth:     cfg.OutputWidth,
+		Resample:        cfg.Resample,
+		Background:      cfg.Background,
+		Index:           cfg.Index,
+		Metrics:         cfg.Metrics,
+		TileSet:         cfg.TileSet,
+		ExcludeVariants: cfg.ExcludeVariants,
+		MatchThreshold:  cfg.MatchThreshold,
+	}
+	if !cfg.Metrics {
+		payload.Output = "png"
+	}
+	if cfg.DownloadBudget >= 0 {
+		payload.DownloadBudget = &cfg.DownloadBudget
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	upload := p.step("uploading", int64(len(body)), "")
+	request, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(cfg.Broker, "/")+"/mosaic", upload.reader(bytes.NewReader(body)))
+	if err != nil {
+		return err
+	}
+	request.ContentLength = int64(len(body))
+	request.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	res, err := http.DefaultClient.Do(request)
+	upload.done()
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err = brokerError(res); err != nil {
+		return err
+	}
+	p.printf("rendered in %s", time.Since(start).Round(100*time.Millisecond))
+
+	download := p.step("downloading", res.ContentLength, "")
+	defer download.done()
+	r := download.reader(res.Body)
+
+	if res.Header.Get("Content-Type") == "image/png" {
+		if cfg.Format == formatPNG {
+			return writeFile(cfg.Output, func(w io.Writer) error {
+				_, err := io.Copy(w, r)
+				return err
+			})
+		}
+
+		mosaicImg, err := png.Decode(r)
+		if err != nil {
+			return err
+		}
+		return writeImage(cfg.Output, cfg.Format, mosaicImg)
+	}
+
+	var response struct {
+		Mosaic  string          `json:"mosaic"`
+		Metrics json.RawMessage `json:"metrics"`
+	}
+	if err = json.NewDecoder(r).Decode(&response); err != nil {
+		return fmt.Errorf("broker: %w", err)
+	}
+
+	mosaicImg, err := internal.Base64StringToImage(response.Mosaic)
+	if err != nil {
+		return fmt.Errorf("broker: %w", err)
+	}
+	if err = writeImage(cfg.Output, cfg.Format, mosaicImg); err != nil {
+		return err
+	}
+
+	if response.Metrics != nil {
+		_, err = fmt.Fprintf(stdout, "%s\n", response.Metrics)
+	}
+	return err
+}
+
+// brokerError reads the error the broker responded with, if any.
+func brokerError(res *http.Response) error {
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var envelope struct {
+		Message any `json:"message"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&envelope); err != nil || envelope.Message == nil {
+		return fmt.Errorf("broker: %s", res.Status)
+	}
+
+	return fmt.Errorf("broker: %s: %v", res.Status, envelope.Message)
+}
+
+// renderOffline renders the mosaic in-process from the tiles of
+// renderConfig.TilesDir. PNG mosaics are rendered and encoded in bands,
+// without holding the whole mosaic.
+func renderOffline(cfg renderConfig, originalImg image.Image, p *progress, stdout io.Writer) error {
+	shape, err := mosaic.ParseShape(cfg.Shape)
+	if err != nil {
+		return err
+	}
+	edge, err := mosaic.ParseEdgePolicy(cfg.Edge)
+	if err != nil {
+		return err
+	}
+	filter, err := mosaic.ParseFilter(cfg.Resample)
+	if err != nil {
+		return err
+	}
+	background, err := mosaic.ParseBackground(cfg.Background)
+	if err != nil {
+		return err
+	}
+
+	tiles, err := internal.LoadMemoryIndexFromDir(cfg.TilesDir, func(name string, err error) {
+		p.printf("skipped %s: %v", name, err)
+	})
+	if err != nil {
+		return err
+	}
+	p.printf("loaded %d tiles from %s", tiles.Len(), cfg.TilesDir)
+
+	b, err := mosaic.NewBuilder(tiles, originalImg, mosaic.Options{
+		TileSize:    image.Pt(cfg.TileWidth, cfg.TileHeight),
+		Shape:       shape,
+		Edge:        edge,
+		Scale:       cfg.OutputScale,
+		OutputWidth: cfg.OutputWidth,
+		Filter:      filter,
+		Background:  background,
+		Workers:     cfg.Workers,
+	})
+	if err != nil {
+		return err
+	}
+
+	rendering := p.step("rendering", int64(b.Size().Y), "rows")
+	if cfg.Format == formatPNG {
+		err = writeFile(cfg.Output, func(w io.Writer) error {
+			e, err := pngstream.NewEncoder(w, b.Size())
+			if err != nil {
+				return err
+			}
+
+			err = b.Bands(cfg.BandHeight, func(band *image.NRGBA) error {
+				rendering.add(int64(band.Rect.Dy()))
+				return e.WriteRows(band)
+			})
+			if err != nil {
+				return err
+			}
+			return e.Close()
+		})
+	} else {
+		var mosaicImg image.Image
+		if mosaicImg, err = b.Mosaic(); err == nil {
+			rendering.add(int64(b.Size().Y))
+			err = writeImage(cfg.Output, cfg.Format, mosaicImg)
+		}
+	}
+	rendering.done()
+	if err != nil {
+		return err
+	}
+	p.printf("wrote %dx%d mosaic of %d cells to %s", b.Size().X, b.Size().Y, b.Cells(), cfg.Output)
+
+	if !cfg.Metrics {
+		return nil
+	}
+
+	q, err := b.Quality()
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(stdout).Encode(q)
+}
+
+func decodeFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return img, nil
+}
+
+func writeImage(path string, f format, img image.Image) error {
+	return writeFile(path, func(w io.Writer) error {
+		if f == formatJPEG {
+			return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+		}
+		return png.Encode(w, img)
+	})
+}
+
+// writeFile writes path with write, the file is removed when write fails so
+// that no partial mosaic is left behind.
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+
+	return err
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_parseFormat(t *testing.T) {
+	var tt = []struct {
+		path     string
+		expected format
+		err      error
+	}{
+		{"mosaic.png", formatPNG, nil},
+		{"out/Mosaic.PNG", formatPNG, nil},
+		{"mosaic.jpg", formatJPEG, nil},
+		{"mosaic.jpeg", formatJPEG, nil},
+		{"mosaic.gif", "", ErrUnknownOutputFormat},
+		{"mosaic", "", ErrUnknownOutputFormat},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			f, err := parseFormat(tc.path)
+			if !errors.Is(err, tc.err) || f != tc.expected {
+				t.Errorf("expected %q (%v), got %q (%v)", tc.expected, tc.err, f, err)
+			}
+		})
+	}
+}
+
+// Test_renderOffline renders a mosaic from a directory of solid tiles, to a
+// PNG in bands and to a JPEG at once.
+func Test_renderOffline(t *testing.T) {
+	tilesDir := t.TempDir()
+	for i, c := range []color.NRGBA{{R: 0xff, A: 0xff}, {G: 0xff, A: 0xff}, {B: 0xff, A: 0xff}, {A: 0xff}} {
+		writePNG(t, filepath.Join(tilesDir, string(rune('a'+i))+".png"), solid(image.Rect(0, 0, 16, 16), c))
+	}
+
+	var tt = []struct {
+		name   string
+		output string
+		scale  float64
+		size   image.Point
+	}{
+		{"png", "mosaic.png", 1, image.Pt(60, 40)},
+		{"png scaled", "mosaic.png", 2, image.Pt(120, 80)},
+		{"jpeg", "mosaic.jpg", 1, image.Pt(60, 40)},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := renderConfig{
+				Output:      filepath.Join(t.TempDir(), tc.output),
+				TileWidth:   10,
+				TileHeight:  10,
+				OutputScale: tc.scale,
+				Metrics:     true,
+				TilesDir:    tilesDir,
+				BandHeight:  7,
+			}
+			cfg.Format, _ = parseFormat(cfg.Output)
+
+			stdout := new(bytes.Buffer)
+			if err := renderOffline(cfg, gradient(image.Rect(0, 0, 60, 40)), newProgress(io.Discard, false), stdout); err != nil {
+				t.Fatal(err)
+			}
+
+			mosaicImg, err := decodeFile(cfg.Output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mosaicImg.Bounds().Size() != tc.size {
+				t.Errorf("expected a %v mosaic, got %v", tc.size, mosaicImg.Bounds().Size())
+			}
+
+			var metrics struct {
+				PSNR float64 `json:"psnr"`
+			}
+			if err = json.Unmarshal(stdout.Bytes(), &metrics); err != nil || metrics.PSNR <= 0 {
+				t.Errorf("expected the metrics on stdout, got %q", stdout)
+			}
+		})
+	}
+}
+
+// Test_renderOnline renders mosaics through a fake broker, streamed as a PNG
+// or returned in JSON with metrics, and leaves no file behind when the broker
+// fails.
+func Test_renderOnline(t *testing.T) {
+	mosaicImg := gradient(image.Rect(0, 0, 30, 20))
+
+	var payload mosaicPayload
+	broker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload = mosaicPayload{}
+		if r.URL.Path != "/mosaic" || json.NewDecoder(r.Body).Decode(&payload) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case payload.TileSet == "missing":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":true,"message":"tile set does not exist"}`))
+		case payload.Output == "png":
+			w.Header().Set("Content-Type", "image/png")
+			png.Encode(w, mosaicImg)
+		default:
+			buf := new(bytes.Buffer)
+			png.Encode(buf, mosaicImg)
+			json.NewEncoder(w).Encode(map[string]any{
+				"mosaic":  buf.Bytes(),
+				"metrics": map[string]float64{"psnr": 21.5},
+			})
+		}
+	}))
+	defer broker.Close()
+
+	var tt = []struct {
+		name    string
+		output  string
+		metrics bool
+		budget  int
+		set     string
+		err     string
+	}{
+		{"png", "mosaic.png", false, -1, "holidays", ""},
+		{"jpeg", "mosaic.jpg", false, 0, "", ""},
+		{"metrics", "mosaic.png", true, 5, "holidays", ""},
+		{"broker error", "mosaic.png", false, -1, "missing", "tile set does not exist"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := renderConfig{
+				Output:         filepath.Join(t.TempDir(), tc.output),
+				TileWidth:      10,
+				TileHeight:     10,
+				Metrics:        tc.metrics,
+				Broker:         broker.URL + "/",
+				TileSet:        tc.set,
+				DownloadBudget: tc.budget,
+			}
+			cfg.Format, _ = parseFormat(cfg.Output)
+
+			stdout := new(bytes.Buffer)
+			err := renderOnline(cfg, gradient(image.Rect(0, 0, 30, 20)), newProgress(io.Discard, false), stdout)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected %q, got %v", tc.err, err)
+				}
+				if _, err = os.Stat(cfg.Output); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("expected no mosaic written, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if payload.TileSet != tc.set || payload.TileWidth != 10 || payload.Original == "" {
+				t.Errorf("unexpected payload %+v", payload)
+			}
+			if (payload.DownloadBudget == nil) != (tc.budget < 0) || payload.DownloadBudget != nil && *payload.DownloadBudget != tc.budget {
+				t.Errorf("expected a download budget of %d, got %v", tc.budget, payload.DownloadBudget)
+			}
+			if (payload.Output == "png") == tc.metrics {
+				t.Errorf("expected the png output unless metrics are asked for, got %q", payload.Output)
+			}
+
+			written, err := decodeFile(cfg.Output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if written.Bounds() != mosaicImg.Bounds() {
+				t.Errorf("expected a %v mosaic, got %v", mosaicImg.Bounds(), written.Bounds())
+			}
+			if tc.metrics && !strings.Contains(stdout.String(), `"psnr":21.5`) {
+				t.Errorf("expected the metrics on stdout, got %q", stdout)
+			}
+		})
+	}
+}
+
+func writePNG(t *testing.T, path string, img image.Image) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err = png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func solid(r image.Rectangle, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+// gradient returns an opaque image with the given bounds whose red and green
+// channels grow along x and y, so every cell averages to a different color.
+func gradient(r image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(255 * (x - r.Min.X) / r.Dx()),
+				G: uint8(255 * (y - r.Min.Y) / r.Dy()),
+				B: uint8((x * y) % 256),
+				A: 0xff,
+			})
+		}
+	}
+	return img
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ChrisShia/tilestore"
+	"github.com/redis/go-redis/v9"
+)
+
+func tiles(args []string) error {
+	var (
+		addr      string
+		batchSize int
+		quiet     bool
+	)
+
+	fs := flag.NewFlagSet("gosaic tiles", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gosaic tiles [flags] list")
+		fmt.Fprintln(fs.Output(), "       gosaic tiles [flags] ingest <set> <directory|archive>")
+		fmt.Fprintln(fs.Output(), "       gosaic tiles [flags] delete <set>")
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&addr, "redis", "redis://localhost:6379", "redis server URL")
+	fs.IntVar(&batchSize, "batch", 256, "tiles read or written per pipeline")
+	fs.BoolVar(&quiet, "q", false, "do not report progress")
+
+	operands, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var command string
+	if len(operands) > 0 {
+		command = operands[0]
+	}
+
+	arity := map[string]int{"list": 1, "ingest": 3, "delete": 2}
+	if n, ok := arity[command]; !ok || len(operands) != n {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	opt, err := redis.ParseURL(addr)
+	if err != nil {
+		return err
+	}
+	client := redis.NewClient(opt)
+	defer client.Close()
+
+	ctx := context.Background()
+	p := newProgress(os.Stderr, quiet)
+
+	switch command {
+	case "list":
+		return listSets(ctx, client, os.Stdout)
+	case "ingest":
+		return ingest(ctx, client, operands[1], operands[2], batchSize, p)
+	default:
+		return deleteSet(ctx, client, operands[1], batchSize, p)
+	}
+}
+
+// listSets prints the tile sets, with their number of tiles and schema
+// version.
+func listSets(ctx context.Context, client *redis.Client, w io.Writer) error {
+	names, err := tilestore.List(ctx, client)
+	if err != nil {
+		return err
+	}
+	slices.Sort(names)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SET\tTILES\tSCHEMA")
+	for _, name := range names {
+		s, err := tilestore.Open(ctx, client, name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		info, err := s.Describe(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", name, info.Docs, s.Schema.Version)
+	}
+
+	return tw.Flush()
+}
+
+// ingest adds the tiles of path to the set named name, creating it when it
+// does not exist. path is a directory of tile images or an archive exported
+// by tilestore, gzipped when named .gz or .tgz.
+func ingest(ctx context.Context, client *redis.Client, name, path string, batchSize int, p *progress) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return ingestDir(ctx, client, name, path, batchSize, p)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reading := p.step("importing "+path, info.Size(), "")
+	defer reading.done()
+
+	var r io.Reader = reading.reader(f)
+	if strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".tgz") {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	report, err := tilestore.Import(ctx, client, name, r, batchSize)
+	reading.done()
+	p.printf("%s: imported %d of %d tiles, %d already in the set", name, report.Imported, report.Tiles, report.Skipped)
+	return err
+}
+
+// ingestDir adds every image of dir to the set named name, any format
+// registered with the image package is accepted. Images the set holds
+// already are skipped, so ingesting a directory again adds only the new
+// ones.
+func ingestDir(ctx context.Context, client *redis.Client, name, dir string, batchSize int, p *progress) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	entries = slices.DeleteFunc(entries, os.DirEntry.IsDir)
+
+	s := tilestore.New(client, name)
+	if err = s.EnsureIndex(ctx); err != nil {
+		return err
+	}
+
+	hashes, err := s.Hashes(ctx, max(batchSize, 1))
+	if err != nil {
+		return err
+	}
+
+	adding := p.step("ingesting "+dir, int64(len(entries)), "files")
+	defer adding.done()
+
+	added, skipped := 0, 0
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+
+		hash := tilestore.Hash(data)
+		if _, ok := hashes[hash]; ok {
+			skipped++
+			adding.add(1)
+			continue
+		}
+
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		key, err := s.Add(ctx, tilestore.Tile{
+			Image:  data,
+			Vector: tilestore.Descriptor(img),
+			Source: entry.Name(),
+		})
+		if err != nil {
+			return err
+		}
+		hashes[hash] = key
+		added++
+		adding.add(1)
+	}
+
+	adding.done()
+	p.printf("%s: ingested %d of %d images, %d already in the set", name, added, len(entries), skipped)
+	return nil
+}
+
+// deleteSet deletes the set named name and its index.
+func deleteSet(ctx context.Context, client *redis.Client, name string, batchSize int, p *progress) error {
+	s, err := tilestore.Open(ctx, client, name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	deleted, err := s.Delete(ctx, max(batchSize, 1))
+	p.printf("%s: deleted %d tiles", name, deleted)
+	return err
+}
@@ -8,6 +8,8 @@ import (
 	"io"
 	"log"
 	"os"
+
+	"github.com/ChrisShia/tilestore"
 )
 
 func Base64StringToImage(str string) (image.Image, error) {
@@ -60,29 +62,11 @@ func ImageAverageRGB(img image.Image) [3]float64 {
 	return AverageRGBArea(img, bounds.Min.X, bounds.Max.X, bounds.Min.Y, bounds.Max.Y)
 }
 
+// AverageRGBArea averages the color of img over the area, see
+// tilestore.AverageArea.
 func AverageRGBArea(img image.Image, xMin, xMax, yMin, yMax int) [3]float64 {
-	var rSum, gSum, bSum uint64
-	var count uint64
-
-	for yy := yMin; yy < yMax; yy++ {
-		for xx := xMin; xx < xMax; xx++ {
-			r, g, b, _ := img.At(xx, yy).RGBA()
-			rSum += uint64(r >> 8)
-			gSum += uint64(g >> 8)
-			bSum += uint64(b >> 8)
-			count++
-		}
-	}
-
-	if count == 0 {
-		return [3]float64{0, 0, 0}
-	}
-
-	rAve := float64(rSum) / float64(count)
-	gAve := float64(gSum) / float64(count)
-	bAve := float64(bSum) / float64(count)
-
-	return [3]float64{rAve, gAve, bAve}
+	avg, _ := tilestore.AverageArea(img, image.Rectangle{Min: image.Pt(xMin, yMin), Max: image.Pt(xMax, yMax)})
+	return avg
 }
 
 func RGBAt(img image.Image, x int, y int) [3]float64 {
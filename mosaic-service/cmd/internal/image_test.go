@@ -0,0 +1,30 @@
+package internal
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+
+	"github.com/ChrisShia/tilestore"
+)
+
+// Test_ImageAverageRGBMatchesDescriptor checks that originals are averaged
+// exactly as tilestore describes tiles.
+func Test_ImageAverageRGBMatchesDescriptor(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for _, img := range []image.Image{
+		randomNRGBA(rnd, image.Rect(3, -4, 40, 31)),
+		randomYCbCr(rnd, image.Rect(0, 0, 33, 17)),
+	} {
+		expected := tilestore.Descriptor(img)
+		if got := ImageAverageRGB(img); got != expected {
+			t.Errorf("%T: expected %v, got %v", img, expected, got)
+		}
+
+		b := img.Bounds()
+		if got := NewSummedArea(img).AverageRGBArea(b.Min.X, b.Max.X, b.Min.Y, b.Max.Y); got != expected {
+			t.Errorf("%T summed area: expected %v, got %v", img, expected, got)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"math"
+	"sort"
+)
+
+// KDTree indexes 3 dimensional descriptors for nearest neighbour queries. It
+// is built once and is safe for concurrent queries.
+type KDTree struct {
+	points [][3]float64
+	nodes  []kdNode
+	root   int
+}
+
+type kdNode struct {
+	point       int
+	axis        int
+	left, right int
+}
+
+// Neighbour is a result of a KNN query, Index refers to the slice the tree
+// was built from and Distance is the euclidean distance to the query.
+type Neighbour struct {
+	Index    int
+	Distance float64
+}
+
+// NewKDTree builds a balanced tree over points, splitting on the median of
+// each axis in turn. The tree keeps a reference to points.
+func NewKDTree(points [][3]float64) *KDTree {
+	t := &KDTree{
+		points: points,
+		nodes:  make([]kdNode, 0, len(points)),
+	}
+
+	perm := make([]int, len(points))
+	for i := range perm {
+		perm[i] = i
+	}
+
+	t.root = t.build(perm, 0)
+
+	return t
+}
+
+func (t *KDTree) build(perm []int, depth int) int {
+	if len(perm) == 0 {
+		return -1
+	}
+
+	axis := depth % 3
+	sort.Slice(perm, func(i, j int) bool {
+		return t.points[perm[i]][axis] < t.points[perm[j]][axis]
+	})
+
+	median := len(perm) / 2
+	n := len(t.nodes)
+	t.nodes = append(t.nodes, kdNode{point: perm[median], axis: axis})
+
+	left := t.build(perm[:median], depth+1)
+	right := t.build(perm[median+1:], depth+1)
+	t.nodes[n].left, t.nodes[n].right = left, right
+
+	return n
+}
+
+func (t *KDTree) Len() int {
+	return len(t.points)
+}
+
+// Nearest returns the point closest to q, false when the tree is empty.
+func (t *KDTree) Nearest(q [3]float64) (Neighbour, bool) {
+	nn := t.KNN(q, 1)
+	if len(nn) == 0 {
+		return Neighbour{}, false
+	}
+	return nn[0], true
+}
+
+// KNN returns the k points closest to q, nearest first.
+func (t *KDTree) KNN(q [3]float64, k int) []Neighbour {
+	if k <= 0 || t.root < 0 {
+		return nil
+	}
+
+	// best holds squared distances sorted in ascending order
+	best := make([]Neighbour, 0, k+1)
+	t.search(t.root, q, k, &best)
+
+	for i := range best {
+		best[i].Distance = math.Sqrt(best[i].Distance)
+	}
+
+	return best
+}
+
+func (t *KDTree) search(n int, q [3]float64, k int, best *[]Neighbour) {
+	if n < 0 {
+		return
+	}
+
+	node := t.nodes[n]
+	p := t.points[node.point]
+
+	insertNeighbour(best, Neighbour{Index: node.point, Distance: squaredDistance(p, q)}, k)
+
+	diff := q[node.axis] - p[node.axis]
+	near, far := node.left, node.right
+	if diff > 0 {
+		near, far = far, near
+	}
+
+	t.search(near, q, k, best)
+
+	// the far side can only hold closer points if the splitting plane is
+	// closer than the current kth neighbour
+	if len(*best) < k || diff*diff < (*best)[len(*best)-1].Distance {
+		t.search(far, q, k, best)
+	}
+}
+
+// insertNeighbour keeps best sorted and at most k long.
+func insertNeighbour(best *[]Neighbour, nb Neighbour, k int) {
+	b := *best
+	if len(b) == k && nb.Distance >= b[k-1].Distance {
+		return
+	}
+
+	i := sort.Search(len(b), func(i int) bool { return b[i].Distance > nb.Distance })
+	b = append(b, Neighbour{})
+	copy(b[i+1:], b[i:])
+	b[i] = nb
+
+	if len(b) > k {
+		b = b[:k]
+	}
+
+	*best = b
+}
+
+func squaredDistance(a, b [3]float64) float64 {
+	var d float64
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return d
+}
@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func Test_KDTreeKNNMatchesBruteForce(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	points := make([][3]float64, 500)
+	for i := range points {
+		points[i] = [3]float64{rnd.Float64() * 255, rnd.Float64() * 255, rnd.Float64() * 255}
+	}
+
+	tree := NewKDTree(points)
+
+	for q := 0; q < 200; q++ {
+		query := [3]float64{rnd.Float64() * 255, rnd.Float64() * 255, rnd.Float64() * 255}
+
+		expected := bruteForceKNN(points, query, 5)
+		actual := tree.KNN(query, 5)
+
+		if len(actual) != len(expected) {
+			t.Fatalf("expected %d neighbours, got %d", len(expected), len(actual))
+		}
+		for i := range expected {
+			if actual[i].Index != expected[i].Index {
+				t.Fatalf("query %v, neighbour %d: expected %v, got %v", query, i, expected[i], actual[i])
+			}
+		}
+	}
+}
+
+func Test_KDTreeEdgeCases(t *testing.T) {
+	empty := NewKDTree(nil)
+	if _, ok := empty.Nearest([3]float64{}); ok {
+		t.Error("expected no neighbour in an empty tree")
+	}
+
+	tree := NewKDTree([][3]float64{{0, 0, 0}, {10, 10, 10}})
+	if nn := tree.KNN([3]float64{}, 5); len(nn) != 2 {
+		t.Errorf("expected k to be capped by the tree size, got %d neighbours", len(nn))
+	}
+	if nn, _ := tree.Nearest([3]float64{3, 4, 0}); nn.Index != 0 || nn.Distance != 5 {
+		t.Errorf("expected neighbour 0 at distance 5, got %v", nn)
+	}
+}
+
+func bruteForceKNN(points [][3]float64, q [3]float64, k int) []Neighbour {
+	all := make([]Neighbour, len(points))
+	for i, p := range points {
+		all[i] = Neighbour{Index: i, Distance: squaredDistance(p, q)}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Distance < all[j].Distance })
+	return all[:k]
+}
+
+func Benchmark_KDTreeNearest(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+
+	points := make([][3]float64, 10000)
+	for i := range points {
+		points[i] = [3]float64{rnd.Float64() * 255, rnd.Float64() * 255, rnd.Float64() * 255}
+	}
+	tree := NewKDTree(points)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Nearest(points[i%len(points)])
+	}
+}
@@ -0,0 +1,263 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/ChrisShia/tilestore"
+)
+
+var (
+	ErrEmptyTileSet = errors.New("tile set holds no tiles")
+	ErrDuplicateID  = errors.New("duplicate tile id")
+)
+
+// MemoryIndex is a TileRepository holding a whole tile set in memory and
+// matching colors against a k-d tree of the tiles' average colors, so
+// lookups need no network round trip.
+type MemoryIndex struct {
+	// Set names the tile set in the records of the matched tiles.
+	Set string
+
+	records     []Tile
+	byID        map[string]int
+	tiles       []image.Image
+	descriptors [][3]float64
+	tree        *KDTree
+}
+
+// NewMemoryIndex indexes tiles by the descriptors at the same positions, ids
+// names them and must be unique.
+func NewMemoryIndex(ids []string, tiles []image.Image, descriptors [][3]float64) (*MemoryIndex, error) {
+	if len(tiles) != len(descriptors) || len(tiles) != len(ids) {
+		return nil, fmt.Errorf("%d ids, %d tiles but %d descriptors", len(ids), len(tiles), len(descriptors))
+	}
+	if len(tiles) == 0 {
+		return nil, ErrEmptyTileSet
+	}
+
+	records := make([]Tile, len(ids))
+	byID := make(map[string]int, len(ids))
+	for i, id := range ids {
+		if _, ok := byID[id]; ok {
+			return nil, fmt.Errorf("%w: %q", ErrDuplicateID, id)
+		}
+		byID[id] = i
+		records[i] = Tile{ID: id, Descriptor: descriptors[i]}
+	}
+
+	return &MemoryIndex{
+		records:     records,
+		byID:        byID,
+		tiles:       tiles,
+		descriptors: descriptors,
+		tree:        NewKDTree(descriptors),
+	}, nil
+}
+
+// NewMemoryIndexFromImages indexes tiles by their average color, the tiles
+// are named by the ids at the same positions.
+func NewMemoryIndexFromImages(ids []string, tiles []image.Image) (*MemoryIndex, error) {
+	descriptors := make([][3]float64, len(tiles))
+	for i, tile := range tiles {
+		descriptors[i] = ImageAverageRGB(tile)
+	}
+
+	return NewMemoryIndex(ids, tiles, descriptors)
+}
+
+func (m *MemoryIndex) Len() int {
+	return len(m.tiles)
+}
+
+func (m *MemoryIndex) Tile(ac [3]float64) (Tile, image.Image, error) {
+	nn, ok := m.tree.Nearest(ac)
+	if !ok {
+		return Tile{}, nil, ErrNoResult
+	}
+
+	return m.tile(nn), m.tiles[nn.Index], nil
+}
+
+func (m *MemoryIndex) Tiles(acs [][3]float64) ([]Tile, []image.Image, error) {
+	tiles := make([]Tile, len(acs))
+	imgs := make([]image.Image, len(acs))
+	for i, ac := range acs {
+		if nn, ok := m.tree.Nearest(ac); ok {
+			tiles[i], imgs[i] = m.tile(nn), m.tiles[nn.Index]
+		}
+	}
+
+	return tiles, imgs, nil
+}
+
+func (m *MemoryIndex) Match(acs [][3]float64) ([]Tile, error) {
+	tiles := make([]Tile, len(acs))
+	for i, ac := range acs {
+		if nn, ok := m.tree.Nearest(ac); ok {
+			tiles[i] = m.tile(nn)
+		}
+	}
+
+	return tiles, nil
+}
+
+func (m *MemoryIndex) tile(nn Neighbour) Tile {
+	t := m.records[nn.Index]
+	t.Set = m.Set
+	t.Distance = nn.Distance
+
+	return t
+}
+
+func (m *MemoryIndex) ImageByID(id string) (image.Image, error) {
+	i, ok := m.byID[id]
+	if !ok {
+		return nil, ErrNoResult
+	}
+
+	return m.tiles[i], nil
+}
+
+// LoadMemoryIndexFromDir decodes every file of dir as a tile, any format
+// registered with the image package is accepted. Files that do not decode are
+// left out and reported to skip, when not nil.
+func LoadMemoryIndexFromDir(dir string, skip func(name string, err error)) (*MemoryIndex, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(dirEntries))
+	tiles := make([]image.Image, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			continue
+		}
+
+		img, err := decodeFile(filepath.Join(dir, dirEntry.Name()))
+		if err != nil {
+			if skip != nil {
+				skip(dirEntry.Name(), err)
+			}
+			continue
+		}
+
+		ids = append(ids, dirEntry.Name())
+		tiles = append(tiles, img)
+	}
+
+	m, err := NewMemoryIndexFromImages(ids, tiles)
+	if err != nil {
+		return nil, err
+	}
+	m.Set = filepath.Base(dir)
+
+	return m, nil
+}
+
+func decodeFile(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// LoadMemoryIndexFromRedis copies the tile set into memory, without the
+// variants of its tiles when the set excludes them. Keys are scanned and
+// fetched in pipelines of batchSize tiles.
+func LoadMemoryIndexFromRedis(ctx context.Context, set *tilestore.Set, batchSize int) (*MemoryIndex, error) {
+	c := set.Client
+	batchSize = max(batchSize, 1)
+
+	ids := make([]string, 0)
+	tiles := make([]image.Image, 0)
+	descriptors := make([][3]float64, 0)
+	attributions := make([][2]string, 0)
+
+	iter := c.Scan(ctx, 0, set.Pattern(), int64(batchSize)).Iterator()
+	keys := make([]string, 0, batchSize)
+
+	// SCAN may return a key more than once
+	seen := make(map[string]struct{})
+
+	flush := func() error {
+		records, err := set.Records(ctx, keys)
+		if err != nil {
+			return err
+		}
+		imgs, err := set.Images(ctx, keys)
+		if err != nil {
+			return err
+		}
+
+		for i, record := range records {
+			if record == nil || imgs[i] == nil {
+				return fmt.Errorf("%s: %w", keys[i], tilestore.ErrNoTile)
+			}
+			if set.ExcludeVariants && record.Parent != "" {
+				continue
+			}
+
+			tile, err := decodeTile(imgs[i])
+			if err != nil {
+				return fmt.Errorf("%s: %w", keys[i], err)
+			}
+
+			ids = append(ids, keys[i])
+			tiles = append(tiles, tile)
+			descriptors = append(descriptors, record.Vector)
+			attributions = append(attributions, [2]string{record.Source, record.Author})
+		}
+
+		keys = keys[:0]
+		return nil
+	}
+
+	for iter.Next(ctx) {
+		if _, ok := seen[iter.Val()]; ok {
+			continue
+		}
+		seen[iter.Val()] = struct{}{}
+
+		keys = append(keys, iter.Val())
+		if len(keys) == batchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) > 0 {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	m, err := NewMemoryIndex(ids, tiles, descriptors)
+	if err != nil {
+		return nil, err
+	}
+	m.Set = set.Name
+	for i, a := range attributions {
+		m.records[i].Source, m.records[i].Author = a[0], a[1]
+	}
+
+	return m, nil
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func Test_MemoryIndexImage(t *testing.T) {
+	colors := []color.NRGBA{
+		{R: 255, A: 0xff},
+		{G: 255, A: 0xff},
+		{B: 255, A: 0xff},
+		{R: 128, G: 128, B: 128, A: 0xff},
+	}
+
+	tiles := make([]image.Image, len(colors))
+	for i, c := range colors {
+		tiles[i] = solidImage(c)
+	}
+
+	ids := []string{"red", "green", "blue", "gray"}
+	m, err := NewMemoryIndexFromImages(ids, tiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tt = []struct {
+		query    [3]float64
+		expected int
+	}{
+		{[3]float64{250, 10, 10}, 0},
+		{[3]float64{0, 200, 30}, 1},
+		{[3]float64{20, 20, 240}, 2},
+		{[3]float64{120, 140, 130}, 3},
+	}
+
+	for _, tc := range tt {
+		tile, img, err := m.Tile(tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img != tiles[tc.expected] {
+			t.Errorf("%v: expected tile %d", tc.query, tc.expected)
+		}
+
+		descriptor := ImageAverageRGB(tiles[tc.expected])
+		if tile.Descriptor != descriptor {
+			t.Errorf("%v: expected descriptor %v, got %v", tc.query, descriptor, tile.Descriptor)
+		}
+		if d := Distance(descriptor, tc.query); tile.Distance != d {
+			t.Errorf("%v: expected distance %v, got %v", tc.query, d, tile.Distance)
+		}
+	}
+
+	queries := make([][3]float64, len(tt))
+	for i, tc := range tt {
+		queries[i] = tc.query
+	}
+	records, imgs, err := m.Tiles(queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tc := range tt {
+		if imgs[i] != tiles[tc.expected] {
+			t.Errorf("batch %v: expected tile %d", tc.query, tc.expected)
+		}
+		if records[i].ID != ids[tc.expected] {
+			t.Errorf("batch %v: expected id %q, got %q", tc.query, ids[tc.expected], records[i].ID)
+		}
+	}
+
+	matches, err := m.Match(queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tc := range tt {
+		img, err := m.ImageByID(matches[i].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img != tiles[tc.expected] {
+			t.Errorf("id %q of %v: expected tile %d", matches[i].ID, tc.query, tc.expected)
+		}
+	}
+
+	if _, err = m.ImageByID("missing"); err != ErrNoResult {
+		t.Errorf("expected %v, got %v", ErrNoResult, err)
+	}
+}
+
+func Test_NewMemoryIndexEmpty(t *testing.T) {
+	if _, err := NewMemoryIndexFromImages(nil, nil); err != ErrEmptyTileSet {
+		t.Errorf("expected %v, got %v", ErrEmptyTileSet, err)
+	}
+}
+
+func Test_NewMemoryIndexDuplicateID(t *testing.T) {
+	tiles := []image.Image{solidImage(color.Black), solidImage(color.White)}
+	if _, err := NewMemoryIndexFromImages([]string{"a", "a"}, tiles); !errors.Is(err, ErrDuplicateID) {
+		t.Errorf("expected %v, got %v", ErrDuplicateID, err)
+	}
+}
+
+func Test_LoadMemoryIndexFromDir(t *testing.T) {
+	dir := t.TempDir()
+
+	for i, c := range []color.NRGBA{{R: 255, A: 0xff}, {B: 255, A: 0xff}} {
+		f, err := os.Create(filepath.Join(dir, strconv.Itoa(i)+".png"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = png.Encode(f, solidImage(c)); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a tile"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var skipped []string
+	m, err := LoadMemoryIndexFromDir(dir, func(name string, err error) {
+		skipped = append(skipped, name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != 2 {
+		t.Fatalf("expected 2 tiles, got %d", m.Len())
+	}
+	if len(skipped) != 1 || skipped[0] != "notes.txt" {
+		t.Errorf("expected notes.txt to be skipped, got %v", skipped)
+	}
+
+	tile, img, err := m.Tile([3]float64{0, 0, 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tile.ID != "1.png" || tile.Set != filepath.Base(dir) {
+		t.Errorf("expected tile 1.png of set %s, got %+v", filepath.Base(dir), tile)
+	}
+	if r, _, b, _ := img.At(0, 0).RGBA(); r != 0 || b != 0xffff {
+		t.Errorf("expected the blue tile, got %v", img.At(0, 0))
+	}
+}
+
+func solidImage(c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: c}, image.Point{}, draw.Src)
+	return img
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import "math"
+
+// Lab converts an 8 bit sRGB color, from 0 to 255 per channel, to CIE L*a*b*
+// under the D65 white point.
+func Lab(rgb [3]float64) [3]float64 {
+	var lin [3]float64
+	for i, v := range rgb {
+		v /= 255
+		if v <= 0.04045 {
+			lin[i] = v / 12.92
+		} else {
+			lin[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+
+	x := 0.4124564*lin[0] + 0.3575761*lin[1] + 0.1804375*lin[2]
+	y := 0.2126729*lin[0] + 0.7151522*lin[1] + 0.0721750*lin[2]
+	z := 0.0193339*lin[0] + 0.1191920*lin[1] + 0.9503041*lin[2]
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x/0.95047), f(y), f(z/1.08883)
+
+	return [3]float64{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
+
+// DeltaE is the CIEDE2000 color difference between two L*a*b* colors, about
+// 1 for the smallest difference noticeable side by side.
+func DeltaE(lab1, lab2 [3]float64) float64 {
+	l1, a1, b1 := lab1[0], lab1[1], lab1[2]
+	l2, a2, b2 := lab2[0], lab2[1], lab2[2]
+
+	c1 := math.Hypot(a1, b1)
+	c2 := math.Hypot(a2, b2)
+	cBar7 := math.Pow((c1+c2)/2, 7)
+	g := 0.5 * (1 - math.Sqrt(cBar7/(cBar7+math.Pow(25, 7))))
+
+	a1p, a2p := (1+g)*a1, (1+g)*a2
+	c1p, c2p := math.Hypot(a1p, b1), math.Hypot(a2p, b2)
+	h1p, h2p := hue(a1p, b1), hue(a2p, b2)
+
+	dLp := l2 - l1
+	dCp := c2p - c1p
+
+	var dhp float64
+	switch {
+	case c1p*c2p == 0:
+		dhp = 0
+	case math.Abs(h2p-h1p) <= 180:
+		dhp = h2p - h1p
+	case h2p-h1p > 180:
+		dhp = h2p - h1p - 360
+	default:
+		dhp = h2p - h1p + 360
+	}
+	dHp := 2 * math.Sqrt(c1p*c2p) * math.Sin(radians(dhp/2))
+
+	lBarP := (l1 + l2) / 2
+	cBarP := (c1p + c2p) / 2
+
+	var hBarP float64
+	switch {
+	case c1p*c2p == 0:
+		hBarP = h1p + h2p
+	case math.Abs(h1p-h2p) <= 180:
+		hBarP = (h1p + h2p) / 2
+	case h1p+h2p < 360:
+		hBarP = (h1p + h2p + 360) / 2
+	default:
+		hBarP = (h1p + h2p - 360) / 2
+	}
+
+	t := 1 - 0.17*math.Cos(radians(hBarP-30)) +
+		0.24*math.Cos(radians(2*hBarP)) +
+		0.32*math.Cos(radians(3*hBarP+6)) -
+		0.20*math.Cos(radians(4*hBarP-63))
+
+	dTheta := 30 * math.Exp(-math.Pow((hBarP-275)/25, 2))
+	cBarP7 := math.Pow(cBarP, 7)
+	rc := 2 * math.Sqrt(cBarP7/(cBarP7+math.Pow(25, 7)))
+	lBarP50 := (lBarP - 50) * (lBarP - 50)
+	sl := 1 + 0.015*lBarP50/math.Sqrt(20+lBarP50)
+	sc := 1 + 0.045*cBarP
+	sh := 1 + 0.015*cBarP*t
+	rt := -math.Sin(radians(2*dTheta)) * rc
+
+	dl, dc, dh := dLp/sl, dCp/sc, dHp/sh
+	return math.Sqrt(dl*dl + dc*dc + dh*dh + rt*dc*dh)
+}
+
+// hue is the hue angle of a, b in degrees, from 0 to 360.
+func hue(a, b float64) float64 {
+	if a == 0 && b == 0 {
+		return 0
+	}
+
+	h := math.Atan2(b, a) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
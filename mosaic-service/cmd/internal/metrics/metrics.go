@@ -0,0 +1,225 @@
+// Package metrics measures how closely an image, a mosaic, reproduces
+// another, its original.
+//
+// PSNR and SSIM compare the images pixel for pixel, on 8 bit colors
+// premultiplied by alpha, as if composited over black. DeltaE compares
+// colors as perceived, and is meant for the average colors of areas.
+package metrics
+
+import (
+	"image"
+	"math"
+
+	"github.com/ChrisShia/tilestore/pixel"
+)
+
+// PSNR is the peak signal-to-noise ratio of b against a over r, in decibels,
+// from the mean squared error of their red, green and blue channels. It is
+// infinite for identical areas and zero for empty ones.
+func PSNR(a, b image.Image, r image.Rectangle) float64 {
+	c := newComparison(r, false)
+	c.Add(a, b)
+	return c.PSNR()
+}
+
+const (
+	// ssimWindow and ssimSigma are the size and the standard deviation of
+	// the gaussian window SSIM is computed in.
+	ssimWindow = 11
+	ssimSigma  = 1.5
+
+	ssimC1 = (0.01 * 255) * (0.01 * 255)
+	ssimC2 = (0.03 * 255) * (0.03 * 255)
+)
+
+// SSIM is the mean structural similarity of the luma of b against that of a
+// over r, from 1 for identical areas down towards 0, or -1, for unrelated
+// ones. It is computed in an 11 by 11 gaussian window, of deviation 1.5, at
+// every position the window fits in r, or in a window the size of r when it
+// does not. Only as many rows as the window holds are kept in memory.
+func SSIM(a, b image.Image, r image.Rectangle) float64 {
+	c := NewComparison(r)
+	c.Add(a, b)
+	return c.SSIM()
+}
+
+// Comparison measures PSNR and SSIM of b against a over r from the rows of
+// b added top to bottom, so that b may be rendered a band at a time and never
+// be held whole.
+type Comparison struct {
+	r image.Rectangle
+	// y is the next row of r to compare.
+	y int
+
+	// noSSIM leaves SSIM out.
+	noSSIM bool
+
+	rowA, rowB []uint16
+
+	// sse is the sum of the squared errors of the channels.
+	sse float64
+
+	win    int
+	kernel []float64
+	// ring holds the last win rows of the five moments, filtered
+	// horizontally: x, y, x², y² and xy.
+	ring         [5][][]float64
+	lumaA, lumaB []float64
+	// ssim sums the similarity of the windows compared.
+	ssim float64
+}
+
+// NewComparison compares images over r.
+func NewComparison(r image.Rectangle) *Comparison {
+	return newComparison(r, true)
+}
+
+func newComparison(r image.Rectangle, ssim bool) *Comparison {
+	c := &Comparison{r: r, y: r.Min.Y, noSSIM: !ssim}
+	if r.Empty() {
+		return c
+	}
+
+	c.rowA = make([]uint16, r.Dx()*4)
+	c.rowB = make([]uint16, r.Dx()*4)
+	if !ssim {
+		return c
+	}
+
+	c.win = min(ssimWindow, r.Dx(), r.Dy())
+	c.kernel = gaussian(c.win, ssimSigma)
+	for m := range c.ring {
+		c.ring[m] = make([][]float64, c.win)
+		for i := range c.ring[m] {
+			c.ring[m][i] = make([]float64, r.Dx()-c.win+1)
+		}
+	}
+	c.lumaA = make([]float64, r.Dx())
+	c.lumaB = make([]float64, r.Dx())
+
+	return c
+}
+
+// Add compares the rows of r that b holds, from the first row not compared
+// yet on. The rows of b must follow those of the previous calls.
+func (c *Comparison) Add(a, b image.Image) {
+	if c.r.Empty() {
+		return
+	}
+
+	for ; c.y < min(b.Bounds().Max.Y, c.r.Max.Y); c.y++ {
+		pixel.Row(a, c.y, c.r.Min.X, c.r.Max.X, c.rowA)
+		pixel.Row(b, c.y, c.r.Min.X, c.r.Max.X, c.rowB)
+
+		for i := range c.rowA {
+			if i%4 == 3 {
+				continue
+			}
+			d := float64(c.rowA[i]>>8) - float64(c.rowB[i]>>8)
+			c.sse += d * d
+		}
+
+		if !c.noSSIM {
+			c.addSSIMRow(c.y - c.r.Min.Y)
+		}
+	}
+}
+
+// addSSIMRow filters row y of r, read into rowA and rowB, horizontally into
+// the ring and, once the window is full, sums the similarity of the windows
+// ending at it.
+func (c *Comparison) addSSIMRow(y int) {
+	luma(c.rowA, c.lumaA)
+	luma(c.rowB, c.lumaB)
+
+	w := len(c.ring[0][0])
+	var moments [5]float64
+
+	slot := y % c.win
+	for x := 0; x < w; x++ {
+		moments = [5]float64{}
+		for k, g := range c.kernel {
+			va, vb := c.lumaA[x+k], c.lumaB[x+k]
+			moments[0] += g * va
+			moments[1] += g * vb
+			moments[2] += g * va * va
+			moments[3] += g * vb * vb
+			moments[4] += g * va * vb
+		}
+		for m := range c.ring {
+			c.ring[m][slot][x] = moments[m]
+		}
+	}
+
+	if y < c.win-1 {
+		return
+	}
+
+	for x := 0; x < w; x++ {
+		moments = [5]float64{}
+		for k, g := range c.kernel {
+			row := (y - c.win + 1 + k) % c.win
+			for m := range c.ring {
+				moments[m] += g * c.ring[m][row][x]
+			}
+		}
+
+		muA, muB := moments[0], moments[1]
+		varA := moments[2] - muA*muA
+		varB := moments[3] - muB*muB
+		cov := moments[4] - muA*muB
+
+		c.ssim += (2*muA*muB + ssimC1) * (2*cov + ssimC2) /
+			((muA*muA + muB*muB + ssimC1) * (varA + varB + ssimC2))
+	}
+}
+
+// PSNR is PSNR of the rows added, see PSNR.
+func (c *Comparison) PSNR() float64 {
+	if c.r.Empty() {
+		return 0
+	}
+
+	mse := c.sse / float64(c.r.Dx()*(c.y-c.r.Min.Y)*3)
+	if mse == 0 {
+		return math.Inf(1)
+	}
+
+	return 10 * math.Log10(255*255/mse)
+}
+
+// SSIM is SSIM of the rows added, see SSIM.
+func (c *Comparison) SSIM() float64 {
+	rows := c.y - c.r.Min.Y - c.win + 1
+	if c.r.Empty() || rows <= 0 {
+		return 0
+	}
+
+	return c.ssim / float64(len(c.ring[0][0])*rows)
+}
+
+// gaussian is a normalized gaussian kernel of n taps.
+func gaussian(n int, sigma float64) []float64 {
+	kernel := make([]float64, n)
+	centre := float64(n-1) / 2
+
+	var sum float64
+	for i := range kernel {
+		d := float64(i) - centre
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	return kernel
+}
+
+// luma converts a row of premultiplied 16 bit pixels to 8 bit BT.601 luma.
+func luma(row []uint16, dst []float64) {
+	for x := range dst {
+		p := row[x*4:]
+		dst[x] = (0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])) / 257
+	}
+}
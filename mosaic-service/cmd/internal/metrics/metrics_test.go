@@ -0,0 +1,216 @@
+package metrics
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func Test_PSNR(t *testing.T) {
+	gray := func(v uint8) image.Image {
+		return uniform(image.Rect(0, 0, 20, 10), color.NRGBA{R: v, G: v, B: v, A: 255})
+	}
+
+	var tt = []struct {
+		name     string
+		a, b     image.Image
+		expected float64
+	}{
+		// an error of 10 on every channel is a mean squared error of 100
+		{"offset", gray(100), gray(110), 10 * math.Log10(255*255/100.0)},
+		{"black and white", gray(0), gray(255), 0},
+		{"identical", gray(37), gray(37), math.Inf(1)},
+		// only the red channel off by 30 out of three
+		{"one channel", uniform(image.Rect(0, 0, 4, 4), color.NRGBA{R: 30, A: 255}), gray(0), 10 * math.Log10(255*255/300.0)},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := PSNR(tc.a, tc.b, tc.a.Bounds()); math.Abs(got-tc.expected) > 1e-9 && got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	if got := PSNR(gray(0), gray(1), image.Rectangle{}); got != 0 {
+		t.Errorf("expected 0 for an empty area, got %v", got)
+	}
+}
+
+func Test_SSIM(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	r := image.Rect(0, 0, 48, 40)
+	noisy := noise(rnd, r)
+
+	gray := func(v uint8) image.Image {
+		return uniform(r, color.NRGBA{R: v, G: v, B: v, A: 255})
+	}
+	// uniform images have no variance, only their luminance term is left
+	luminance := func(a, b float64) float64 {
+		return (2*a*b + ssimC1) / (a*a + b*b + ssimC1)
+	}
+
+	var tt = []struct {
+		name     string
+		a, b     image.Image
+		expected float64
+	}{
+		{"identical noise", noisy, noisy, 1},
+		{"identical gray", gray(128), gray(128), 1},
+		{"uniform", gray(100), gray(150), luminance(100, 150)},
+		{"black and white", gray(0), gray(255), luminance(0, 255)},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SSIM(tc.a, tc.b, r); math.Abs(got-tc.expected) > 1e-9 {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	// noise is far less similar to its blur than the blur to itself, and more
+	// similar to it than to unrelated noise
+	blurred := blur(noisy)
+	other := noise(rnd, r)
+	self, toBlur, toOther := SSIM(blurred, blurred, r), SSIM(noisy, blurred, r), SSIM(noisy, other, r)
+	if !(self > toBlur && toBlur > toOther && math.Abs(toOther) < 0.1) {
+		t.Errorf("expected 1 > %v > %v, near 0", toBlur, toOther)
+	}
+
+	// areas smaller than the window are compared in a single window
+	small := image.Rect(3, 3, 8, 20)
+	if got := SSIM(noisy, noisy, small); math.Abs(got-1) > 1e-9 {
+		t.Errorf("expected 1 over %v, got %v", small, got)
+	}
+}
+
+// Test_ComparisonBands compares images added a band at a time as if they
+// were compared at once.
+func Test_ComparisonBands(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	a := noise(rnd, image.Rect(0, 0, 40, 37))
+	b := blur(a)
+	r := image.Rect(3, 2, 38, 35)
+
+	for _, height := range []int{1, 5, 11, 40} {
+		c := NewComparison(r)
+		for y := 0; y < 37; y += height {
+			c.Add(a, b.SubImage(image.Rect(0, y, 40, min(y+height, 37))))
+		}
+
+		if psnr, ssim := PSNR(a, b, r), SSIM(a, b, r); math.Abs(c.PSNR()-psnr) > 1e-9 || math.Abs(c.SSIM()-ssim) > 1e-9 {
+			t.Errorf("bands of %d rows: expected %v and %v, got %v and %v", height, psnr, ssim, c.PSNR(), c.SSIM())
+		}
+	}
+}
+
+func Test_Lab(t *testing.T) {
+	var tt = []struct {
+		name     string
+		rgb      [3]float64
+		expected [3]float64
+	}{
+		{"white", [3]float64{255, 255, 255}, [3]float64{100, 0, 0}},
+		{"black", [3]float64{0, 0, 0}, [3]float64{0, 0, 0}},
+		{"red", [3]float64{255, 0, 0}, [3]float64{53.2408, 80.0925, 67.2032}},
+		{"green", [3]float64{0, 255, 0}, [3]float64{87.7347, -86.1827, 83.1793}},
+		{"blue", [3]float64{0, 0, 255}, [3]float64{32.2970, 79.1875, -107.8602}},
+		{"gray", [3]float64{119, 119, 119}, [3]float64{50.0, 0, 0}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Lab(tc.rgb)
+			for i := range got {
+				if math.Abs(got[i]-tc.expected[i]) > 0.05 {
+					t.Fatalf("expected %v, got %v", tc.expected, got)
+				}
+			}
+		})
+	}
+}
+
+// Test_DeltaE checks pairs of the CIEDE2000 test data of Sharma, Wu and Dalal,
+// "The CIEDE2000 color-difference formula: implementation notes,
+// supplementary test data, and mathematical observations", 2005.
+func Test_DeltaE(t *testing.T) {
+	var tt = []struct {
+		lab1, lab2 [3]float64
+		expected   float64
+	}{
+		{[3]float64{50, 2.6772, -79.7751}, [3]float64{50, 0, -82.7485}, 2.0425},
+		{[3]float64{50, 3.1571, -77.2803}, [3]float64{50, 0, -82.7485}, 2.8615},
+		{[3]float64{50, -1, 2}, [3]float64{50, 0, 0}, 2.3669},
+		{[3]float64{50, 2.49, -0.001}, [3]float64{50, -2.49, 0.0009}, 7.1792},
+		{[3]float64{50, 2.49, -0.001}, [3]float64{50, -2.49, 0.0011}, 7.2195},
+		{[3]float64{50, -0.001, 2.49}, [3]float64{50, 0.0009, -2.49}, 4.8045},
+		{[3]float64{50, 2.5, 0}, [3]float64{73, 25, -18}, 27.1492},
+		{[3]float64{50, 2.5, 0}, [3]float64{50, 3.1736, 0.5854}, 1.0000},
+		{[3]float64{50, 2.5, 0}, [3]float64{58, 24, 15}, 19.4535},
+		{[3]float64{60.2574, -34.0099, 36.2677}, [3]float64{60.4626, -34.1751, 39.4387}, 1.2644},
+		{[3]float64{22.7233, 20.0904, -46.6940}, [3]float64{23.0331, 14.9730, -42.5619}, 2.0373},
+		{[3]float64{90.8027, -2.0831, 1.4410}, [3]float64{91.1528, -1.6435, 0.0447}, 1.4441},
+		{[3]float64{2.0776, 0.0795, -1.1350}, [3]float64{0.9033, -0.0636, -0.5514}, 0.9082},
+	}
+
+	for _, tc := range tt {
+		if got := DeltaE(tc.lab1, tc.lab2); math.Abs(got-tc.expected) > 1e-4 {
+			t.Errorf("%v %v: expected %v, got %v", tc.lab1, tc.lab2, tc.expected, got)
+		}
+		if got := DeltaE(tc.lab2, tc.lab1); math.Abs(got-tc.expected) > 1e-4 {
+			t.Errorf("%v %v: expected %v reversed, got %v", tc.lab1, tc.lab2, tc.expected, got)
+		}
+	}
+
+	if got := DeltaE(Lab([3]float64{10, 200, 30}), Lab([3]float64{10, 200, 30})); got != 0 {
+		t.Errorf("expected no difference between identical colors, got %v", got)
+	}
+}
+
+func Benchmark_SSIM(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	r := image.Rect(0, 0, 700, 700)
+	x, y := noise(rnd, r), noise(rnd, r)
+
+	for i := 0; i < b.N; i++ {
+		SSIM(x, y, r)
+	}
+}
+
+func uniform(r image.Rectangle, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func noise(rnd *rand.Rand, r image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	rnd.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+	return img
+}
+
+// blur averages every pixel of img with its right and lower neighbours.
+func blur(img *image.NRGBA) *image.NRGBA {
+	r := img.Bounds()
+	out := image.NewNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			var sum [3]int
+			for _, p := range []image.Point{{x, y}, {min(x+1, r.Max.X-1), y}, {x, min(y+1, r.Max.Y-1)}} {
+				c := img.NRGBAAt(p.X, p.Y)
+				sum[0] += int(c.R)
+				sum[1] += int(c.G)
+				sum[2] += int(c.B)
+			}
+			out.SetNRGBA(x, y, color.NRGBA{R: uint8(sum[0] / 3), G: uint8(sum[1] / 3), B: uint8(sum[2] / 3), A: 255})
+		}
+	}
+	return out
+}
@@ -0,0 +1,36 @@
+package mosaic
+
+import (
+	"encoding/hex"
+	"errors"
+	"image/color"
+	"strings"
+)
+
+var ErrInvalidBackground = errors.New("invalid background color")
+
+// ParseBackground reads the background of a request. Empty or "transparent"
+// leaves the background transparent, "#rrggbb" and "#rrggbbaa" fill it with
+// that color.
+func ParseBackground(s string) (color.Color, error) {
+	if s == "" || s == "transparent" {
+		return nil, nil
+	}
+
+	digits, ok := strings.CutPrefix(s, "#")
+	if !ok || (len(digits) != 6 && len(digits) != 8) {
+		return nil, ErrInvalidBackground
+	}
+
+	b, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, ErrInvalidBackground
+	}
+
+	c := color.NRGBA{R: b[0], G: b[1], B: b[2], A: 0xff}
+	if len(b) == 4 {
+		c.A = b[3]
+	}
+
+	return c, nil
+}
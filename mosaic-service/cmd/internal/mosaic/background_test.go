@@ -0,0 +1,139 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+
+	"github.com/ChrisShia/mosaic/cmd/internal"
+)
+
+func Test_parseBackground(t *testing.T) {
+	var tt = []struct {
+		name     string
+		expected color.Color
+		err      error
+	}{
+		{"", nil, nil},
+		{"transparent", nil, nil},
+		{"#ff8000", color.NRGBA{R: 0xff, G: 0x80, A: 0xff}, nil},
+		{"#FF800040", color.NRGBA{R: 0xff, G: 0x80, A: 0x40}, nil},
+		{"ff8000", nil, ErrInvalidBackground},
+		{"#ff80", nil, ErrInvalidBackground},
+		{"#gg8000", nil, ErrInvalidBackground},
+		{"white", nil, ErrInvalidBackground},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := ParseBackground(tc.name)
+			if err != tc.err {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+// Test_MosaicTransparentOriginal renders an original whose left half is
+// transparent white and right half translucent red. Transparent cells are left
+// to the background and translucent ones matched by their color alone, not
+// darkened towards black.
+func Test_MosaicTransparentOriginal(t *testing.T) {
+	original := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	draw.Draw(original, image.Rect(0, 0, 20, 20), image.NewUniform(color.NRGBA{R: 255, G: 255, B: 255}), point{}, draw.Src)
+	draw.Draw(original, image.Rect(20, 0, 40, 20), image.NewUniform(color.NRGBA{R: 255, A: 128}), point{}, draw.Src)
+
+	red := color.NRGBA{R: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+
+	var tt = []struct {
+		name       string
+		background color.Color
+		left       color.NRGBA
+	}{
+		{"transparent", nil, color.NRGBA{}},
+		{"blue", blue, blue},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := NewBuilder(&solidTileRepository{}, original, Options{
+				TileSize:   image.Pt(10, 10),
+				Shape:      ShapeRect,
+				Background: tc.background,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mosaic, err := b.Mosaic()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for y := 0; y < 20; y++ {
+				for x := 0; x < 40; x++ {
+					expected := tc.left
+					if x >= 20 {
+						expected = red
+					}
+					if c := color.NRGBAModel.Convert(mosaic.At(x, y)); c != expected {
+						t.Fatalf("(%d,%d): expected %v, got %v", x, y, expected, c)
+					}
+				}
+			}
+
+			if placements := b.Placements(); len(placements) != 4 {
+				t.Errorf("expected the 4 cells of the right half placed, got %d", len(placements))
+			}
+		})
+	}
+}
+
+// Test_MosaicTranslucentTiles composites translucent tiles over the
+// background.
+func Test_MosaicTranslucentTiles(t *testing.T) {
+	original := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(original, original.Bounds(), image.NewUniform(color.NRGBA{G: 255, A: 255}), point{}, draw.Src)
+
+	for _, s := range []Shape{ShapeRect, ShapeHex} {
+		t.Run(string(s), func(t *testing.T) {
+			b, err := NewBuilder(&translucentTileRepository{}, original, Options{
+				TileSize:   image.Pt(10, 10),
+				Shape:      s,
+				Edge:       EdgeCrop,
+				Background: color.White,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mosaic, err := b.Mosaic()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// half green over white
+			c := color.NRGBAModel.Convert(mosaic.At(5, 5)).(color.NRGBA)
+			if c.A != 0xff || c.G != 0xff || c.R < 126 || c.R > 128 || c.B < 126 || c.B > 128 {
+				t.Errorf("expected green blended with white, got %v", c)
+			}
+		})
+	}
+}
+
+// translucentTileRepository answers every lookup with a uniform tile of the
+// requested color at half opacity.
+type translucentTileRepository struct{}
+
+func (r *translucentTileRepository) Tile(ac [3]float64) (internal.Tile, image.Image, error) {
+	c := color.NRGBA{R: uint8(math.Round(ac[0])), G: uint8(math.Round(ac[1])), B: uint8(math.Round(ac[2])), A: 0x80}
+	img := image.NewNRGBA(image.Rect(0, 0, 30, 30))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: c}, point{}, draw.Src)
+	return internal.Tile{Descriptor: ac}, img, nil
+}
@@ -0,0 +1,81 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/ChrisShia/mosaic/cmd/internal"
+)
+
+// Test_MosaicBands renders mosaics in bands of various heights and expects
+// them to be drawn exactly as when rendered at once, cells straddling bands
+// included.
+func Test_MosaicBands(t *testing.T) {
+	original := gradient(image.Rect(0, 0, 97, 83))
+
+	var tt = []struct {
+		name       string
+		shape      Shape
+		scale      float64
+		background color.Color
+	}{
+		{"rect", ShapeRect, 1, nil},
+		{"rect scaled", ShapeRect, 1.7, nil},
+		{"brick", ShapeBrick, 1, color.White},
+		{"hex scaled", ShapeHex, 2.3, color.Black},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := Options{TileSize: image.Pt(10, 10), Shape: tc.shape, Scale: tc.scale, Background: tc.background, Workers: 1}
+
+			b, err := NewBuilder(&texturedTileRepository{}, original, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := b.Mosaic()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, height := range []int{1, 7, 64, 1000} {
+				b, err := NewBuilder(&texturedTileRepository{}, original, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				rows := 0
+				err = b.Bands(height, func(band *image.NRGBA) error {
+					if band.Bounds().Min.Y != expected.Bounds().Min.Y+rows || band.Bounds().Dx() != expected.Bounds().Dx() {
+						t.Fatalf("height %d: unexpected band %v after %d rows", height, band.Bounds(), rows)
+					}
+					rows += band.Bounds().Dy()
+
+					for y := band.Rect.Min.Y; y < band.Rect.Max.Y; y++ {
+						for x := band.Rect.Min.X; x < band.Rect.Max.X; x++ {
+							if c, e := band.At(x, y), color.NRGBAModel.Convert(expected.At(x, y)); c != e {
+								t.Fatalf("height %d: (%d,%d): expected %v, got %v", height, x, y, e, c)
+							}
+						}
+					}
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if rows != expected.Bounds().Dy() {
+					t.Errorf("height %d: expected %d rows, got %d", height, expected.Bounds().Dy(), rows)
+				}
+			}
+		})
+	}
+}
+
+// texturedTileRepository answers every lookup with the same gradient, for
+// tiles whose pixels differ across their cells.
+type texturedTileRepository struct{}
+
+func (r *texturedTileRepository) Tile(ac [3]float64) (internal.Tile, image.Image, error) {
+	return internal.Tile{Descriptor: ac}, gradient(image.Rect(0, 0, 30, 30)), nil
+}
@@ -0,0 +1,220 @@
+package mosaic
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"slices"
+
+	"github.com/ChrisShia/mosaic/cmd/internal"
+)
+
+const (
+	// coverageBucket is the width, per channel, of the color buckets cells
+	// are grouped in to find the colors the tile set covers worst, and
+	// worstBuckets the number of buckets reported.
+	coverageBucket = 32
+	worstBuckets   = 8
+
+	// heatmapMaxDistance is the distance drawn in the hottest color of the
+	// heatmap, farther matches are drawn in it too.
+	heatmapMaxDistance = 64
+)
+
+// coverageBins are the upper bounds of the bins of the distance histogram,
+// the last one the largest distance between two colors.
+var coverageBins = []float64{5, 10, 20, 40, 80, 160, 442}
+
+// CoverageReport describes how well a tile set covers the cells of an
+// original. Distances are euclidean, between the average color of a cell
+// and the descriptor of the tile matched for it, from 0 to 255 per channel.
+type CoverageReport struct {
+	Cells     int `json:"cells"`
+	Unmatched int `json:"unmatched"`
+
+	Distances distanceStats   `json:"distances"`
+	Histogram []histogramBin  `json:"histogram"`
+	Worst     []coverageWorst `json:"worst"`
+
+	// Heatmap is a base64 PNG of the original's canvas, every cell drawn
+	// from green for exact matches to red for matches heatmapMaxDistance
+	// away or more, and left transparent when unmatched.
+	Heatmap string `json:"heatmap"`
+}
+
+type distanceStats struct {
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// histogramBin counts the matches from the bound of the previous bin, or 0,
+// up to Max.
+type histogramBin struct {
+	Max   float64 `json:"max"`
+	Cells int     `json:"cells"`
+}
+
+// coverageWorst is a bucket of similar cell colors the tile set covers
+// poorly, Color is the average color of its cells.
+type coverageWorst struct {
+	Color        string  `json:"color"`
+	Cells        int     `json:"cells"`
+	MeanDistance float64 `json:"mean_distance"`
+	MaxDistance  float64 `json:"max_distance"`
+}
+
+// Coverage matches a tile to every cell, without rendering the mosaic, and
+// reports how far the tiles are from the cells they were matched for.
+func (b *Builder) Coverage() (CoverageReport, error) {
+	if err := b.lookup(); err != nil {
+		return CoverageReport{}, err
+	}
+
+	report := CoverageReport{Cells: len(b.cells)}
+	heatmap := image.NewNRGBA(b.canvas)
+
+	type bucket struct {
+		cells    int
+		sum, max float64
+		colorSum [3]float64
+		key      [3]int
+	}
+	buckets := make(map[[3]int]*bucket)
+
+	distances := make([]float64, 0, len(b.cells))
+	for i, c := range b.cells {
+		if !b.matches[i].found() {
+			report.Unmatched++
+			continue
+		}
+
+		ac := b.colors[i]
+		d := distance(ac, b.matches[i].tile.Descriptor)
+		distances = append(distances, d)
+
+		key := [3]int{int(ac[0]) / coverageBucket, int(ac[1]) / coverageBucket, int(ac[2]) / coverageBucket}
+		bk, ok := buckets[key]
+		if !ok {
+			bk = &bucket{key: key}
+			buckets[key] = bk
+		}
+		bk.cells++
+		bk.sum += d
+		bk.max = math.Max(bk.max, d)
+		for j := range ac {
+			bk.colorSum[j] += ac[j]
+		}
+
+		drawHeat(heatmap, c, d)
+	}
+
+	report.Distances = summarize(distances)
+	report.Histogram = histogram(distances)
+
+	worst := make([]*bucket, 0, len(buckets))
+	for _, bk := range buckets {
+		worst = append(worst, bk)
+	}
+	slices.SortFunc(worst, func(x, y *bucket) int {
+		return cmp.Or(
+			cmp.Compare(y.sum/float64(y.cells), x.sum/float64(x.cells)),
+			cmp.Compare(y.cells, x.cells),
+			slices.Compare(x.key[:], y.key[:]),
+		)
+	})
+	for _, bk := range worst[:min(len(worst), worstBuckets)] {
+		n := float64(bk.cells)
+		report.Worst = append(report.Worst, coverageWorst{
+			Color:        hexColor(bk.colorSum[0]/n, bk.colorSum[1]/n, bk.colorSum[2]/n),
+			Cells:        bk.cells,
+			MeanDistance: bk.sum / n,
+			MaxDistance:  bk.max,
+		})
+	}
+
+	var err error
+	if report.Heatmap, err = internal.ImageToBase64String(heatmap); err != nil {
+		return CoverageReport{}, err
+	}
+
+	return report, nil
+}
+
+func distance(a, b [3]float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return math.Sqrt(sum)
+}
+
+// summarize computes the statistics of distances, nearest rank percentiles
+// included.
+func summarize(distances []float64) distanceStats {
+	if len(distances) == 0 {
+		return distanceStats{}
+	}
+
+	sorted := slices.Sorted(slices.Values(distances))
+	percentile := func(p float64) float64 {
+		return sorted[int(math.Ceil(p*float64(len(sorted))))-1]
+	}
+
+	var sum float64
+	for _, d := range sorted {
+		sum += d
+	}
+
+	return distanceStats{
+		Mean: sum / float64(len(sorted)),
+		P50:  percentile(0.5),
+		P90:  percentile(0.9),
+		P99:  percentile(0.99),
+		Max:  sorted[len(sorted)-1],
+	}
+}
+
+// histogram counts distances in coverageBins.
+func histogram(distances []float64) []histogramBin {
+	bins := make([]histogramBin, len(coverageBins))
+	for i, bound := range coverageBins {
+		bins[i].Max = bound
+	}
+
+	for _, d := range distances {
+		i, _ := slices.BinarySearch(coverageBins, d)
+		bins[min(i, len(bins)-1)].Cells++
+	}
+
+	return bins
+}
+
+// drawHeat paints cell c of the heatmap in the color of distance d, through
+// its mask for shaped cells.
+func drawHeat(heatmap *image.NRGBA, c cell, d float64) {
+	t := min(d/heatmapMaxDistance, 1)
+
+	// green to yellow, then yellow to red
+	heat := color.NRGBA{R: 255, G: 255, A: 255}
+	if t < 0.5 {
+		heat.R = uint8(math.Round(510 * t))
+	} else {
+		heat.G = uint8(math.Round(510 * (1 - t)))
+	}
+
+	if c.Mask == nil {
+		draw.Draw(heatmap, c.Rect, image.NewUniform(heat), point{}, draw.Src)
+		return
+	}
+	draw.DrawMask(heatmap, c.Rect, image.NewUniform(heat), point{}, c.Mask, c.Mask.Bounds().Min, draw.Over)
+}
+
+func hexColor(r, g, b float64) string {
+	return fmt.Sprintf("#%02x%02x%02x", uint8(math.Round(r)), uint8(math.Round(g)), uint8(math.Round(b)))
+}
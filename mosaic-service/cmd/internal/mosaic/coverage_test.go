@@ -0,0 +1,117 @@
+package mosaic
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/ChrisShia/mosaic/cmd/internal"
+)
+
+// Test_MosaicCoverage matches a gray and red original against a tile set of
+// grays only and expects the red cells reported as covered worst.
+func Test_MosaicCoverage(t *testing.T) {
+	original := image.NewNRGBA(image.Rect(0, 0, 60, 40))
+	draw.Draw(original, original.Bounds(), image.NewUniform(color.NRGBA{R: 128, G: 128, B: 128, A: 255}), point{}, draw.Src)
+	draw.Draw(original, image.Rect(40, 0, 60, 20), image.NewUniform(color.NRGBA{R: 255, A: 255}), point{}, draw.Src)
+	// transparent cells are not matched
+	draw.Draw(original, image.Rect(0, 30, 10, 40), image.Transparent, point{}, draw.Src)
+
+	grays := &paletteTileRepository{palette: [][3]float64{{0, 0, 0}, {128, 128, 128}, {255, 255, 255}}}
+	b, err := NewBuilder(grays, original, Options{TileSize: image.Pt(10, 10), Shape: ShapeRect})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := b.Coverage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	red := distance([3]float64{255, 0, 0}, [3]float64{128, 128, 128})
+	if report.Cells != 24 || report.Unmatched != 1 {
+		t.Errorf("expected 24 cells, 1 unmatched, got %d, %d unmatched", report.Cells, report.Unmatched)
+	}
+
+	// gray averages back to itself within rounding
+	expected := distanceStats{Mean: 4 * red / 23, P50: 0, P90: red, P99: red, Max: red}
+	if math.Abs(report.Distances.Mean-expected.Mean) > 0.01 || report.Distances.P50 > 0.01 || report.Distances.P90 != red || report.Distances.Max != red {
+		t.Errorf("expected %+v, got %+v", expected, report.Distances)
+	}
+
+	total := 0
+	for _, bin := range report.Histogram {
+		total += bin.Cells
+	}
+	if report.Histogram[0].Cells != 19 || report.Histogram[len(report.Histogram)-1].Cells != 4 || total != 23 {
+		t.Errorf("expected 19 exact and 4 red matches, got %+v", report.Histogram)
+	}
+
+	if len(report.Worst) != 2 || report.Worst[0].Color != "#ff0000" || report.Worst[0].Cells != 4 || report.Worst[1].MeanDistance > 0.01 {
+		t.Errorf("expected red covered worst, got %+v", report.Worst)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(report.Heatmap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	heatmap, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tt = []struct {
+		at       image.Point
+		expected color.NRGBA
+	}{
+		{image.Pt(5, 5), color.NRGBA{G: 255, A: 255}},
+		{image.Pt(50, 5), color.NRGBA{R: 255, A: 255}},
+		{image.Pt(5, 35), color.NRGBA{}},
+	}
+	for _, tc := range tt {
+		if c := color.NRGBAModel.Convert(heatmap.At(tc.at.X, tc.at.Y)); c != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.at, tc.expected, c)
+		}
+	}
+}
+
+func Test_summarize(t *testing.T) {
+	distances := make([]float64, 100)
+	for i := range distances {
+		distances[i] = float64(100 - i)
+	}
+
+	expected := distanceStats{Mean: 50.5, P50: 50, P90: 90, P99: 99, Max: 100}
+	if got := summarize(distances); got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+	if got := summarize(nil); got != (distanceStats{}) {
+		t.Errorf("expected no statistics, got %+v", got)
+	}
+}
+
+// paletteTileRepository answers every lookup with a solid tile of the nearest
+// color of its palette.
+type paletteTileRepository struct {
+	palette [][3]float64
+}
+
+func (r *paletteTileRepository) Tile(ac [3]float64) (internal.Tile, image.Image, error) {
+	nearest := r.palette[0]
+	for _, p := range r.palette[1:] {
+		if distance(ac, p) < distance(ac, nearest) {
+			nearest = p
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 30, 30))
+	c := color.NRGBA{R: uint8(nearest[0]), G: uint8(nearest[1]), B: uint8(nearest[2]), A: 255}
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), point{}, draw.Src)
+
+	return internal.Tile{Descriptor: nearest, Distance: distance(ac, nearest)}, img, nil
+}
@@ -0,0 +1,93 @@
+package mosaic
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"io"
+
+	"github.com/ChrisShia/mosaic/cmd/internal/resample"
+)
+
+// resizeToFill scales inputImg with the filter f so that it covers size,
+// cropping the centered part that matches the aspect ratio of size first.
+func resizeToFill(inputImg image.Image, size image.Point, f resample.Filter) *image.NRGBA {
+	return resample.Resize(cropToAspect(inputImg, size), size, f)
+}
+
+// ParseFilter reads the resampling filter of a request, box filtering when
+// unset.
+func ParseFilter(s string) (resample.Filter, error) {
+	if s == "" {
+		return resample.Box, nil
+	}
+
+	return resample.ByName(s)
+}
+
+// cropToAspect copies the largest centered region of inputImg with the aspect
+// ratio of size into a new image anchored at the origin.
+func cropToAspect(inputImg image.Image, size image.Point) *image.NRGBA {
+	bounds := inputImg.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	if w*size.Y > h*size.X {
+		w = (h*size.X + size.Y/2) / size.Y
+	} else {
+		h = (w*size.Y + size.X/2) / size.X
+	}
+	w, h = max(w, 1), max(h, 1)
+
+	sp := point{X: bounds.Min.X + (bounds.Dx()-w)/2, Y: bounds.Min.Y + (bounds.Dy()-h)/2}
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(out, out.Bounds(), inputImg, sp, draw.Src)
+
+	return out
+}
+
+//func ResizeGoCV(img image.Image, scaleFactor float64, interpolation gocv.InterpolationFlags) (*image.RGBA, error) {
+//	encoder := func(w io.Writer, img image.Image) error {
+//		return png.Encode(w, img)
+//	}
+//	imgBytesBuf, err := imageToBuffer(img, encoder)
+//	if err != nil {
+//		return &image.RGBA{}, err
+//	}
+//
+//	mat, err := gocv.IMDecode(imgBytesBuf.Bytes(), gocv.IMReadColor)
+//	if err != nil {
+//		return &image.RGBA{}, err
+//	}
+//
+//	resized := gocv.NewMat()
+//	err = gocv.Resize(mat, &resized, image.Point{}, scaleFactor, scaleFactor, interpolation)
+//	if err != nil {
+//		return &image.RGBA{}, err
+//	}
+//
+//	resizedImg, err := resized.ToImage()
+//	if err != nil {
+//		return &image.RGBA{}, err
+//	}
+//
+//	var resizedRGBA *image.RGBA
+//
+//	switch resizedImg.(type) {
+//	case *image.RGBA:
+//		resizedRGBA = resizedImg.(*image.RGBA)
+//	default:
+//		return nil, errors.New("resized image is not a NRGBA")
+//	}
+//
+//	return resizedRGBA, nil
+//}
+
+func imageToBuffer(img image.Image, encoder func(io.Writer, image.Image) error) (*bytes.Buffer, error) {
+	imgBuf := new(bytes.Buffer)
+
+	err := encoder(imgBuf, img)
+	if err != nil {
+		return nil, err
+	}
+	return imgBuf, nil
+}
@@ -1,13 +1,17 @@
-package main
+package mosaic
 
 import (
+	"errors"
 	"image"
 	"image/draw"
 	"image/jpeg"
 	"image/png"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/ChrisShia/mosaic/cmd/internal/resample"
 )
 
 //NOTE: the resized images are embedded on a frame with larger dimensions.
@@ -27,13 +31,13 @@ func Test_ResizeGoCV(t *testing.T) {
 	//
 	//for _, tc := range tt {
 	//	t.Run(tc.name, func(t *testing.T) {
-	//		img_700 := pngImage("../../test/test_image_700.png")
+	//		img_700 := pngImage("testdata/original.png")
 	//		resized, err := ResizeGoCV(img_700, 0.05, tc.method)
 	//		if err != nil {
 	//			log.Fatal(err)
 	//		}
 	//
-	//		newImageFileName := fmt.Sprintf("../../test/resized_go_cv_%s.jpg", tc.name)
+	//		newImageFileName := filepath.Join(t.TempDir(), fmt.Sprintf("resized_go_cv_%s.jpg", tc.name))
 	//		dstFile, err := os.Create(newImageFileName)
 	//		if err != nil {
 	//			log.Fatal(err)
@@ -46,20 +50,46 @@ func Test_ResizeGoCV(t *testing.T) {
 	//}
 }
 
+func Test_parseFilter(t *testing.T) {
+	var tt = []struct {
+		name     string
+		expected string
+		err      error
+	}{
+		{"", "box", nil},
+		{"nearest", "nearest", nil},
+		{"bilinear", "bilinear", nil},
+		{"bicubic", "bicubic", nil},
+		{"lanczos3", "lanczos3", nil},
+		{"sinc", "", resample.ErrUnknownFilter},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := ParseFilter(tc.name)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if actual.Name != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual.Name)
+			}
+		})
+	}
+}
+
 func Test_resize(t *testing.T) {
 	t.Run("", func(t *testing.T) {
 		frame := image.NewNRGBA(image.Rect(0, 0, 100, 100))
-		img_200_300 := jpegImage("../../test/test_image_200_300.jpg")
+		img_200_300 := jpegImage("testdata/original.jpg")
 		newWidth := 20
-		imgResized := resizeByNearestNeighbour(img_200_300, newWidth)
-		tile := imgResized.SubImage(imgResized.Bounds())
+		tile := resample.Resize(img_200_300, image.Pt(newWidth, 30), resample.Nearest)
 		offSetX := 20
 		offSetY := 20
 		tileBoundsInFrame := image.Rect(offSetX, offSetY, offSetX+newWidth, offSetY+30)
 		draw.Draw(frame, tileBoundsInFrame, tile, image.Point{}, draw.Src)
-		dstFile, err := os.Create("../../test/resized_nearest_neighbour.jpg")
+		dstFile, err := os.Create(filepath.Join(t.TempDir(), "resized_nearest_neighbour.jpg"))
 		if err != nil {
-			log.Fatal(err)
+			t.Fatal(err)
 		}
 		jpeg.Encode(dstFile, frame, nil)
 	})
@@ -69,7 +99,7 @@ func Test_resize(t *testing.T) {
 func Test_ResizeByAveragePooling(t *testing.T) {
 	t.Run("", func(t *testing.T) {
 		//frame := image.NewNRGBA(image.Rect(0, 0, 100, 100))
-		//img_200_300 := jpegImage("../../test/test_image_200_300.jpg")
+		//img_200_300 := jpegImage("testdata/original.jpg")
 		//newWidth := 20
 		//imgResized := resizeByAveragePooling(img_200_300, newWidth)
 		//tile := imgResized.SubImage(imgResized.Bounds())
@@ -77,7 +107,7 @@ func Test_ResizeByAveragePooling(t *testing.T) {
 		//offSetY := 20
 		//tileBoundsInFrame := image.Rect(offSetX, offSetY, offSetX+newWidth, offSetY+30)
 		//draw.Draw(frame, tileBoundsInFrame, tile, image.Point{}, draw.Src)
-		//dstFile, err := os.Create("../../test/resized_average_pooling.jpg")
+		//dstFile, err := os.Create(filepath.Join(t.TempDir(), "resized_average_pooling.jpg"))
 		//if err != nil {
 		//	log.Fatal(err)
 		//}
@@ -0,0 +1,137 @@
+package mosaic
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images under testdata/golden")
+
+// Test_MosaicEdgesGolden renders gradients whose size is not a multiple of the
+// tile size, some with bounds not anchored at the origin, and compares them
+// with the images under testdata/golden. Run with -update to regenerate them.
+func Test_MosaicEdgesGolden(t *testing.T) {
+	var tt = []struct {
+		name     string
+		original image.Rectangle
+		opts     Options
+		expected image.Rectangle
+	}{
+		{"partial_odd", image.Rect(0, 0, 101, 67), Options{TileSize: image.Pt(10, 10), Shape: ShapeRect, Edge: EdgePartial}, image.Rect(0, 0, 101, 67)},
+		{"crop_odd", image.Rect(0, 0, 101, 67), Options{TileSize: image.Pt(10, 10), Shape: ShapeRect, Edge: EdgeCrop}, image.Rect(0, 0, 100, 60)},
+		{"pad_odd", image.Rect(0, 0, 101, 67), Options{TileSize: image.Pt(10, 10), Shape: ShapeRect, Edge: EdgePad}, image.Rect(0, 0, 110, 70)},
+		{"partial_offset", image.Rect(13, 7, 90, 71), Options{TileSize: image.Pt(12, 8), Shape: ShapeRect, Edge: EdgePartial}, image.Rect(13, 7, 90, 71)},
+		{"crop_offset", image.Rect(13, 7, 90, 71), Options{TileSize: image.Pt(12, 8), Shape: ShapeRect, Edge: EdgeCrop}, image.Rect(13, 7, 85, 71)},
+		{"pad_offset", image.Rect(13, 7, 90, 71), Options{TileSize: image.Pt(12, 8), Shape: ShapeRect, Edge: EdgePad}, image.Rect(13, 7, 97, 71)},
+		{"brick_partial_offset", image.Rect(5, 9, 96, 60), Options{TileSize: image.Pt(14, 9), Shape: ShapeBrick, Edge: EdgePartial}, image.Rect(5, 9, 96, 60)},
+		{"brick_pad_offset", image.Rect(5, 9, 96, 60), Options{TileSize: image.Pt(14, 9), Shape: ShapeBrick, Edge: EdgePad}, image.Rect(5, 9, 103, 63)},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := NewBuilder(&solidTileRepository{}, gradient(tc.original), tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mosaic, err := b.Mosaic()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if mosaic.Bounds() != tc.expected {
+				t.Fatalf("expected bounds %v, got %v", tc.expected, mosaic.Bounds())
+			}
+
+			compareGolden(t, tc.name, mosaic)
+		})
+	}
+}
+
+func Test_MosaicTooSmallForCrop(t *testing.T) {
+	_, err := NewBuilder(&solidTileRepository{}, gradient(image.Rect(0, 0, 9, 30)), Options{TileSize: image.Pt(10, 10), Shape: ShapeRect, Edge: EdgeCrop})
+	if err != ErrOriginalTooSmall {
+		t.Errorf("expected %v, got %v", ErrOriginalTooSmall, err)
+	}
+}
+
+// gradient returns an opaque image with the given bounds whose red and green
+// channels grow along x and y, so every cell averages to a different color.
+func gradient(r image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(255 * (x - r.Min.X) / r.Dx()),
+				G: uint8(255 * (y - r.Min.Y) / r.Dy()),
+				B: uint8((x * y) % 256),
+				A: 0xff,
+			})
+		}
+	}
+	return img
+}
+
+// compareGolden compares img pixel by pixel, relative to its bounds, with
+// testdata/golden/<name>.png, or rewrites the golden image when -update is
+// set.
+func compareGolden(t *testing.T, name string, img image.Image) {
+	t.Helper()
+
+	path := filepath.Join("testdata", "golden", name+".png")
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err = png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	golden, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = samePixels(golden, img); err != nil {
+		t.Errorf("%s: %v", path, err)
+	}
+}
+
+func samePixels(expected, actual image.Image) error {
+	eb, ab := expected.Bounds(), actual.Bounds()
+	if eb.Size() != ab.Size() {
+		return fmt.Errorf("expected size %v, got %v", eb.Size(), ab.Size())
+	}
+
+	for y := 0; y < eb.Dy(); y++ {
+		for x := 0; x < eb.Dx(); x++ {
+			e := color.NRGBAModel.Convert(expected.At(eb.Min.X+x, eb.Min.Y+y))
+			a := color.NRGBAModel.Convert(actual.At(ab.Min.X+x, ab.Min.Y+y))
+			if e != a {
+				return fmt.Errorf("pixel (%d,%d): expected %v, got %v", x, y, e, a)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,252 @@
+package mosaic
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+var (
+	ErrInvalidShape      = errors.New("invalid cell shape")
+	ErrInvalidTileSize   = errors.New("invalid tile size")
+	ErrInvalidEdgePolicy = errors.New("invalid edge policy")
+)
+
+type Shape string
+
+const (
+	ShapeRect  Shape = "rect"
+	ShapeBrick Shape = "brick"
+	ShapeHex   Shape = "hex"
+)
+
+// ParseShape maps the shape named in a request to a known shape. An empty
+// name selects the plain rectangular grid.
+func ParseShape(s string) (Shape, error) {
+	switch Shape(s) {
+	case "", "square", ShapeRect:
+		return ShapeRect, nil
+	case ShapeBrick, ShapeHex:
+		return Shape(s), nil
+	default:
+		return "", ErrInvalidShape
+	}
+}
+
+// cell is a single tile slot of the mosaic. Rect is the bounding box of the
+// cell in the original's frame and Mask, when set, is an alpha mask of the
+// same size restricting the painted pixels to the cell shape.
+type cell struct {
+	Rect image.Rectangle
+	Mask image.Image
+}
+
+// layout describes how cells of a given size tile a canvas. Step is the
+// period of the grid, the distance between the origins of consecutive
+// columns and rows, and Mask rasterizes the cell shape at any size, it is nil
+// for rectangular cells.
+type layout interface {
+	Cells(bounds image.Rectangle) []cell
+	Step() image.Point
+	Mask(size image.Point) image.Image
+}
+
+func newLayout(s Shape, size image.Point) (layout, error) {
+	if size.X <= 0 || size.Y <= 0 {
+		return nil, ErrInvalidTileSize
+	}
+
+	switch s {
+	case ShapeRect:
+		return rectLayout{size: size}, nil
+	case ShapeBrick:
+		return brickLayout{size: size}, nil
+	case ShapeHex:
+		if size.Y < 4 {
+			return nil, ErrInvalidTileSize
+		}
+		return hexLayout{size: size, mask: hexMask(size)}, nil
+	default:
+		return nil, ErrInvalidShape
+	}
+}
+
+// rectLayout is the plain grid of size.X by size.Y cells.
+type rectLayout struct {
+	size image.Point
+}
+
+func (l rectLayout) Step() image.Point {
+	return l.size
+}
+
+func (l rectLayout) Mask(image.Point) image.Image {
+	return nil
+}
+
+func (l rectLayout) Cells(bounds image.Rectangle) []cell {
+	cells := make([]cell, 0)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += l.size.Y {
+		for x := bounds.Min.X; x < bounds.Max.X; x += l.size.X {
+			cells = append(cells, cell{Rect: rect{Min: point{X: x, Y: y}, Max: point{X: x + l.size.X, Y: y + l.size.Y}}})
+		}
+	}
+	return cells
+}
+
+// brickLayout is a grid whose odd rows are shifted by half a cell, like a
+// running bond brick wall.
+type brickLayout struct {
+	size image.Point
+}
+
+func (l brickLayout) Step() image.Point {
+	return l.size
+}
+
+func (l brickLayout) Mask(image.Point) image.Image {
+	return nil
+}
+
+func (l brickLayout) Cells(bounds image.Rectangle) []cell {
+	cells := make([]cell, 0)
+	for row, y := 0, bounds.Min.Y; y < bounds.Max.Y; row, y = row+1, y+l.size.Y {
+		x0 := bounds.Min.X
+		if row%2 == 1 {
+			x0 -= l.size.X / 2
+		}
+		for x := x0; x < bounds.Max.X; x += l.size.X {
+			cells = append(cells, cell{Rect: rect{Min: point{X: x, Y: y}, Max: point{X: x + l.size.X, Y: y + l.size.Y}}})
+		}
+	}
+	return cells
+}
+
+// hexLayout packs pointy-top hexagons inscribed in size.X by size.Y boxes.
+// Rows overlap by a quarter of the cell height and odd rows are shifted by
+// half a cell. The first row starts a quarter cell above the canvas so the
+// notches between the top hexagons fall outside of it.
+type hexLayout struct {
+	size image.Point
+	mask *image.Alpha
+}
+
+func (l hexLayout) Step() image.Point {
+	return point{X: l.size.X, Y: l.size.Y - l.size.Y/4}
+}
+
+func (l hexLayout) Mask(size image.Point) image.Image {
+	return hexMask(size)
+}
+
+func (l hexLayout) Cells(bounds image.Rectangle) []cell {
+	cells := make([]cell, 0)
+	rowStep := l.Step().Y
+	for row, y := 0, bounds.Min.Y-l.size.Y/4; y < bounds.Max.Y; row, y = row+1, y+rowStep {
+		x0 := bounds.Min.X
+		if row%2 == 1 {
+			x0 -= l.size.X / 2
+		}
+		for x := x0; x < bounds.Max.X; x += l.size.X {
+			cells = append(cells, cell{
+				Rect: rect{Min: point{X: x, Y: y}, Max: point{X: x + l.size.X, Y: y + l.size.Y}},
+				Mask: l.mask,
+			})
+		}
+	}
+	return cells
+}
+
+// hexMask rasterizes a pointy-top hexagon filling a box of the given size.
+// Neighbouring hexagons share their slanted edges, so a pixel is inside when
+// its center lies on or within the outline, which keeps adjacent cells from
+// leaving gaps between them.
+func hexMask(size image.Point) *image.Alpha {
+	mask := image.NewAlpha(image.Rect(0, 0, size.X, size.Y))
+
+	w, h := float64(size.X), float64(size.Y)
+	q := float64(size.Y / 4)
+
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			px, py := float64(x)+0.5, float64(y)+0.5
+
+			// distance from the vertical center line, normalised to the half width
+			dx := px - w/2
+			if dx < 0 {
+				dx = -dx
+			}
+			edge := q * (dx / (w / 2))
+
+			if py+0.5 >= edge && py-0.5 <= h-edge {
+				mask.SetAlpha(x, y, color.Alpha{A: 0xff})
+			}
+		}
+	}
+
+	return mask
+}
+
+// EdgePolicy decides what happens to the strips of the original left over
+// when its size is not a multiple of the grid step.
+type EdgePolicy string
+
+const (
+	// EdgePartial keeps the size of the original and clips the last
+	// column and row of cells to it.
+	EdgePartial EdgePolicy = "partial"
+	// EdgeCrop drops the leftover strips, the mosaic only holds whole
+	// columns and rows of cells.
+	EdgeCrop EdgePolicy = "crop"
+	// EdgePad grows the mosaic to the next whole column and row, the
+	// cells hanging over the original are matched on the part they cover.
+	EdgePad EdgePolicy = "pad"
+)
+
+// ParseEdgePolicy maps the edge policy named in a request to a known policy.
+// An empty name selects EdgePartial.
+func ParseEdgePolicy(s string) (EdgePolicy, error) {
+	switch EdgePolicy(s) {
+	case "", EdgePartial:
+		return EdgePartial, nil
+	case EdgeCrop, EdgePad:
+		return EdgePolicy(s), nil
+	default:
+		return "", ErrInvalidEdgePolicy
+	}
+}
+
+// CountCells counts the cells of the mosaic of an original of the given size
+// laid out as opts tells, without matching or drawing them. Only TileSize,
+// Shape and Edge are read.
+func CountCells(size image.Point, opts Options) (int, error) {
+	l, err := newLayout(opts.Shape, opts.TileSize)
+	if err != nil {
+		return 0, err
+	}
+
+	canvas := canvasBounds(opts.Edge, image.Rectangle{Max: size}, l.Step())
+	if canvas.Empty() {
+		return 0, ErrOriginalTooSmall
+	}
+
+	return len(l.Cells(canvas)), nil
+}
+
+// canvasBounds returns the bounds of the mosaic for an original with the
+// given bounds. The canvas is anchored at bounds.Min, cropping and padding
+// only move its right and bottom edges.
+func canvasBounds(p EdgePolicy, bounds image.Rectangle, step image.Point) image.Rectangle {
+	size := bounds.Size()
+
+	switch p {
+	case EdgeCrop:
+		size.X -= size.X % step.X
+		size.Y -= size.Y % step.Y
+	case EdgePad:
+		size.X += (step.X - size.X%step.X) % step.X
+		size.Y += (step.Y - size.Y%step.Y) % step.Y
+	}
+
+	return rect{Min: bounds.Min, Max: bounds.Min.Add(size)}
+}
@@ -0,0 +1,212 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/ChrisShia/mosaic/cmd/internal/resample"
+)
+
+func Test_parseShape(t *testing.T) {
+	var tt = []struct {
+		name     string
+		expected Shape
+		err      error
+	}{
+		{"", ShapeRect, nil},
+		{"square", ShapeRect, nil},
+		{"rect", ShapeRect, nil},
+		{"brick", ShapeBrick, nil},
+		{"hex", ShapeHex, nil},
+		{"circle", "", ErrInvalidShape},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := ParseShape(tc.name)
+			if err != tc.err {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_newLayoutInvalidSize(t *testing.T) {
+	for _, size := range []image.Point{{0, 10}, {10, 0}, {-1, 10}} {
+		if _, err := newLayout(ShapeRect, size); err != ErrInvalidTileSize {
+			t.Errorf("size %v: expected %v, got %v", size, ErrInvalidTileSize, err)
+		}
+	}
+}
+
+// Test_LayoutCoverage checks that the cells of every layout, masked to their
+// shape, leave no pixel of the canvas unpainted.
+func Test_LayoutCoverage(t *testing.T) {
+	var tt = []struct {
+		name   string
+		shape  Shape
+		size   image.Point
+		bounds image.Rectangle
+	}{
+		{"rect square", ShapeRect, image.Pt(10, 10), image.Rect(0, 0, 100, 100)},
+		{"rect wide", ShapeRect, image.Pt(16, 9), image.Rect(0, 0, 101, 77)},
+		{"brick", ShapeBrick, image.Pt(20, 10), image.Rect(0, 0, 95, 63)},
+		{"hex", ShapeHex, image.Pt(20, 24), image.Rect(0, 0, 120, 90)},
+		{"hex odd", ShapeHex, image.Pt(13, 15), image.Rect(5, 7, 88, 71)},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := newLayout(tc.shape, tc.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			covered := image.NewAlpha(tc.bounds)
+			for _, c := range l.Cells(tc.bounds) {
+				if c.Rect.Size() != tc.size {
+					t.Fatalf("expected cell size %v, got %v", tc.size, c.Rect.Size())
+				}
+				for y := c.Rect.Min.Y; y < c.Rect.Max.Y; y++ {
+					for x := c.Rect.Min.X; x < c.Rect.Max.X; x++ {
+						if c.Mask != nil {
+							mp := c.Mask.Bounds().Min.Add(image.Pt(x, y).Sub(c.Rect.Min))
+							if _, _, _, a := c.Mask.At(mp.X, mp.Y).RGBA(); a == 0 {
+								continue
+							}
+						}
+						covered.SetAlpha(x, y, color.Alpha{A: 0xff})
+					}
+				}
+			}
+
+			for y := tc.bounds.Min.Y; y < tc.bounds.Max.Y; y++ {
+				for x := tc.bounds.Min.X; x < tc.bounds.Max.X; x++ {
+					if covered.AlphaAt(x, y).A == 0 {
+						t.Fatalf("pixel (%d,%d) is not covered by any cell", x, y)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_hexMask(t *testing.T) {
+	mask := hexMask(image.Pt(20, 20))
+
+	var tt = []struct {
+		p      image.Point
+		inside bool
+	}{
+		{image.Pt(10, 10), true},
+		{image.Pt(10, 0), true},
+		{image.Pt(0, 10), true},
+		{image.Pt(0, 0), false},
+		{image.Pt(19, 19), false},
+		{image.Pt(0, 19), false},
+	}
+
+	for _, tc := range tt {
+		inside := mask.AlphaAt(tc.p.X, tc.p.Y).A != 0
+		if inside != tc.inside {
+			t.Errorf("%v: expected inside=%t, got %t", tc.p, tc.inside, inside)
+		}
+	}
+}
+
+func Test_resizeToFill(t *testing.T) {
+	var tt = []struct {
+		name string
+		src  image.Rectangle
+		size image.Point
+	}{
+		{"portrait to square", image.Rect(0, 0, 200, 300), image.Pt(10, 10)},
+		{"portrait to wide", image.Rect(0, 0, 200, 300), image.Pt(20, 10)},
+		{"landscape to tall", image.Rect(0, 0, 300, 200), image.Pt(10, 30)},
+		{"upscale", image.Rect(0, 0, 8, 8), image.Pt(20, 12)},
+		{"offset bounds", image.Rect(50, 70, 250, 370), image.Pt(12, 12)},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, f := range resample.Filters {
+				resized := resizeToFill(image.NewNRGBA(tc.src), tc.size, f)
+				if b := resized.Bounds(); b != image.Rect(0, 0, tc.size.X, tc.size.Y) {
+					t.Errorf("%s: expected the resized tile to be %v at the origin, got %v", f.Name, tc.size, b)
+				}
+			}
+		})
+	}
+}
+
+func Test_canvasBounds(t *testing.T) {
+	var tt = []struct {
+		name     string
+		policy   EdgePolicy
+		bounds   image.Rectangle
+		step     image.Point
+		expected image.Rectangle
+	}{
+		{"partial", EdgePartial, image.Rect(0, 0, 101, 67), image.Pt(10, 10), image.Rect(0, 0, 101, 67)},
+		{"crop", EdgeCrop, image.Rect(0, 0, 101, 67), image.Pt(10, 10), image.Rect(0, 0, 100, 60)},
+		{"pad", EdgePad, image.Rect(0, 0, 101, 67), image.Pt(10, 10), image.Rect(0, 0, 110, 70)},
+		{"pad exact", EdgePad, image.Rect(0, 0, 100, 60), image.Pt(10, 10), image.Rect(0, 0, 100, 60)},
+		{"crop offset", EdgeCrop, image.Rect(13, 7, 90, 71), image.Pt(12, 8), image.Rect(13, 7, 85, 71)},
+		{"pad offset", EdgePad, image.Rect(-5, -5, 20, 20), image.Pt(10, 15), image.Rect(-5, -5, 25, 25)},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := canvasBounds(tc.policy, tc.bounds, tc.step)
+			if actual != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_CountCells(t *testing.T) {
+	var tt = []struct {
+		name     string
+		size     image.Point
+		opts     Options
+		expected int
+	}{
+		{"exact", image.Pt(100, 60), Options{TileSize: image.Pt(10, 10), Shape: ShapeRect}, 60},
+		{"partial", image.Pt(101, 67), Options{TileSize: image.Pt(10, 10), Shape: ShapeRect}, 77},
+		{"crop", image.Pt(101, 67), Options{TileSize: image.Pt(10, 10), Shape: ShapeRect, Edge: EdgeCrop}, 60},
+		{"pad", image.Pt(101, 67), Options{TileSize: image.Pt(10, 10), Shape: ShapeRect, Edge: EdgePad}, 77},
+		{"rect", image.Pt(100, 60), Options{TileSize: image.Pt(20, 10), Shape: ShapeRect}, 30},
+		{"brick", image.Pt(100, 40), Options{TileSize: image.Pt(20, 10), Shape: ShapeBrick}, 2*5 + 2*6},
+		{"hex", image.Pt(40, 40), Options{TileSize: image.Pt(20, 20), Shape: ShapeHex}, 2*2 + 1*3},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := CountCells(tc.size, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %d, got %d", tc.expected, actual)
+			}
+
+			b, err := NewBuilder(nil, image.NewNRGBA(image.Rectangle{Max: tc.size}), tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b.cells = b.layout.Cells(b.canvas)
+			if b.Cells() != actual {
+				t.Errorf("expected the %d cells of the builder, got %d", b.Cells(), actual)
+			}
+		})
+	}
+
+	if _, err := CountCells(image.Pt(5, 5), Options{TileSize: image.Pt(10, 10), Shape: ShapeRect, Edge: EdgeCrop}); err != ErrOriginalTooSmall {
+		t.Errorf("expected %v, got %v", ErrOriginalTooSmall, err)
+	}
+}
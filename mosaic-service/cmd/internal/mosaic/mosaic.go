@@ -0,0 +1,541 @@
+// Package mosaic renders mosaics: it cuts an original into cells of a shape,
+// matches each cell to the tile of a repository closest to its average color
+// and draws the tiles, at once or in bands of rows. It is shared by the
+// mosaic service and the offline mode of the gosaic command.
+package mosaic
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"runtime"
+	"sync"
+
+	"github.com/ChrisShia/mosaic/cmd/internal"
+	"github.com/ChrisShia/mosaic/cmd/internal/resample"
+)
+
+var (
+	ErrInvalidTilesRepository = errors.New("invalid tiles repository")
+	ErrOriginalTooSmall       = errors.New("original is smaller than a single tile")
+	ErrInvalidOutputScale     = errors.New("invalid output scale")
+	ErrRenderPanic            = errors.New("panic while rendering")
+)
+
+// maxOutputScale bounds how much larger than the original a mosaic may be
+// rendered.
+const maxOutputScale = 32
+
+type Builder struct {
+	tiles       internal.TileRepository
+	originalImg image.Image
+	tileSize    image.Point
+	layout      layout
+	cells       []cell
+	matches     []match
+
+	// colors are the average colors of the cells, parallel to cells, those
+	// of the unmatched cells are unset.
+	colors [][3]float64
+
+	// mosaicImg is the mosaic rendered by the last call to Mosaic, Bands
+	// renders without it. bandHeight is the height of the bands of the last
+	// call to Bands, zero after Mosaic.
+	mosaicImg  draw.Image
+	bandHeight int
+
+	// canvas is the area of the original covered by cells, scale the factor
+	// from it to the mosaic and bounds the bounds of the mosaic.
+	canvas rect
+	scale  float64
+	bounds rect
+
+	// workers is the number of goroutines painting cells, GOMAXPROCS when
+	// zero.
+	workers int
+
+	// cache holds resized tiles of keyed repositories, nil disables it.
+	cache *TileCache
+
+	// filter resamples the tiles to the cell sizes.
+	filter resample.Filter
+
+	// background fills the mosaic before drawing, nil leaves it transparent.
+	background color.Color
+
+	// mu serializes drawing masked cells, shaped layouts overlap their
+	// neighbours' bounding boxes. Rectangular cells never overlap and are
+	// drawn without it.
+	mu sync.Mutex
+
+	// masks holds the cell masks rasterized at the rendered cell sizes,
+	// which vary by a pixel when scale is fractional.
+	masksMu sync.Mutex
+	masks   map[image.Point]image.Image
+}
+
+type Options struct {
+	TileSize image.Point
+	Shape    Shape
+	Edge     EdgePolicy
+
+	// Scale is the ratio between the size of the rendered mosaic and the
+	// sampled area of the original, zero means 1. Cells still sample
+	// TileSize pixels of the original but are drawn Scale times larger.
+	Scale float64
+	// OutputWidth, when set, overrides Scale with the one rendering the
+	// mosaic that many pixels wide.
+	OutputWidth int
+
+	// Filter resamples the tiles to the cell sizes, resample.Box when
+	// unset.
+	Filter resample.Filter
+
+	// Background fills the mosaic before the tiles are drawn over it, showing
+	// through empty cells, the gaps between shaped cells and translucent
+	// tiles. Nil leaves them transparent.
+	Background color.Color
+
+	// Workers is the number of goroutines painting cells, zero means
+	// GOMAXPROCS.
+	Workers int
+
+	// Cache, when set and the repository is keyed, holds the resized tiles
+	// across renders.
+	Cache *TileCache
+}
+
+func NewBuilder(tiles internal.TileRepository, originalImg image.Image, opts Options) (*Builder, error) {
+	l, err := newLayout(opts.Shape, opts.TileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	canvas := canvasBounds(opts.Edge, originalImg.Bounds(), l.Step())
+	if canvas.Empty() {
+		return nil, ErrOriginalTooSmall
+	}
+
+	scale := opts.Scale
+	if opts.OutputWidth != 0 {
+		scale = float64(opts.OutputWidth) / float64(canvas.Dx())
+	}
+	if scale == 0 {
+		scale = 1
+	}
+	if scale < 0 || scale > maxOutputScale {
+		return nil, ErrInvalidOutputScale
+	}
+
+	filter := opts.Filter
+	if filter.Name == "" {
+		filter = resample.Box
+	}
+
+	return &Builder{
+		tiles:       tiles,
+		originalImg: originalImg,
+		tileSize:    opts.TileSize,
+		layout:      l,
+		canvas:      canvas,
+		scale:       scale,
+		workers:     opts.Workers,
+		cache:       opts.Cache,
+		filter:      filter,
+		background:  opts.Background,
+		bounds:      scaleRect(canvas, scale),
+		masks:       make(map[image.Point]image.Image),
+	}, nil
+}
+
+// scaleRect maps r from the original's frame to the mosaic's. Both edges are
+// rounded down, so cells sharing an edge in the original still share it in
+// the mosaic.
+func scaleRect(r rect, scale float64) rect {
+	if scale == 1 {
+		return r
+	}
+
+	// the epsilon keeps products like 101 * (1000 / 101) from rounding
+	// down a whole pixel
+	f := func(v int) int {
+		return int(math.Floor(float64(v)*scale + 1e-9))
+	}
+
+	return image.Rect(f(r.Min.X), f(r.Min.Y), f(r.Max.X), f(r.Max.Y))
+}
+
+// Mosaic renders the whole mosaic at once.
+func (b *Builder) Mosaic() (image.Image, error) {
+	if err := b.lookup(); err != nil {
+		return nil, err
+	}
+
+	mosaicImg := image.NewNRGBA(b.bounds)
+	b.mosaicImg = mosaicImg
+	b.bandHeight = 0
+	b.fill(mosaicImg)
+
+	if err := b.render(mosaicImg, b.matches); err != nil {
+		return nil, err
+	}
+
+	return mosaicImg, nil
+}
+
+// Bands renders the mosaic in bands of height rows, top to bottom, passing
+// each to f, so that only a band of the mosaic is held in memory. Bands share
+// their pixels, f must be done with a band when it returns. Cells straddling
+// two bands are drawn in both, clipped to each.
+func (b *Builder) Bands(height int, f func(band *image.NRGBA) error) error {
+	if err := b.lookup(); err != nil {
+		return err
+	}
+
+	b.mosaicImg = nil
+	b.bandHeight = max(height, 1)
+
+	return b.bands(b.bandHeight, f)
+}
+
+// bands renders the tiles matched in bands of height rows, see Bands.
+func (b *Builder) bands(height int, f func(band *image.NRGBA) error) error {
+	height = max(min(height, b.bounds.Dy()), 1)
+	buf := image.NewNRGBA(image.Rect(0, 0, b.bounds.Dx(), height))
+
+	for y := b.bounds.Min.Y; y < b.bounds.Max.Y; y += height {
+		r := image.Rect(b.bounds.Min.X, y, b.bounds.Max.X, min(y+height, b.bounds.Max.Y))
+		band := &image.NRGBA{Pix: buf.Pix[:r.Dy()*buf.Stride], Stride: buf.Stride, Rect: r}
+
+		b.fill(band)
+		if err := b.render(band, b.matches); err != nil {
+			return err
+		}
+		if err := f(band); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Size is the size of the rendered mosaic.
+func (b *Builder) Size() image.Point {
+	return b.bounds.Size()
+}
+
+// Cells is the number of cells of the mosaic, zero until it is rendered.
+func (b *Builder) Cells() int {
+	return len(b.cells)
+}
+
+// lookup lays the cells out and matches a tile to each.
+func (b *Builder) lookup() error {
+	if b.tiles == nil {
+		return ErrInvalidTilesRepository
+	}
+
+	b.cells = b.layout.Cells(b.canvas)
+
+	tiles, err := b.lookupTiles()
+	if err != nil {
+		return err
+	}
+	b.matches = tiles
+
+	return nil
+}
+
+// fill clears img to the background.
+func (b *Builder) fill(img *image.NRGBA) {
+	if b.background == nil {
+		clear(img.Pix)
+		return
+	}
+
+	draw.Draw(img, img.Bounds(), image.NewUniform(b.background), point{}, draw.Src)
+}
+
+// Placement is a tile drawn in the mosaic, X0, Y0, X1 and Y1 bound the part
+// of the mosaic it covers.
+type Placement struct {
+	X0   int           `json:"x0"`
+	Y0   int           `json:"y0"`
+	X1   int           `json:"x1"`
+	Y1   int           `json:"y1"`
+	Tile internal.Tile `json:"tile"`
+}
+
+// Placements lists the tiles drawn by the last call to Mosaic or Bands, in
+// cell order. Cells of shaped layouts are reported by their bounding boxes.
+func (b *Builder) Placements() []Placement {
+	bounds := b.bounds
+
+	placements := make([]Placement, 0, len(b.matches))
+	for i, m := range b.matches {
+		r := b.outRect(b.cells[i]).Intersect(bounds)
+		if !m.found() || r.Empty() {
+			continue
+		}
+
+		placements = append(placements, Placement{X0: r.Min.X, Y0: r.Min.Y, X1: r.Max.X, Y1: r.Max.Y, Tile: m.tile})
+	}
+
+	return placements
+}
+
+// match is the tile found for a cell. Tiles of keyed repositories come
+// without img and are fetched by ID through the tile cache when drawn, those
+// of the others come decoded.
+type match struct {
+	tile internal.Tile
+	img  image.Image
+}
+
+func (m match) found() bool {
+	return m.tile.ID != "" || m.img != nil
+}
+
+// lookupTiles resolves a tile for every cell, the result is parallel to
+// b.cells. All cell colors are averaged first, from a summed-area table of
+// the original, identical colors are looked up once and the distinct ones are
+// resolved in a single batch. Cells without a tile are left unmatched.
+func (b *Builder) lookupTiles() ([]match, error) {
+	sums := internal.NewSummedArea(b.originalImg)
+
+	colors := make([][3]float64, len(b.cells))
+	found := make([]bool, len(b.cells))
+	b.forEach(len(b.cells), func(i int) {
+		colors[i], found[i] = b.cellColor(sums, b.cells[i])
+	})
+
+	memo := make(map[[3]float64]int)
+	distinct := make([][3]float64, 0)
+	index := make([]int, len(b.cells))
+	for i, c := range colors {
+		if !found[i] {
+			index[i] = -1
+			continue
+		}
+
+		j, ok := memo[c]
+		if !ok {
+			j = len(distinct)
+			memo[c] = j
+			distinct = append(distinct, c)
+		}
+		index[i] = j
+	}
+
+	matches, err := b.lookupDistinct(distinct)
+	if err != nil {
+		return nil, err
+	}
+
+	b.colors = colors
+
+	tiles := make([]match, len(b.cells))
+	for i, j := range index {
+		if j >= 0 {
+			tiles[i] = matches[j]
+		}
+	}
+
+	return tiles, nil
+}
+
+// lookupDistinct resolves acs by tile id when the tiles can be cached and to
+// decoded tiles otherwise.
+func (b *Builder) lookupDistinct(acs [][3]float64) ([]match, error) {
+	matches := make([]match, len(acs))
+
+	if keyed, ok := b.tiles.(internal.KeyedTileRepository); ok && b.cache != nil {
+		tiles, err := keyed.Match(acs)
+		if err != nil {
+			return nil, err
+		}
+		for i, tile := range tiles {
+			matches[i].tile = tile
+		}
+		return matches, nil
+	}
+
+	tiles, imgs, err := internal.Tiles(b.tiles, acs, b.workerCount())
+	if err != nil {
+		return nil, err
+	}
+	for i := range matches {
+		matches[i] = match{tile: tiles[i], img: imgs[i]}
+	}
+
+	return matches, nil
+}
+
+// render paints the tiles of every cell overlapping dst, tiles being parallel
+// to b.cells. The cells are drained by b.workers goroutines drawing straight
+// into dst. A panic while painting a cell is recovered, the remaining cells
+// are still painted and the first panic is returned once all workers are
+// done.
+func (b *Builder) render(dst drawer, tiles []match) error {
+	bounds := dst.Bounds()
+
+	var once sync.Once
+	var panicErr error
+
+	b.forEach(len(b.cells), func(i int) {
+		c := b.cells[i]
+		if !tiles[i].found() || !b.outRect(c).Overlaps(bounds) {
+			return
+		}
+
+		if err := b.safePutTileAt(c, tiles[i], dst); err != nil {
+			once.Do(func() { panicErr = err })
+		}
+	})
+
+	return panicErr
+}
+
+// forEach calls f for 0 <= i < n from b.workers goroutines.
+func (b *Builder) forEach(n int, f func(i int)) {
+	queue := make(chan int, n)
+	for i := 0; i < n; i++ {
+		queue <- i
+	}
+	close(queue)
+
+	var wg sync.WaitGroup
+	for w := b.workerCount(); w > 0; w-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				f(i)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (b *Builder) workerCount() int {
+	if b.workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return b.workers
+}
+
+// safePutTileAt paints a single cell, turning a panic into an error.
+func (b *Builder) safePutTileAt(c cell, tile match, dst drawer) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: cell %v: %v", ErrRenderPanic, c.Rect, r)
+		}
+	}()
+
+	return b.putTileAt(c, tile, dst)
+}
+
+type drawer interface {
+	draw.Image
+}
+
+// putTileAt draws the tile matched for the cell at the cell's place in the
+// mosaic.
+func (b *Builder) putTileAt(c cell, tile match, dst drawer) error {
+	out := b.outRect(c)
+
+	resizedImg, err := b.resizedTile(tile, out.Size())
+	if err != nil {
+		return err
+	}
+
+	b.drawTile(resizedImg, cell{Rect: out, Mask: b.cellMask(c, out.Size())}, dst)
+
+	return nil
+}
+
+// resizedTile returns the tile of m resized to size, from the tile cache for
+// the tiles matched without their image.
+func (b *Builder) resizedTile(m match, size image.Point) (image.Image, error) {
+	if m.img != nil {
+		return b.resize(size, m.img), nil
+	}
+
+	return b.cache.get(tileKey{id: m.tile.ID, size: size, filter: b.filter.Name}, func() (*image.NRGBA, error) {
+		img, err := b.tiles.(internal.KeyedTileRepository).ImageByID(m.tile.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return b.resize(size, img), nil
+	})
+}
+
+// outRect is the rectangle a cell is drawn at in the mosaic.
+func (b *Builder) outRect(c cell) rect {
+	return scaleRect(c.Rect, b.scale)
+}
+
+// cellMask returns the mask of the cell shape at the given size.
+func (b *Builder) cellMask(c cell, size image.Point) image.Image {
+	if c.Mask == nil || c.Mask.Bounds().Size() == size {
+		return c.Mask
+	}
+
+	b.masksMu.Lock()
+	defer b.masksMu.Unlock()
+
+	m, ok := b.masks[size]
+	if !ok {
+		m = b.layout.Mask(size)
+		b.masks[size] = m
+	}
+
+	return m
+}
+
+// cellColor averages the part of the cell that lies within the original,
+// weighted by alpha. It reports false for cells entirely outside of it or
+// entirely transparent, which are left empty.
+func (b *Builder) cellColor(sums *internal.SummedArea, c cell) ([3]float64, bool) {
+	r := c.Rect.Intersect(b.originalImg.Bounds())
+	if r.Empty() {
+		return [3]float64{}, false
+	}
+
+	avg, coverage := sums.AverageRGBAArea(r.Min.X, r.Max.X, r.Min.Y, r.Max.Y)
+	return avg, coverage > 0
+}
+
+type point = image.Point
+type rect = image.Rectangle
+
+// drawTile composites tileImg over the cell, masked to the cell shape if it
+// has one.
+func (b *Builder) drawTile(tileImg image.Image, c cell, dst drawer) {
+	if c.Mask == nil {
+		// rectangular cells do not overlap, opaque tiles can replace the
+		// background
+		op := draw.Over
+		if o, ok := tileImg.(interface{ Opaque() bool }); ok && o.Opaque() {
+			op = draw.Src
+		}
+		draw.Draw(dst, c.Rect, tileImg, tileImg.Bounds().Min, op)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	draw.DrawMask(dst, c.Rect, tileImg, tileImg.Bounds().Min, c.Mask, c.Mask.Bounds().Min, draw.Over)
+}
+
+// resize scales img to cover a cell of the given size, cropping whatever
+// sticks out once the aspect ratios differ.
+func (b *Builder) resize(size image.Point, img image.Image) *image.NRGBA {
+	return resizeToFill(img, size, b.filter)
+}
@@ -0,0 +1,688 @@
+package mosaic
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ChrisShia/mosaic/cmd/internal"
+	"github.com/ChrisShia/mosaic/cmd/internal/resample"
+)
+
+func Test_Mosaic(t *testing.T) {
+	decode := func(file *os.File) (image.Image, error) {
+		return png.Decode(file)
+	}
+
+	img := internal.ImageDecodeFunc("testdata/original.png", decode)
+
+	b, err := NewBuilder(mockWithAverageInfiniteTileRepository_, img, Options{TileSize: image.Pt(10, 10), Shape: ShapeRect})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	mosaic, err := b.Mosaic()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	resultFile, err := os.Create(filepath.Join(t.TempDir(), "mosaic.png"))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer resultFile.Close()
+
+	err = png.Encode(resultFile, mosaic)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+}
+
+func Test_MosaicShapes(t *testing.T) {
+	original := image.NewNRGBA(image.Rect(0, 0, 120, 90))
+	draw.Draw(original, original.Bounds(), &image.Uniform{C: color.NRGBA{R: 200, G: 100, B: 50, A: 0xff}}, point{}, draw.Src)
+
+	for _, s := range []Shape{ShapeRect, ShapeBrick, ShapeHex} {
+		t.Run(string(s), func(t *testing.T) {
+			b, err := NewBuilder(&solidTileRepository{}, original, Options{TileSize: image.Pt(20, 12), Shape: s})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mosaic, err := b.Mosaic()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bounds := mosaic.Bounds()
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				for x := bounds.Min.X; x < bounds.Max.X; x++ {
+					if c := color.NRGBAModel.Convert(mosaic.At(x, y)).(color.NRGBA); c != original.NRGBAAt(x, y) {
+						t.Fatalf("(%d,%d): expected %v, got %v", x, y, original.NRGBAAt(x, y), c)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_MosaicOutputScale(t *testing.T) {
+	original := gradient(image.Rect(0, 0, 60, 40))
+	opts := Options{TileSize: image.Pt(10, 10), Shape: ShapeRect}
+
+	b, err := NewBuilder(&solidTileRepository{}, original, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unscaled, err := b.Mosaic()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts.Scale = 3
+	b, err = NewBuilder(&solidTileRepository{}, original, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scaled, err := b.Mosaic()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := image.Rect(0, 0, 180, 120); scaled.Bounds() != expected {
+		t.Fatalf("expected bounds %v, got %v", expected, scaled.Bounds())
+	}
+
+	for y := 0; y < 120; y++ {
+		for x := 0; x < 180; x++ {
+			if scaled.At(x, y) != unscaled.At(x/3, y/3) {
+				t.Fatalf("(%d,%d): expected %v, got %v", x, y, unscaled.At(x/3, y/3), scaled.At(x, y))
+			}
+		}
+	}
+}
+
+func Test_MosaicOutputWidth(t *testing.T) {
+	var tt = []struct {
+		name     string
+		original image.Rectangle
+		opts     Options
+		expected image.Rectangle
+	}{
+		{"rect", image.Rect(0, 0, 101, 67), Options{TileSize: image.Pt(10, 10), Shape: ShapeRect, OutputWidth: 1000}, image.Rect(0, 0, 1000, 663)},
+		{"hex", image.Rect(0, 0, 80, 60), Options{TileSize: image.Pt(12, 12), Shape: ShapeHex, OutputWidth: 200}, image.Rect(0, 0, 200, 150)},
+		{"brick offset", image.Rect(10, 10, 90, 50), Options{TileSize: image.Pt(16, 8), Shape: ShapeBrick, OutputWidth: 120}, image.Rect(15, 15, 135, 75)},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := NewBuilder(&solidTileRepository{}, gradient(tc.original), tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mosaic, err := b.Mosaic()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if mosaic.Bounds() != tc.expected {
+				t.Fatalf("expected bounds %v, got %v", tc.expected, mosaic.Bounds())
+			}
+
+			for y := tc.expected.Min.Y; y < tc.expected.Max.Y; y++ {
+				for x := tc.expected.Min.X; x < tc.expected.Max.X; x++ {
+					if _, _, _, a := mosaic.At(x, y).RGBA(); a == 0 {
+						t.Fatalf("pixel (%d,%d) was left empty", x, y)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_NewMosaicBuilderInvalidScale(t *testing.T) {
+	for _, scale := range []float64{-1, maxOutputScale + 1} {
+		_, err := NewBuilder(&solidTileRepository{}, gradient(image.Rect(0, 0, 10, 10)), Options{TileSize: image.Pt(5, 5), Shape: ShapeRect, Scale: scale})
+		if err != ErrInvalidOutputScale {
+			t.Errorf("scale %v: expected %v, got %v", scale, ErrInvalidOutputScale, err)
+		}
+	}
+}
+
+func Test_renderRecoversPanics(t *testing.T) {
+	original := gradient(image.Rect(0, 0, 40, 40))
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			b, err := NewBuilder(&brokenTileRepository{at: 3}, original, Options{TileSize: image.Pt(10, 10), Shape: ShapeRect, Workers: workers})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = b.Mosaic()
+			if !errors.Is(err, ErrRenderPanic) {
+				t.Fatalf("expected %v, got %v", ErrRenderPanic, err)
+			}
+
+			painted := 0
+			for _, c := range b.cells {
+				if _, _, _, a := b.mosaicImg.At(c.Rect.Min.X, c.Rect.Min.Y).RGBA(); a != 0 {
+					painted++
+				}
+			}
+			if painted != len(b.cells)-1 {
+				t.Errorf("expected all cells but the panicking one painted, got %d of %d", painted, len(b.cells))
+			}
+		})
+	}
+}
+
+func Test_MosaicLookupPanic(t *testing.T) {
+	b, err := NewBuilder(&panickingTileRepository{at: 3}, gradient(image.Rect(0, 0, 40, 40)), Options{TileSize: image.Pt(10, 10), Shape: ShapeRect})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = b.Mosaic()
+	if !errors.Is(err, internal.ErrLookupPanic) {
+		t.Fatalf("expected %v, got %v", internal.ErrLookupPanic, err)
+	}
+}
+
+func Test_lookupTilesMemo(t *testing.T) {
+	original := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(original, image.Rect(0, 0, 100, 50), &image.Uniform{C: color.NRGBA{R: 255, A: 0xff}}, point{}, draw.Src)
+	draw.Draw(original, image.Rect(0, 50, 100, 100), &image.Uniform{C: color.NRGBA{B: 255, A: 0xff}}, point{}, draw.Src)
+
+	repo := &countingTileRepository{}
+	b, err := NewBuilder(repo, original, Options{TileSize: image.Pt(10, 10), Shape: ShapeRect})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = b.Mosaic(); err != nil {
+		t.Fatal(err)
+	}
+
+	if repo.calls.Load() != 2 {
+		t.Errorf("expected 2 lookups for 2 distinct colors, got %d", repo.calls.Load())
+	}
+}
+
+func Test_MosaicPlacements(t *testing.T) {
+	original := image.NewNRGBA(image.Rect(0, 0, 100, 50))
+	draw.Draw(original, image.Rect(0, 0, 50, 50), &image.Uniform{C: color.NRGBA{R: 255, A: 0xff}}, point{}, draw.Src)
+	draw.Draw(original, image.Rect(50, 0, 100, 50), &image.Uniform{C: color.NRGBA{B: 255, A: 0xff}}, point{}, draw.Src)
+
+	tiles := []image.Image{original.SubImage(image.Rect(0, 0, 10, 10)), original.SubImage(image.Rect(90, 40, 100, 50))}
+	repo, err := internal.NewMemoryIndexFromImages([]string{"red", "blue"}, tiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cache := range []*TileCache{nil, NewTileCache(1 << 20)} {
+		b, err := NewBuilder(repo, original, Options{TileSize: image.Pt(10, 10), Shape: ShapeRect, Scale: 2, Cache: cache})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = b.Mosaic(); err != nil {
+			t.Fatal(err)
+		}
+
+		placements := b.Placements()
+		if len(placements) != 50 {
+			t.Fatalf("expected 50 placements, got %d", len(placements))
+		}
+
+		for _, p := range placements {
+			if p.X1-p.X0 != 20 || p.Y1-p.Y0 != 20 {
+				t.Errorf("expected 20x20 placements, got %+v", p)
+			}
+
+			expected := "red"
+			if p.X0 >= 100 {
+				expected = "blue"
+			}
+			if p.Tile.ID != expected || p.Tile.Distance != 0 {
+				t.Errorf("expected an exact match with %s at %d,%d, got %+v", expected, p.X0, p.Y0, p.Tile)
+			}
+		}
+	}
+}
+
+func Benchmark_Mosaic(b *testing.B) {
+	original := gradient(image.Rect(0, 0, 700, 700))
+
+	// zero workers renders with GOMAXPROCS goroutines
+	for _, workers := range []int{1, 2, 4, 0} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				mb, err := NewBuilder(&solidTileRepository{}, original, Options{TileSize: image.Pt(10, 10), Shape: ShapeRect, Workers: workers})
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err = mb.Mosaic(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Benchmark_MosaicLookups compares resolving cells one lookup at a time with
+// resolving them in batches, against a repository charging a fixed round trip
+// per call. The gradient has a distinct color per cell, the flat original a
+// single one.
+func Benchmark_MosaicLookups(b *testing.B) {
+	const roundTrip = 200 * time.Microsecond
+
+	originals := map[string]image.Image{
+		"gradient": gradient(image.Rect(0, 0, 700, 700)),
+		"flat":     image.NewNRGBA(image.Rect(0, 0, 700, 700)),
+	}
+
+	repos := map[string]func() internal.TileRepository{
+		"single": func() internal.TileRepository {
+			return &latencyTileRepository{delay: roundTrip}
+		},
+		"batched": func() internal.TileRepository {
+			return &batchLatencyTileRepository{latencyTileRepository{delay: roundTrip}, 256, 4}
+		},
+	}
+
+	for originalName, original := range originals {
+		for repoName, newRepo := range repos {
+			b.Run(originalName+"/"+repoName, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					mb, err := NewBuilder(newRepo(), original, Options{TileSize: image.Pt(10, 10), Shape: ShapeRect})
+					if err != nil {
+						b.Fatal(err)
+					}
+					if _, err = mb.Mosaic(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func Test_render(t *testing.T) {
+	bounds := image.Rect(0, 0, 400, 400)
+	nrgbaImg := image.NewNRGBA(bounds)
+
+	gi := &gridImage{img: nrgbaImg}
+	b := &Builder{tiles: mockTileRepository_, originalImg: gi, tileSize: image.Pt(40, 40), layout: rectLayout{size: image.Pt(40, 40)}, canvas: bounds, scale: 1}
+	b.cells = b.layout.Cells(bounds)
+
+	tiles, err := b.lookupTiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = b.render(gi, tiles); err != nil {
+		t.Fatal(err)
+	}
+
+	gi.Grid(40)
+	saveInTestDir(t, "testRender", gi)
+}
+
+func Test_drawTileHexCell(t *testing.T) {
+	bounds := image.Rect(0, 0, 40, 40)
+	nrgbaImg := image.NewNRGBA(bounds)
+
+	b := &Builder{tiles: &solidTileRepository{}, originalImg: nrgbaImg}
+
+	_, tileImage, _ := b.tiles.Tile([3]float64{255, 0, 0})
+
+	size := image.Pt(20, 20)
+	c := cell{Rect: image.Rect(10, 10, 30, 30), Mask: hexMask(size)}
+	b.drawTile(resizeToFill(tileImage, size, resample.Box), c, nrgbaImg)
+
+	if a := nrgbaImg.NRGBAAt(20, 20).A; a != 0xff {
+		t.Errorf("expected the cell center to be painted, got alpha %d", a)
+	}
+	if a := nrgbaImg.NRGBAAt(10, 10).A; a != 0 {
+		t.Errorf("expected the cell corner to be masked out, got alpha %d", a)
+	}
+	if a := nrgbaImg.NRGBAAt(5, 20).A; a != 0 {
+		t.Errorf("expected pixels outside the cell to be untouched, got alpha %d", a)
+	}
+}
+
+func Test_drawTile(t *testing.T) {
+	testName := "drawTile"
+	bounds := image.Rect(0, 0, 400, 400)
+	nrgbaImg := image.NewNRGBA(bounds)
+
+	result := &gridImage{img: nrgbaImg}
+	b := &Builder{tiles: mockTileRepository_, originalImg: result}
+
+	_, tileImage, _ := b.tiles.Tile([3]float64{0, 0, 0})
+
+	b.drawTile(tileImage, cell{Rect: bounds}, result)
+
+	result.Grid(20)
+
+	saveInTestDir(t, testName, nrgbaImg)
+}
+
+func saveInTestDir(t *testing.T, testName string, nrgbaImg image.Image) {
+	resultFile, err := os.Create(filepath.Join(t.TempDir(), testName+".png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resultFile.Close()
+	err = png.Encode(resultFile, nrgbaImg)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type MockImage struct {
+	bounds     image.Rectangle
+	color      [][]color.Color
+	colorModel color.Model
+}
+
+func (mi *MockImage) ColorModel() color.Model {
+	return mi.colorModel
+}
+
+func (mi *MockImage) Bounds() image.Rectangle {
+	return mi.bounds
+}
+
+func (mi *MockImage) At(x, y int) color.Color {
+	return mi.color[x][y]
+}
+
+func (mi *MockImage) Set(x, y int, c color.Color) {
+	mi.color[x][y] = c
+}
+
+func Test_grid(t *testing.T) {
+	grid := gridImage{img: image.NewNRGBA(image.Rect(0, 0, 401, 401))}
+	grid.Grid(20)
+	file, _ := os.Create(filepath.Join(t.TempDir(), "grid.png"))
+	defer file.Close()
+	err := png.Encode(file, grid.img)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type gridImage struct {
+	img *image.NRGBA
+}
+
+func (gi *gridImage) ColorModel() color.Model {
+	return gi.img.ColorModel()
+}
+
+func (gi *gridImage) Bounds() image.Rectangle {
+	return gi.img.Bounds()
+}
+
+func (gi *gridImage) At(x, y int) color.Color {
+	return gi.img.At(x, y)
+}
+
+func (gi *gridImage) Set(x, y int, c color.Color) {
+	gi.img.Set(x, y, c)
+}
+
+func (gi *gridImage) Grid(tileWidth int) {
+	for x := 0; x < gi.img.Bounds().Dx(); x = x + tileWidth {
+		for y := 0; y < gi.img.Bounds().Dy(); y++ {
+			gi.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	for y := 0; y <= gi.img.Bounds().Dy(); y = y + tileWidth {
+		for x := 0; x < gi.img.Bounds().Dx(); x++ {
+			gi.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+}
+
+type MockRandomInfiniteTileRepository struct {
+	images []image.Image
+	len    int
+}
+
+func (mi *MockRandomInfiniteTileRepository) Tile(ac [3]float64) (internal.Tile, image.Image, error) {
+	return internal.Tile{}, mi.Random(ac), nil
+}
+
+func (mi *MockRandomInfiniteTileRepository) Random(ac [3]float64) image.Image {
+	randomIndex := rand.Intn(mi.len)
+
+	return mi.images[randomIndex]
+}
+
+type MockWithAverageInfiniteTileRepository struct {
+	images     []image.Image
+	len        int
+	searchFunc func([3]float64) float64
+}
+
+func (m *MockWithAverageInfiniteTileRepository) Tile(ac [3]float64) (internal.Tile, image.Image, error) {
+	randomIndex := rand.Intn(m.len)
+
+	searchAverage := m.searchFunc(ac)
+
+	increment := func(i int) int {
+		if i == m.len-1 {
+			return 0
+		}
+		return i + 1
+	}
+
+	for i := increment(randomIndex); ; i = increment(i) {
+		img := m.images[i]
+		imgAve := internal.ImageAverageRGB(img)
+		if math.Abs(m.searchFunc(imgAve)-searchAverage) < 10 {
+			return internal.Tile{}, img, nil
+		}
+
+		if i == randomIndex {
+			break
+		}
+	}
+
+	return internal.Tile{}, m.images[randomIndex], nil
+}
+
+type MockTileRepository struct {
+	images []image.Image
+}
+
+func (m *MockTileRepository) Pop() image.Image {
+	popped := m.images[0]
+	m.images = m.images[1:]
+	return popped
+}
+
+func (m *MockTileRepository) Tile(ac [3]float64) (internal.Tile, image.Image, error) {
+	return internal.Tile{}, m.Pop(), nil
+}
+
+// solidTileRepository answers every lookup with a uniform tile of the
+// requested color, so tests relying on it need no tile images on disk.
+type solidTileRepository struct{}
+
+func (s *solidTileRepository) Tile(ac [3]float64) (internal.Tile, image.Image, error) {
+	c := color.NRGBA{R: uint8(math.Round(ac[0])), G: uint8(math.Round(ac[1])), B: uint8(math.Round(ac[2])), A: 0xff}
+	img := image.NewNRGBA(image.Rect(0, 0, 30, 30))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: c}, point{}, draw.Src)
+	return internal.Tile{Descriptor: ac}, img, nil
+}
+
+// brokenTileRepository behaves like solidTileRepository but hands out a tile
+// panicking when drawn on its lookup number at.
+type brokenTileRepository struct {
+	solidTileRepository
+	at    int32
+	calls atomic.Int32
+}
+
+func (r *brokenTileRepository) Tile(ac [3]float64) (internal.Tile, image.Image, error) {
+	if r.calls.Add(1) == r.at {
+		return internal.Tile{}, panickingImage{}, nil
+	}
+	return r.solidTileRepository.Tile(ac)
+}
+
+type panickingImage struct{}
+
+func (panickingImage) ColorModel() color.Model { return color.NRGBAModel }
+func (panickingImage) Bounds() image.Rectangle { return image.Rect(0, 0, 30, 30) }
+func (panickingImage) At(x, y int) color.Color { panic("broken tile") }
+
+// countingTileRepository behaves like solidTileRepository and counts its
+// lookups.
+type countingTileRepository struct {
+	solidTileRepository
+	calls atomic.Int32
+}
+
+func (r *countingTileRepository) Tile(ac [3]float64) (internal.Tile, image.Image, error) {
+	r.calls.Add(1)
+	return r.solidTileRepository.Tile(ac)
+}
+
+// latencyTileRepository behaves like solidTileRepository but sleeps for
+// delay on every call, standing in for a network round trip.
+type latencyTileRepository struct {
+	solidTileRepository
+	delay time.Duration
+}
+
+func (r *latencyTileRepository) Tile(ac [3]float64) (internal.Tile, image.Image, error) {
+	time.Sleep(r.delay)
+	return r.solidTileRepository.Tile(ac)
+}
+
+// batchLatencyTileRepository resolves lookups in batches of batchSize with up
+// to concurrency batches in flight, paying delay once per batch like a
+// pipeline does.
+type batchLatencyTileRepository struct {
+	latencyTileRepository
+	batchSize   int
+	concurrency int
+}
+
+func (r *batchLatencyTileRepository) Tiles(acs [][3]float64) ([]internal.Tile, []image.Image, error) {
+	tiles := make([]internal.Tile, len(acs))
+	imgs := make([]image.Image, len(acs))
+
+	sem := make(chan struct{}, r.concurrency)
+	var wg sync.WaitGroup
+	for start := 0; start < len(acs); start += r.batchSize {
+		end := min(start+r.batchSize, len(acs))
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			time.Sleep(r.delay)
+			for i := start; i < end; i++ {
+				tiles[i], imgs[i], _ = r.solidTileRepository.Tile(acs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	return tiles, imgs, nil
+}
+
+// panickingTileRepository behaves like solidTileRepository but panics on
+// its lookup number at.
+type panickingTileRepository struct {
+	solidTileRepository
+	at    int32
+	calls atomic.Int32
+}
+
+func (p *panickingTileRepository) Tile(ac [3]float64) (internal.Tile, image.Image, error) {
+	if p.calls.Add(1) == p.at {
+		panic("tile repository failure")
+	}
+	return p.solidTileRepository.Tile(ac)
+}
+
+// lazyTileRepository defers building the wrapped repository to the first
+// lookup, so tests that do not use the tile images on disk can run without
+// them.
+type lazyTileRepository struct {
+	once sync.Once
+	new  func() internal.TileRepository
+	repo internal.TileRepository
+}
+
+func (l *lazyTileRepository) Tile(ac [3]float64) (internal.Tile, image.Image, error) {
+	l.once.Do(func() { l.repo = l.new() })
+	return l.repo.Tile(ac)
+}
+
+var mockTileRepository_ internal.TileRepository = &lazyTileRepository{new: NewMockTileRepository}
+var mockRandomInfiniteTileRepository_ internal.TileRepository = &lazyTileRepository{new: NewMockRandomInfiniteTileRepository}
+var mockWithAverageInfiniteTileRepository_ internal.TileRepository = &lazyTileRepository{new: NewMockWithAverageInfiniteTileRepository}
+
+func NewMockTileRepository() internal.TileRepository {
+	return &MockTileRepository{images: images()}
+}
+
+func NewMockRandomInfiniteTileRepository() internal.TileRepository {
+	list := images()
+	return &MockRandomInfiniteTileRepository{images: list, len: len(list)}
+}
+
+func NewMockWithAverageInfiniteTileRepository() internal.TileRepository {
+	list := images()
+	return &MockWithAverageInfiniteTileRepository{
+		images: list,
+		len:    len(list),
+		searchFunc: func(c [3]float64) float64 {
+			return math.Sqrt(c[0]*c[0] + c[1]*c[1] + c[2]*c[2])
+		},
+	}
+}
+
+func images() []image.Image {
+	list := make([]image.Image, 0)
+
+	dirEntries, err := os.ReadDir(tilesTestSrcDir)
+	if err != nil {
+		panic(err)
+	}
+
+	decode := func(file *os.File) (image.Image, error) {
+		return jpeg.Decode(file)
+	}
+
+	for _, dirEntry := range dirEntries {
+		img := internal.ImageDecodeFunc(filepath.Join(tilesTestSrcDir, dirEntry.Name()), decode)
+		list = append(list, img)
+	}
+	return list
+}
@@ -0,0 +1,135 @@
+package mosaic
+
+import (
+	"cmp"
+	"image"
+	"math"
+
+	"github.com/ChrisShia/mosaic/cmd/internal"
+	"github.com/ChrisShia/mosaic/cmd/internal/metrics"
+	"github.com/ChrisShia/tilestore"
+	"github.com/ChrisShia/tilestore/pixel"
+)
+
+// maxPSNR stands for the infinite PSNR of a mosaic identical to its original,
+// which JSON cannot hold.
+const maxPSNR = 100
+
+// Quality measures how closely a mosaic reproduces its original. PSNR and SSIM
+// compare them pixel for pixel, DeltaE the average colors of the cells that
+// were given a tile.
+type Quality struct {
+	PSNR   float64       `json:"psnr"`
+	SSIM   float64       `json:"ssim"`
+	DeltaE deltaEQuality `json:"delta_e"`
+}
+
+// deltaEQuality sums up the CIEDE2000 differences between the average colors
+// of the cells in the original and in the mosaic.
+type deltaEQuality struct {
+	Mean  float64 `json:"mean"`
+	Max   float64 `json:"max"`
+	Cells int     `json:"cells"`
+}
+
+// referenceBandHeight is the height of the bands the mosaic is rendered
+// again in for Quality when it was rendered whole at another scale.
+const referenceBandHeight = 256
+
+// Quality compares the mosaic matched by the last call to Mosaic or Bands
+// with the original, at the size of the original: the mosaic is rendered
+// again at scale 1 unless it already was, a band at a time. Only the part of
+// the canvas within the original is compared.
+func (b *Builder) Quality() (Quality, error) {
+	area := b.canvas.Intersect(b.originalImg.Bounds())
+	comparison := metrics.NewComparison(area)
+
+	// sums are the colors of the matched cells in the mosaic, summed over the
+	// bands they straddle
+	sums := make([]tilestore.ColorSum, len(b.cells))
+	row := make([]uint16, area.Dx()*4)
+
+	err := b.referenceBands(func(band *image.NRGBA) error {
+		comparison.Add(b.originalImg, band)
+
+		for i, c := range b.cells {
+			r := c.Rect.Intersect(area).Intersect(band.Rect)
+			if !b.matches[i].found() || r.Empty() {
+				continue
+			}
+
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				pixels := row[:r.Dx()*4]
+				pixel.Row(band, y, r.Min.X, r.Max.X, pixels)
+				for k := 0; k < len(pixels); k += 4 {
+					sums[i].Add(pixels[k], pixels[k+1], pixels[k+2], pixels[k+3])
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return Quality{}, err
+	}
+
+	q := Quality{
+		PSNR: min(comparison.PSNR(), maxPSNR),
+		SSIM: comparison.SSIM(),
+	}
+
+	originalSums := internal.NewSummedArea(b.originalImg)
+
+	var sum float64
+	for i, c := range b.cells {
+		r := c.Rect.Intersect(area)
+		if !b.matches[i].found() || r.Empty() {
+			continue
+		}
+
+		original, covered := originalSums.AverageRGBAArea(r.Min.X, r.Max.X, r.Min.Y, r.Max.Y)
+		if covered == 0 {
+			continue
+		}
+		mosaic, _ := sums[i].Average(uint64(r.Dx()) * uint64(r.Dy()))
+
+		d := metrics.DeltaE(metrics.Lab(original), metrics.Lab(mosaic))
+		sum += d
+		q.DeltaE.Max = math.Max(q.DeltaE.Max, d)
+		q.DeltaE.Cells++
+	}
+	if q.DeltaE.Cells > 0 {
+		q.DeltaE.Mean = sum / float64(q.DeltaE.Cells)
+	}
+
+	return q, nil
+}
+
+// referenceBands passes the mosaic rendered at scale 1, over the canvas in
+// the frame of the original, to f top to bottom. A mosaic Mosaic rendered at
+// scale 1 is passed whole, others are rendered again in bands as high as those
+// of the last call to Bands, or referenceBandHeight.
+func (b *Builder) referenceBands(f func(band *image.NRGBA) error) error {
+	if img, ok := b.mosaicImg.(*image.NRGBA); ok && b.scale == 1 {
+		return f(img)
+	}
+
+	r := &Builder{
+		tiles:       b.tiles,
+		originalImg: b.originalImg,
+		tileSize:    b.tileSize,
+		layout:      b.layout,
+		cells:       b.cells,
+		matches:     b.matches,
+		canvas:      b.canvas,
+		scale:       1,
+		bounds:      b.canvas,
+		workers:     b.workers,
+		cache:       b.cache,
+		filter:      b.filter,
+		background:  b.background,
+		masks:       make(map[image.Point]image.Image),
+	}
+
+	return r.bands(cmp.Or(b.bandHeight, referenceBandHeight), f)
+}
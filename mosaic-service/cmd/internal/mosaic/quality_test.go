@@ -0,0 +1,151 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+
+	"github.com/ChrisShia/mosaic/cmd/internal"
+)
+
+func Test_MosaicQuality(t *testing.T) {
+	uniform := image.NewNRGBA(image.Rect(0, 0, 60, 40))
+	draw.Draw(uniform, uniform.Bounds(), image.NewUniform(color.NRGBA{R: 200, G: 100, B: 50, A: 255}), point{}, draw.Src)
+
+	var tt = []struct {
+		name     string
+		tiles    internal.TileRepository
+		original image.Image
+		scale    float64
+		// expected bounds on PSNR, SSIM and the mean Delta E
+		minPSNR, minSSIM, maxDeltaE float64
+	}{
+		// every cell is drawn in the exact color of the original
+		{"uniform", &solidTileRepository{}, uniform, 1, maxPSNR, 1, 1e-9},
+		{"uniform scaled", &solidTileRepository{}, uniform, 2.5, maxPSNR, 1, 1e-9},
+		// solid cells lose the detail of the gradient but keep its colors
+		{"gradient", &solidTileRepository{}, gradient(image.Rect(0, 0, 60, 40)), 1, 12, 0.3, 1},
+		// the same gradient in every cell gets the colors wrong
+		{"textured", &texturedTileRepository{}, gradient(image.Rect(0, 0, 60, 40)), 1, 0, -1, 100},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := NewBuilder(tc.tiles, tc.original, Options{TileSize: image.Pt(10, 10), Shape: ShapeRect, Scale: tc.scale})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = b.Mosaic(); err != nil {
+				t.Fatal(err)
+			}
+
+			q, err := b.Quality()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q.PSNR < tc.minPSNR || q.SSIM < tc.minSSIM-1e-9 || q.DeltaE.Mean > tc.maxDeltaE {
+				t.Errorf("expected a PSNR of at least %v, an SSIM of at least %v and a mean Delta E of at most %v, got %+v",
+					tc.minPSNR, tc.minSSIM, tc.maxDeltaE, q)
+			}
+			if q.DeltaE.Cells != 24 || q.DeltaE.Max < q.DeltaE.Mean {
+				t.Errorf("expected the Delta E of 24 cells, got %+v", q.DeltaE)
+			}
+		})
+	}
+}
+
+// Test_MosaicQualityBands measures mosaics rendered in bands and at other
+// scales as if they were rendered at once at scale 1.
+func Test_MosaicQualityBands(t *testing.T) {
+	original := gradient(image.Rect(0, 0, 73, 51))
+	opts := Options{TileSize: image.Pt(8, 8), Shape: ShapeHex, Background: color.White}
+
+	b, err := NewBuilder(&texturedTileRepository{}, original, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = b.Mosaic(); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := b.Quality()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts.Scale = 3
+	if b, err = NewBuilder(&texturedTileRepository{}, original, opts); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Bands(20, func(*image.NRGBA) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	got, err := b.Quality()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != expected || math.IsNaN(got.SSIM) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+// Test_MosaicQualityStreamed measures a mosaic streamed in bands, as PNG
+// output is, without rendering its reference in more than a band at a time.
+func Test_MosaicQualityStreamed(t *testing.T) {
+	original := gradient(image.Rect(0, 0, 73, 51))
+	opts := Options{TileSize: image.Pt(8, 8), Shape: ShapeBrick}
+
+	var tt = []struct {
+		name  string
+		scale float64
+	}{
+		{"scale 1", 1},
+		{"scaled", 2},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := NewBuilder(&texturedTileRepository{}, original, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = b.Mosaic(); err != nil {
+				t.Fatal(err)
+			}
+			expected, err := b.Quality()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			opts := opts
+			opts.Scale = tc.scale
+			if b, err = NewBuilder(&texturedTileRepository{}, original, opts); err != nil {
+				t.Fatal(err)
+			}
+			const height = 7
+			if err = b.Bands(height, func(*image.NRGBA) error { return nil }); err != nil {
+				t.Fatal(err)
+			}
+
+			err = b.referenceBands(func(band *image.NRGBA) error {
+				if band.Rect.Dy() > height {
+					t.Fatalf("expected bands of at most %d rows, got %v", height, band.Rect)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := b.Quality()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != expected || got.DeltaE.Cells == 0 {
+				t.Errorf("expected %+v, got %+v", expected, got)
+			}
+		})
+	}
+}
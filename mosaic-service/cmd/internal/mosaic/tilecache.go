@@ -0,0 +1,149 @@
+package mosaic
+
+import (
+	"container/list"
+	"image"
+	"image/draw"
+	"sync"
+	"sync/atomic"
+)
+
+// tileKey identifies a tile resized for a cell size with a resampling
+// filter.
+type tileKey struct {
+	id     string
+	size   image.Point
+	filter string
+}
+
+// TileCache is an LRU cache of decoded tiles resized to the cell sizes they
+// were drawn at, shared by every render. Its capacity is the number of pixel
+// bytes it holds. Cached tiles are shared and must not be drawn into.
+type TileCache struct {
+	capacity int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List
+	entries map[tileKey]*list.Element
+
+	// loading holds the tiles being decoded, concurrent misses of the same
+	// key wait for the first one instead of decoding the tile again.
+	loading map[tileKey]*tileLoad
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type tileCacheEntry struct {
+	key tileKey
+	img *image.NRGBA
+}
+
+type tileLoad struct {
+	done chan struct{}
+	img  *image.NRGBA
+	err  error
+}
+
+type TileCacheStats struct {
+	Hits    int64
+	Misses  int64
+	Entries int
+	Bytes   int64
+}
+
+func NewTileCache(capacity int64) *TileCache {
+	return &TileCache{
+		capacity: capacity,
+		lru:      list.New(),
+		entries:  make(map[tileKey]*list.Element),
+		loading:  make(map[tileKey]*tileLoad),
+	}
+}
+
+// get returns the tile cached under key, calling load to produce it on a miss.
+// Tiles larger than the whole cache are returned without being cached.
+func (c *TileCache) get(key tileKey, load func() (*image.NRGBA, error)) (*image.NRGBA, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return e.Value.(*tileCacheEntry).img, nil
+	}
+	if l, ok := c.loading[key]; ok {
+		c.mu.Unlock()
+		c.hits.Add(1)
+		<-l.done
+		return l.img, l.err
+	}
+
+	l := &tileLoad{done: make(chan struct{})}
+	c.loading[key] = l
+	c.mu.Unlock()
+	c.misses.Add(1)
+
+	// a panicking load still has to release the goroutines waiting on it
+	defer func() {
+		c.mu.Lock()
+		delete(c.loading, key)
+		if l.err == nil && l.img != nil {
+			c.add(key, l.img)
+		}
+		c.mu.Unlock()
+		close(l.done)
+	}()
+
+	// reported to the waiters if load panics
+	l.err = ErrRenderPanic
+	l.img, l.err = load()
+
+	return l.img, l.err
+}
+
+// add stores img and evicts the least recently used tiles until the cache
+// fits its capacity again. c.mu must be held.
+func (c *TileCache) add(key tileKey, img *image.NRGBA) {
+	n := int64(len(img.Pix))
+	if n > c.capacity {
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&tileCacheEntry{key: key, img: img})
+	c.size += n
+
+	for c.size > c.capacity {
+		e := c.lru.Back()
+		entry := e.Value.(*tileCacheEntry)
+		c.lru.Remove(e)
+		delete(c.entries, entry.key)
+		c.size -= int64(len(entry.img.Pix))
+	}
+}
+
+func (c *TileCache) Stats() TileCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return TileCacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: c.lru.Len(),
+		Bytes:   c.size,
+	}
+}
+
+// toNRGBA returns img as an *image.NRGBA with its origin at (0, 0), copying
+// it when it is of another type.
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Rect.Min == (image.Point{}) {
+		return nrgba
+	}
+
+	b := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, b.Min, draw.Src)
+
+	return nrgba
+}
@@ -0,0 +1,154 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ChrisShia/mosaic/cmd/internal"
+)
+
+func Test_tileCacheLRU(t *testing.T) {
+	// room for two 10x10 tiles
+	c := NewTileCache(2 * 10 * 10 * 4)
+
+	loads := 0
+	load := func() (*image.NRGBA, error) {
+		loads++
+		return image.NewNRGBA(image.Rect(0, 0, 10, 10)), nil
+	}
+	key := func(id string) tileKey {
+		return tileKey{id: id, size: image.Pt(10, 10)}
+	}
+
+	var tt = []struct {
+		id    string
+		loads int
+	}{
+		{"a", 1},
+		{"b", 2},
+		{"a", 2},
+		// evicts b, the least recently used
+		{"c", 3},
+		{"a", 3},
+		{"b", 4},
+	}
+
+	for _, tc := range tt {
+		if _, err := c.get(key(tc.id), load); err != nil {
+			t.Fatal(err)
+		}
+		if loads != tc.loads {
+			t.Fatalf("after %s: expected %d loads, got %d", tc.id, tc.loads, loads)
+		}
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 4 {
+		t.Errorf("expected 2 hits and 4 misses, got %+v", stats)
+	}
+	if stats.Entries != 2 || stats.Bytes != 800 {
+		t.Errorf("expected 2 entries of 800 bytes, got %+v", stats)
+	}
+}
+
+func Test_tileCacheOversized(t *testing.T) {
+	c := NewTileCache(100)
+
+	for i := 0; i < 2; i++ {
+		_, err := c.get(tileKey{id: "a"}, func() (*image.NRGBA, error) {
+			return image.NewNRGBA(image.Rect(0, 0, 10, 10)), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if stats := c.Stats(); stats.Misses != 2 || stats.Entries != 0 {
+		t.Errorf("expected oversized tiles not to be cached, got %+v", stats)
+	}
+}
+
+func Test_tileCacheConcurrentMisses(t *testing.T) {
+	c := NewTileCache(1 << 20)
+
+	var loads atomic.Int64
+	release := make(chan struct{})
+	load := func() (*image.NRGBA, error) {
+		loads.Add(1)
+		<-release
+		return image.NewNRGBA(image.Rect(0, 0, 10, 10)), nil
+	}
+
+	var wg sync.WaitGroup
+	imgs := make([]*image.NRGBA, 8)
+	for i := range imgs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			imgs[i], _ = c.get(tileKey{id: "a"}, load)
+		}()
+	}
+	close(release)
+	wg.Wait()
+
+	if loads.Load() != 1 {
+		t.Errorf("expected a single load, got %d", loads.Load())
+	}
+	for _, img := range imgs {
+		if img != imgs[0] {
+			t.Fatal("expected every caller to get the same tile")
+		}
+	}
+}
+
+func Test_MosaicTileCache(t *testing.T) {
+	original := gradient(image.Rect(0, 0, 200, 200))
+
+	colors := []color.NRGBA{{R: 255, A: 0xff}, {G: 255, A: 0xff}, {B: 255, A: 0xff}}
+	ids := []string{"red", "green", "blue"}
+	tiles := make([]image.Image, len(colors))
+	for i, c := range colors {
+		tile := image.NewNRGBA(image.Rect(0, 0, 30, 30))
+		draw.Draw(tile, tile.Bounds(), &image.Uniform{C: c}, point{}, draw.Src)
+		tiles[i] = tile
+	}
+
+	repo, err := internal.NewMemoryIndexFromImages(ids, tiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	render := func(cache *TileCache) image.Image {
+		b, err := NewBuilder(repo, original, Options{TileSize: image.Pt(10, 10), Shape: ShapeRect, Cache: cache})
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := b.Mosaic()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+
+	expected := render(nil)
+
+	cache := NewTileCache(1 << 20)
+	for i := 0; i < 2; i++ {
+		if err := samePixels(expected, render(cache)); err != nil {
+			t.Fatalf("render %d: cached mosaic differs from the uncached one: %v", i, err)
+		}
+	}
+
+	// 400 cells per render, all drawn at the same size
+	stats := cache.Stats()
+	if stats.Misses > int64(len(tiles)) {
+		t.Errorf("expected at most %d misses, got %d", len(tiles), stats.Misses)
+	}
+	if stats.Hits+stats.Misses != 800 {
+		t.Errorf("expected 800 lookups, got %+v", stats)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package mosaic
 
 import (
 	"bytes"
@@ -8,8 +8,8 @@ import (
 	"path/filepath"
 )
 
-const tilesTestSrcDir = "Downloads"
-const originImg = "test/original/img.jpeg"
+const tilesTestSrcDir = "testdata/tiles"
+const originImg = "testdata/original.jpg"
 
 func imgStream(in <-chan []byte) <-chan image.Image {
 	out := make(chan image.Image)
@@ -0,0 +1,256 @@
+// Package pngstream encodes PNG images from their rows, fed top to bottom in
+// bands, so that images too large to hold in memory can be written as they
+// are rendered.
+//
+// Images are written as 8 bit non-premultiplied RGBA, each row filtered with
+// the filter that minimizes the sum of its absolute differences, as
+// image/png does.
+package pngstream
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"io"
+)
+
+var (
+	ErrRowWidth  = errors.New("pngstream: band width does not match the image")
+	ErrRowCount  = errors.New("pngstream: rows do not match the image height")
+	ErrEmptySize = errors.New("pngstream: empty image")
+)
+
+// bpp is the number of bytes per pixel.
+const bpp = 4
+
+const (
+	ftNone = iota
+	ftSub
+	ftUp
+	ftAverage
+	ftPaeth
+	nFilter
+)
+
+// Encoder writes a PNG image of a known size to w, one band of rows at a time.
+type Encoder struct {
+	size image.Point
+	y    int
+
+	w  io.Writer
+	bw *bufio.Writer
+	zw *zlib.Writer
+
+	// cr holds the current row under every filter, filter type first, and
+	// pr the previous row, unfiltered.
+	cr  [nFilter][]uint8
+	pr  []uint8
+	err error
+}
+
+// NewEncoder writes the PNG header of an image of the given size to w.
+func NewEncoder(w io.Writer, size image.Point) (*Encoder, error) {
+	if size.X <= 0 || size.Y <= 0 {
+		return nil, ErrEmptySize
+	}
+
+	e := &Encoder{size: size, w: w, pr: make([]uint8, 1+size.X*bpp)}
+	for i := range e.cr {
+		e.cr[i] = make([]uint8, 1+size.X*bpp)
+		e.cr[i][0] = uint8(i)
+	}
+
+	if _, err := io.WriteString(w, "\x89PNG\r\n\x1a\n"); err != nil {
+		return nil, err
+	}
+
+	var ihdr [13]uint8
+	binary.BigEndian.PutUint32(ihdr[0:4], uint32(size.X))
+	binary.BigEndian.PutUint32(ihdr[4:8], uint32(size.Y))
+	ihdr[8] = 8  // bit depth
+	ihdr[9] = 6  // true color with alpha
+	ihdr[10] = 0 // deflate
+	ihdr[11] = 0 // adaptive filtering
+	ihdr[12] = 0 // not interlaced
+	if err := writeChunk(w, "IHDR", ihdr[:]); err != nil {
+		return nil, err
+	}
+
+	e.bw = bufio.NewWriterSize(idatWriter{w}, 1<<15)
+	e.zw = zlib.NewWriter(e.bw)
+
+	return e, nil
+}
+
+// WriteRows appends the rows of band to the image. band must be as wide as
+// the image and its rows, along with those written before, no more than its
+// height.
+func (e *Encoder) WriteRows(band *image.NRGBA) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	b := band.Bounds()
+	if b.Dx() != e.size.X {
+		return ErrRowWidth
+	}
+	if e.y+b.Dy() > e.size.Y {
+		return ErrRowCount
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := band.PixOffset(b.Min.X, y)
+		copy(e.cr[ftNone][1:], band.Pix[i:i+e.size.X*bpp])
+
+		f := filter(&e.cr, e.pr)
+		if _, e.err = e.zw.Write(e.cr[f]); e.err != nil {
+			return e.err
+		}
+
+		e.pr, e.cr[ftNone] = e.cr[ftNone], e.pr
+		e.cr[ftNone][0] = ftNone
+		e.y++
+	}
+
+	return nil
+}
+
+// Close ends the image, once all its rows have been written. It does not
+// close the underlying writer.
+func (e *Encoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.y != e.size.Y {
+		return ErrRowCount
+	}
+
+	if e.err = e.zw.Close(); e.err != nil {
+		return e.err
+	}
+	if e.err = e.bw.Flush(); e.err != nil {
+		return e.err
+	}
+
+	e.err = writeChunk(e.w, "IEND", nil)
+	return e.err
+}
+
+// filter fills every filtered row of cr from the unfiltered one, cr[ftNone],
+// and the previous row pr, and returns the filter to write.
+func filter(cr *[nFilter][]uint8, pr []uint8) int {
+	cdat0 := cr[ftNone][1:]
+	pdat := pr[1:]
+	n := len(cdat0)
+
+	sum := func(row []uint8) int {
+		s := 0
+		for _, v := range row {
+			s += abs(int(int8(v)))
+		}
+		return s
+	}
+
+	best, bestSum := ftNone, sum(cdat0)
+
+	cdat := cr[ftSub][1:]
+	for i := 0; i < n; i++ {
+		left := uint8(0)
+		if i >= bpp {
+			left = cdat0[i-bpp]
+		}
+		cdat[i] = cdat0[i] - left
+	}
+	if s := sum(cdat); s < bestSum {
+		best, bestSum = ftSub, s
+	}
+
+	cdat = cr[ftUp][1:]
+	for i := 0; i < n; i++ {
+		cdat[i] = cdat0[i] - pdat[i]
+	}
+	if s := sum(cdat); s < bestSum {
+		best, bestSum = ftUp, s
+	}
+
+	cdat = cr[ftAverage][1:]
+	for i := 0; i < n; i++ {
+		left := 0
+		if i >= bpp {
+			left = int(cdat0[i-bpp])
+		}
+		cdat[i] = cdat0[i] - uint8((left+int(pdat[i]))/2)
+	}
+	if s := sum(cdat); s < bestSum {
+		best, bestSum = ftAverage, s
+	}
+
+	cdat = cr[ftPaeth][1:]
+	for i := 0; i < n; i++ {
+		var a, c uint8
+		if i >= bpp {
+			a, c = cdat0[i-bpp], pdat[i-bpp]
+		}
+		cdat[i] = cdat0[i] - paeth(a, pdat[i], c)
+	}
+	if s := sum(cdat); s < bestSum {
+		best = ftPaeth
+	}
+
+	return best
+}
+
+// paeth predicts a pixel from its left, upper and upper left neighbours.
+func paeth(a, b, c uint8) uint8 {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// idatWriter writes every write as an IDAT chunk.
+type idatWriter struct {
+	w io.Writer
+}
+
+func (w idatWriter) Write(p []byte) (int, error) {
+	if err := writeChunk(w.w, "IDAT", p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func writeChunk(w io.Writer, name string, data []byte) error {
+	var header [8]uint8
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], name)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	var footer [4]uint8
+	binary.BigEndian.PutUint32(footer[:], crc.Sum32())
+
+	for _, b := range [][]byte{header[:], data, footer[:]} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
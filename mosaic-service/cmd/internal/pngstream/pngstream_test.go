@@ -0,0 +1,125 @@
+package pngstream
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+func Test_EncoderRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	var tt = []struct {
+		name  string
+		img   *image.NRGBA
+		bands []int
+	}{
+		{"single band", gradient(image.Rect(0, 0, 37, 21)), []int{21}},
+		{"uneven bands", gradient(image.Rect(0, 0, 64, 50)), []int{1, 16, 30, 3}},
+		{"offset", gradient(image.Rect(-5, 7, 20, 27)), []int{10, 10}},
+		{"noise", noise(rnd, image.Rect(0, 0, 100, 80)), []int{7, 64, 9}},
+		{"single pixel", noise(rnd, image.Rect(0, 0, 1, 1)), []int{1}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			b := tc.img.Bounds()
+
+			e, err := NewEncoder(&buf, b.Size())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			y := b.Min.Y
+			for _, n := range tc.bands {
+				band := tc.img.SubImage(image.Rect(b.Min.X, y, b.Max.X, y+n)).(*image.NRGBA)
+				if err = e.WriteRows(band); err != nil {
+					t.Fatal(err)
+				}
+				y += n
+			}
+			if err = e.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			decoded, err := png.Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Bounds().Size() != b.Size() {
+				t.Fatalf("expected size %v, got %v", b.Size(), decoded.Bounds().Size())
+			}
+
+			for y := 0; y < b.Dy(); y++ {
+				for x := 0; x < b.Dx(); x++ {
+					expected := tc.img.NRGBAAt(b.Min.X+x, b.Min.Y+y)
+					if c := color.NRGBAModel.Convert(decoded.At(x, y)); c != expected {
+						t.Fatalf("(%d,%d): expected %v, got %v", x, y, expected, c)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_EncoderErrors(t *testing.T) {
+	if _, err := NewEncoder(&bytes.Buffer{}, image.Pt(0, 4)); err != ErrEmptySize {
+		t.Errorf("expected %v, got %v", ErrEmptySize, err)
+	}
+
+	e, err := NewEncoder(&bytes.Buffer{}, image.Pt(4, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = e.WriteRows(image.NewNRGBA(image.Rect(0, 0, 5, 1))); err != ErrRowWidth {
+		t.Errorf("expected %v, got %v", ErrRowWidth, err)
+	}
+	if err = e.WriteRows(image.NewNRGBA(image.Rect(0, 0, 4, 5))); err != ErrRowCount {
+		t.Errorf("expected %v, got %v", ErrRowCount, err)
+	}
+	if err = e.WriteRows(image.NewNRGBA(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+	if err = e.Close(); err != ErrRowCount {
+		t.Errorf("expected %v closing short of rows, got %v", ErrRowCount, err)
+	}
+}
+
+func Benchmark_Encoder(b *testing.B) {
+	img := noise(rand.New(rand.NewSource(1)), image.Rect(0, 0, 1024, 1024))
+
+	b.Run("pngstream", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			e, _ := NewEncoder(&bytes.Buffer{}, img.Bounds().Size())
+			e.WriteRows(img)
+			e.Close()
+		}
+	})
+
+	b.Run("image/png", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			png.Encode(&bytes.Buffer{}, img)
+		}
+	})
+}
+
+func gradient(r image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 5), G: uint8(y * 7), B: uint8(x * y), A: uint8(255 - y)})
+		}
+	}
+	return img
+}
+
+func noise(rnd *rand.Rand, r image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	rnd.Read(img.Pix)
+	return img
+}
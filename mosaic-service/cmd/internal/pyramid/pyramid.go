@@ -0,0 +1,402 @@
+// Package pyramid cuts an image into the tiles of a zoomable pyramid, every
+// level half the size of the one below it, so that viewers load only the
+// tiles of the area and zoom level they show.
+//
+// Two layouts are written: Deep Zoom Images (DZI), as read by OpenSeadragon,
+// and XYZ tiles, as read by slippy map libraries such as Leaflet.
+package pyramid
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown pyramid format")
+	ErrEmptySize     = errors.New("empty pyramid")
+	ErrBandSize      = errors.New("band does not match the pyramid")
+)
+
+type Format string
+
+const (
+	DZI Format = "dzi"
+	XYZ Format = "xyz"
+)
+
+// ParseFormat returns the format of the given name.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case DZI, XYZ:
+		return f, nil
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+}
+
+const (
+	// DZITileSize and DZIOverlap are the size of the tiles of Deep Zoom
+	// Images and the pixels they share with their neighbours, tiles are
+	// DZITileSize plus the overlap on every inner edge.
+	DZITileSize = 254
+	DZIOverlap  = 1
+
+	// XYZTileSize is the size of XYZ tiles.
+	XYZTileSize = 256
+
+	// DZIName is the name of the descriptor of Deep Zoom Images, their tiles
+	// are written under DZIName without its extension and with "_files".
+	DZIName = "mosaic.dzi"
+)
+
+// Pyramid describes a pyramid written to a directory. Path is the entry point
+// viewers are given, relative to the directory: the DZI descriptor or the
+// XYZ tile template.
+type Pyramid struct {
+	Format   Format `json:"format"`
+	Path     string `json:"path"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	TileSize int    `json:"tile_size"`
+	Overlap  int    `json:"overlap,omitempty"`
+	MaxLevel int    `json:"max_level"`
+}
+
+// Write writes the pyramid of img in the format f to dir, from workers
+// goroutines, GOMAXPROCS when zero.
+func Write(dir string, img image.Image, f Format, workers int) (Pyramid, error) {
+	w, err := NewWriter(dir, img.Bounds().Size(), f, workers)
+	if err != nil {
+		return Pyramid{}, err
+	}
+
+	band, ok := img.(*image.NRGBA)
+	if !ok {
+		band = image.NewNRGBA(img.Bounds())
+		draw.Draw(band, band.Bounds(), img, band.Bounds().Min, draw.Src)
+	}
+
+	if err = w.WriteRows(band); err != nil {
+		return Pyramid{}, err
+	}
+
+	return w.Close()
+}
+
+// Writer writes the pyramid of an image from its rows, fed top to bottom in
+// bands. Every level is halved from the one above it as its rows come and
+// its tiles are written as soon as their rows are in, so that no more than a
+// row of tiles is held per level.
+//
+// Deep Zoom Images start at level 0, a single pixel, and end with the image
+// itself at level ceil(log2(max(width, height))). The tile at column c and row
+// r of level l is written to mosaic_files/l/c_r.png.
+//
+// XYZ tiles start at zoom 0, which fits the image in a single tile, and end
+// with the image itself, anchored at the top left corner of the tile grid.
+// The tile at column x and row y of zoom z is written to z/x/y.png, tiles
+// past the edges of the image are transparent where they overhang it and not
+// written where they are past it altogether.
+type Writer struct {
+	dir     string
+	p       Pyramid
+	workers int
+
+	// levels are the levels of the pyramid, the image itself first.
+	levels []*level
+}
+
+// level is a level of the pyramid being written. It holds the rows from y-n
+// up to y, the first row of the next tile row to write on.
+type level struct {
+	number int
+	size   image.Point
+	buf    []uint8
+	y, n   int
+
+	// next is the next row of tiles to write and even the last even row
+	// received, halved with the odd one after it.
+	next int
+	even []uint8
+}
+
+// NewWriter starts writing a pyramid of the given size in the format f to dir
+// from workers goroutines, GOMAXPROCS when zero.
+func NewWriter(dir string, size image.Point, f Format, workers int) (*Writer, error) {
+	if size.X <= 0 || size.Y <= 0 {
+		return nil, ErrEmptySize
+	}
+
+	p := Pyramid{Format: f, Width: size.X, Height: size.Y}
+	switch f {
+	case DZI:
+		p.Path = DZIName
+		p.TileSize = DZITileSize
+		p.Overlap = DZIOverlap
+		p.MaxLevel = levelCount(max(size.X, size.Y), 1)
+	case XYZ:
+		p.Path = "{z}/{x}/{y}.png"
+		p.TileSize = XYZTileSize
+		p.MaxLevel = levelCount(max(size.X, size.Y), XYZTileSize)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, f)
+	}
+
+	w := &Writer{dir: dir, p: p, workers: workers}
+	for l := p.MaxLevel; l >= 0; l-- {
+		w.levels = append(w.levels, &level{number: l, size: size})
+		size = image.Pt((size.X+1)/2, (size.Y+1)/2)
+	}
+
+	return w, nil
+}
+
+// WriteRows appends the rows of band to the image. band must be as wide as
+// the image and its rows, along with those written before, no more than its
+// height.
+func (w *Writer) WriteRows(band *image.NRGBA) error {
+	b := band.Bounds()
+	if b.Dx() != w.p.Width || w.levels[0].y+b.Dy() > w.p.Height {
+		return ErrBandSize
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := band.PixOffset(b.Min.X, y)
+		if err := w.add(0, band.Pix[i:i+b.Dx()*4]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close writes what remains of the pyramid once all the rows of the image
+// have been written, the DZI descriptor, and describes the pyramid.
+func (w *Writer) Close() (Pyramid, error) {
+	if w.levels[0].y != w.p.Height {
+		return Pyramid{}, ErrBandSize
+	}
+	if w.p.Format != DZI {
+		return w.p, nil
+	}
+
+	descriptor, err := xml.MarshalIndent(dziImage{
+		XMLNS:    "http://schemas.microsoft.com/deepzoom/2008",
+		Format:   "png",
+		Overlap:  DZIOverlap,
+		TileSize: DZITileSize,
+		Size:     dziSize{Width: w.p.Width, Height: w.p.Height},
+	}, "", "  ")
+	if err != nil {
+		return Pyramid{}, err
+	}
+	descriptor = append([]byte(xml.Header), descriptor...)
+
+	return w.p, os.WriteFile(filepath.Join(w.dir, DZIName), descriptor, 0o644)
+}
+
+// add appends row to level i, halves it into the next level and writes the
+// row of tiles it completes, if any.
+func (w *Writer) add(i int, row []uint8) error {
+	l := w.levels[i]
+	y := l.y
+	l.buf = append(l.buf, row...)
+	l.y++
+	l.n++
+
+	if i+1 < len(w.levels) {
+		switch {
+		case y%2 == 1:
+			if err := w.add(i+1, halve(l.even, row)); err != nil {
+				return err
+			}
+		case y == l.size.Y-1:
+			if err := w.add(i+1, halve(row, row)); err != nil {
+				return err
+			}
+		default:
+			l.even = append(l.even[:0], row...)
+		}
+	}
+
+	size, overlap := w.p.TileSize, w.p.Overlap
+	if end := min((l.next+1)*size+overlap, l.size.Y); l.y < end {
+		return nil
+	}
+
+	if err := writeTiles(w.tileRow(l), w.workers); err != nil {
+		return err
+	}
+	l.next++
+
+	// keep the rows the next row of tiles overlaps
+	if drop := min(l.next*size-overlap-(l.y-l.n), l.n); drop > 0 {
+		stride := l.size.X * 4
+		l.buf = append(l.buf[:0], l.buf[drop*stride:]...)
+		l.n -= drop
+	}
+
+	return nil
+}
+
+// tileRow lists the tiles of the next row of tiles of l.
+func (w *Writer) tileRow(l *level) []tile {
+	rows := &image.NRGBA{
+		Pix:    l.buf,
+		Stride: l.size.X * 4,
+		Rect:   image.Rect(0, l.y-l.n, l.size.X, l.y),
+	}
+
+	size, overlap := w.p.TileSize, w.p.Overlap
+	r := l.next
+
+	var tiles []tile
+	for c := 0; c*size < l.size.X; c++ {
+		rect := image.Rect(c*size-overlap, r*size-overlap, (c+1)*size+overlap, (r+1)*size+overlap)
+		rect = rect.Intersect(image.Rectangle{Max: l.size})
+
+		t := tile{src: rows, rect: rect}
+		if w.p.Format == DZI {
+			t.path = filepath.Join(w.dir, strings.TrimSuffix(DZIName, ".dzi")+"_files", fmt.Sprint(l.number), fmt.Sprintf("%d_%d.png", c, r))
+			t.bound = rect.Size()
+		} else {
+			t.path = filepath.Join(w.dir, fmt.Sprint(l.number), fmt.Sprint(c), fmt.Sprintf("%d.png", r))
+			t.bound = image.Pt(size, size)
+		}
+		tiles = append(tiles, t)
+	}
+
+	return tiles
+}
+
+type dziImage struct {
+	XMLName  xml.Name `xml:"Image"`
+	XMLNS    string   `xml:"xmlns,attr"`
+	Format   string   `xml:"Format,attr"`
+	Overlap  int      `xml:"Overlap,attr"`
+	TileSize int      `xml:"TileSize,attr"`
+	Size     dziSize  `xml:"Size"`
+}
+
+type dziSize struct {
+	Width  int `xml:"Width,attr"`
+	Height int `xml:"Height,attr"`
+}
+
+// levelCount is the number of times size must be halved, rounding up, to fit
+// in fit.
+func levelCount(size, fit int) int {
+	n := 0
+	for ; size > fit; size = (size + 1) / 2 {
+		n++
+	}
+	return n
+}
+
+// halve averages the 2x2 blocks of pixels of the rows a and b, of 8 bit
+// non-premultiplied RGBA, into a row half as wide, rounding up. Colors are
+// weighted by alpha, so transparent pixels do not bleed their color into
+// their neighbours.
+func halve(a, b []uint8) []uint8 {
+	width := len(a) / 4
+	out := make([]uint8, (width+1)/2*4)
+
+	for x := 0; x < width; x += 2 {
+		var sum [3]int
+		alpha, count := 0, 0
+		for _, row := range [][]uint8{a, b} {
+			for dx := 0; dx < 2 && x+dx < width; dx++ {
+				p := row[(x+dx)*4:]
+				for j := range sum {
+					sum[j] += int(p[j]) * int(p[3])
+				}
+				alpha += int(p[3])
+				count++
+			}
+		}
+
+		o := out[x/2*4:]
+		if alpha == 0 {
+			continue
+		}
+		for j := range sum {
+			o[j] = uint8((sum[j] + alpha/2) / alpha)
+		}
+		o[3] = uint8((alpha + count/2) / count)
+	}
+
+	return out
+}
+
+// tile is the area rect of src, written to path as a PNG of size bound with
+// the area at its top left corner.
+type tile struct {
+	path  string
+	src   image.Image
+	rect  image.Rectangle
+	bound image.Point
+}
+
+func (t tile) write() error {
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return err
+	}
+
+	img := image.NewNRGBA(image.Rectangle{Max: t.bound})
+	draw.Draw(img, t.rect.Sub(t.rect.Min), t.src, t.rect.Min, draw.Src)
+
+	f, err := os.Create(t.path)
+	if err != nil {
+		return err
+	}
+
+	if err = png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// writeTiles writes tiles from workers goroutines and returns the first
+// error met.
+func writeTiles(tiles []tile, workers int) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	queue := make(chan tile, len(tiles))
+	for _, t := range tiles {
+		queue <- t
+	}
+	close(queue)
+
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	for ; workers > 0; workers-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range queue {
+				if err := t.write(); err != nil {
+					once.Do(func() { first = err })
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	return first
+}
@@ -0,0 +1,289 @@
+package pyramid
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ParseFormat(t *testing.T) {
+	var tt = []struct {
+		name     string
+		expected Format
+		err      bool
+	}{
+		{"dzi", DZI, false},
+		{"xyz", XYZ, false},
+		{"DZI", "", true},
+		{"", "", true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := ParseFormat(tc.name)
+			if (err != nil) != tc.err || f != tc.expected {
+				t.Errorf("expected %q (error %v), got %q (%v)", tc.expected, tc.err, f, err)
+			}
+		})
+	}
+}
+
+func Test_levelCount(t *testing.T) {
+	var tt = []struct {
+		size, fit, expected int
+	}{
+		{1, 1, 0},
+		{2, 1, 1},
+		{3, 1, 2},
+		{600, 1, 10},
+		{1024, 1, 10},
+		{1025, 1, 11},
+		{256, 256, 0},
+		{257, 256, 1},
+		{600, 256, 2},
+	}
+
+	for _, tc := range tt {
+		if n := levelCount(tc.size, tc.fit); n != tc.expected {
+			t.Errorf("levelCount(%d, %d): expected %d, got %d", tc.size, tc.fit, tc.expected, n)
+		}
+	}
+}
+
+func Test_WriteDZI(t *testing.T) {
+	dir := t.TempDir()
+	img := quadrants(image.Rect(10, 20, 610, 320))
+
+	p, err := Write(dir, img, DZI, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.MaxLevel != 10 || p.Width != 600 || p.Height != 300 || p.Path != DZIName {
+		t.Fatalf("unexpected pyramid %+v", p)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, DZIName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var descriptor dziImage
+	if err = xml.Unmarshal(data, &descriptor); err != nil {
+		t.Fatal(err)
+	}
+	if descriptor.TileSize != DZITileSize || descriptor.Overlap != DZIOverlap || descriptor.Size != (dziSize{600, 300}) {
+		t.Errorf("unexpected descriptor %+v", descriptor)
+	}
+
+	var tt = []struct {
+		path     string
+		expected image.Rectangle
+		at       image.Point
+		color    color.NRGBA
+	}{
+		// tiles overlap their neighbours on inner edges only
+		{"10/0_0.png", image.Rect(0, 0, 255, 255), image.Pt(0, 0), red},
+		{"10/1_0.png", image.Rect(0, 0, 256, 255), image.Pt(255, 0), blue},
+		{"10/2_1.png", image.Rect(0, 0, 93, 47), image.Pt(92, 46), white},
+		{"10/0_1.png", image.Rect(0, 0, 255, 47), image.Pt(0, 0), green},
+		// 300 by 150
+		{"9/1_0.png", image.Rect(0, 0, 47, 150), image.Pt(46, 149), white},
+		// levels are halved exactly, the last pixels of odd rows and columns
+		// are averaged alone and weigh more than the rest
+		{"0/0_0.png", image.Rect(0, 0, 1, 1), image.Pt(0, 0), color.NRGBA{R: 150, G: 181, B: 181, A: 255}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			tile := readPNG(t, filepath.Join(dir, "mosaic_files", tc.path))
+			if tile.Bounds() != tc.expected {
+				t.Fatalf("expected bounds %v, got %v", tc.expected, tile.Bounds())
+			}
+			if c := color.NRGBAModel.Convert(tile.At(tc.at.X, tc.at.Y)); !closeNRGBA(c.(color.NRGBA), tc.color) {
+				t.Errorf("expected %v at %v, got %v", tc.color, tc.at, c)
+			}
+		})
+	}
+
+	for _, missing := range []string{"10/3_0.png", "10/0_2.png", "9/2_0.png", "9/0_1.png", "11"} {
+		if _, err := os.Stat(filepath.Join(dir, "mosaic_files", missing)); !os.IsNotExist(err) {
+			t.Errorf("expected no %s, got %v", missing, err)
+		}
+	}
+}
+
+func Test_WriteXYZ(t *testing.T) {
+	dir := t.TempDir()
+	img := quadrants(image.Rect(0, 0, 600, 300))
+
+	p, err := Write(dir, img, XYZ, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.MaxLevel != 2 || p.TileSize != XYZTileSize {
+		t.Fatalf("unexpected pyramid %+v", p)
+	}
+
+	var tt = []struct {
+		path  string
+		at    image.Point
+		color color.NRGBA
+	}{
+		{"2/0/0.png", image.Pt(0, 0), red},
+		{"2/2/1.png", image.Pt(600-512-1, 300-256-1), white},
+		// transparent where past the image
+		{"2/2/1.png", image.Pt(600-512, 0), color.NRGBA{}},
+		{"1/1/0.png", image.Pt(149-128, 149), white},
+		{"1/1/0.png", image.Pt(150, 10), color.NRGBA{}},
+		{"0/0/0.png", image.Pt(0, 0), red},
+		{"0/0/0.png", image.Pt(149, 0), blue},
+		{"0/0/0.png", image.Pt(0, 75), color.NRGBA{}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			tile := readPNG(t, filepath.Join(dir, tc.path))
+			if tile.Bounds() != image.Rect(0, 0, XYZTileSize, XYZTileSize) {
+				t.Fatalf("expected a full tile, got %v", tile.Bounds())
+			}
+			if c := color.NRGBAModel.Convert(tile.At(tc.at.X, tc.at.Y)); !closeNRGBA(c.(color.NRGBA), tc.color) {
+				t.Errorf("expected %v at %v, got %v", tc.color, tc.at, c)
+			}
+		})
+	}
+
+	for _, missing := range []string{"2/3", "2/0/2.png", "1/0/1.png", "3"} {
+		if _, err := os.Stat(filepath.Join(dir, missing)); !os.IsNotExist(err) {
+			t.Errorf("expected no %s, got %v", missing, err)
+		}
+	}
+}
+
+var (
+	red   = color.NRGBA{R: 255, A: 255}
+	blue  = color.NRGBA{B: 255, A: 255}
+	green = color.NRGBA{G: 255, A: 255}
+	white = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+)
+
+// quadrants is an image of r red at the top left, blue at the top right,
+// green at the bottom left and white at the bottom right.
+func quadrants(r image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	mid := r.Min.Add(r.Size().Div(2))
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y, mid.X, mid.Y), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(mid.X, r.Min.Y, r.Max.X, mid.Y), image.NewUniform(blue), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Min.X, mid.Y, mid.X, r.Max.Y), image.NewUniform(green), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(mid.X, mid.Y, r.Max.X, r.Max.Y), image.NewUniform(white), image.Point{}, draw.Src)
+	return img
+}
+
+func readPNG(t *testing.T, path string) image.Image {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func closeNRGBA(a, b color.NRGBA) bool {
+	near := func(x, y uint8) bool {
+		return int(x)-int(y) <= 1 && int(y)-int(x) <= 1
+	}
+	return near(a.R, b.R) && near(a.G, b.G) && near(a.B, b.B) && near(a.A, b.A)
+}
+
+// Test_WriterBands writes the same pyramids in uneven bands and at once and
+// expects identical files.
+func Test_WriterBands(t *testing.T) {
+	img := quadrants(image.Rect(0, 0, 700, 530))
+
+	for _, f := range []Format{DZI, XYZ} {
+		t.Run(string(f), func(t *testing.T) {
+			whole, banded := t.TempDir(), t.TempDir()
+			if _, err := Write(whole, img, f, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			w, err := NewWriter(banded, img.Bounds().Size(), f, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for y, n := 0, 1; y < 530; y, n = y+n, n*3%97+1 {
+				band := img.SubImage(image.Rect(0, y, 700, min(y+n, 530))).(*image.NRGBA)
+				if err = w.WriteRows(band); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err = w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			files := 0
+			err = filepath.WalkDir(whole, func(path string, d os.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				files++
+
+				rel, _ := filepath.Rel(whole, path)
+				expected, _ := os.ReadFile(path)
+				got, err := os.ReadFile(filepath.Join(banded, rel))
+				if err != nil || !bytes.Equal(expected, got) {
+					t.Errorf("%s differs when written in bands: %v", rel, err)
+				}
+				return nil
+			})
+			if err != nil || files == 0 {
+				t.Fatalf("expected files, got %d (%v)", files, err)
+			}
+		})
+	}
+}
+
+func Test_WriterBandSize(t *testing.T) {
+	w, err := NewWriter(t.TempDir(), image.Pt(10, 10), XYZ, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = w.WriteRows(image.NewNRGBA(image.Rect(0, 0, 9, 5))); err != ErrBandSize {
+		t.Errorf("expected %v for a narrow band, got %v", ErrBandSize, err)
+	}
+	if err = w.WriteRows(image.NewNRGBA(image.Rect(0, 0, 10, 5))); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.WriteRows(image.NewNRGBA(image.Rect(0, 0, 10, 6))); err != ErrBandSize {
+		t.Errorf("expected %v past the height, got %v", ErrBandSize, err)
+	}
+	if _, err = w.Close(); err != ErrBandSize {
+		t.Errorf("expected %v closing short of rows, got %v", ErrBandSize, err)
+	}
+
+	if _, err = NewWriter(t.TempDir(), image.Pt(0, 10), DZI, 1); err != ErrEmptySize {
+		t.Errorf("expected %v, got %v", ErrEmptySize, err)
+	}
+}
+
+func Test_halve(t *testing.T) {
+	a := []uint8{255, 0, 0, 255, 0, 0, 255, 255, 9, 9, 9, 0}
+	b := []uint8{255, 0, 0, 255, 0, 0, 255, 255, 0, 255, 0, 128}
+
+	expected := []uint8{128, 0, 128, 255, 0, 255, 0, 64}
+	if got := halve(a, b); !bytes.Equal(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
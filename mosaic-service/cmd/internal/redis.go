@@ -3,77 +3,264 @@ package internal
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
-	"encoding/binary"
 	"errors"
+	"fmt"
 	"image"
-	"image/jpeg"
+	_ "image/jpeg"
 	"math"
+	"strconv"
+	"sync"
 
+	"github.com/ChrisShia/tilestore"
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	DefaultBatchSize   = 256
+	DefaultConcurrency = 4
+)
+
+// knn is the number of neighbours searched per color, only the nearest is
+// used.
+const knn = 5
+
 type RedisIndex struct {
-	Name   string
-	Prefix string
-	Client *redis.Client
+	Set *tilestore.Set
+
+	// BatchSize is the number of commands sent per pipeline by Tiles and
+	// Match and Concurrency the number of pipelines in flight.
+	BatchSize   int
+	Concurrency int
 }
 
-func NewRedisIndex(name string, prefix string, c *redis.Client) *RedisIndex {
+func NewRedisIndex(set *tilestore.Set) *RedisIndex {
 	return &RedisIndex{
-		Name:   name,
-		Prefix: prefix,
-		Client: c,
+		Set:         set,
+		BatchSize:   DefaultBatchSize,
+		Concurrency: DefaultConcurrency,
 	}
 }
 
-func (ri *RedisIndex) Image(ac [3]float64) (image.Image, error) {
-	ftSearchResults, err := ri.FTSEARCH(ac)
+func (ri *RedisIndex) Tile(ac [3]float64) (Tile, image.Image, error) {
+	tiles, imgs, err := ri.Tiles([][3]float64{ac})
 	if err != nil {
-		return nil, err
+		return Tile{}, nil, err
 	}
+	if imgs[0] == nil {
+		return Tile{}, nil, ErrNoResult
+	}
+
+	return tiles[0], imgs[0], nil
+}
 
-	result, err := NearestNeighbourRedisResult(ftSearchResults)
+func decodeTile(b []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(b))
 	if err != nil {
 		return nil, err
 	}
 
-	return base64StringToImage(result)
+	return img, nil
+}
+
+// Tiles resolves acs like Match and then fetches the images of the tiles
+// matched, each distinct tile once. Colors without a match are left nil.
+func (ri *RedisIndex) Tiles(acs [][3]float64) ([]Tile, []image.Image, error) {
+	tiles, err := ri.Match(acs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ids, index := distinctIDs(tiles)
+	decoded := make([]image.Image, len(ids))
+
+	err = ri.inBatches(len(ids), func(start, end int) error {
+		blobs, err := ri.Set.Images(context.Background(), ids[start:end])
+		if err != nil {
+			return err
+		}
+		for i, blob := range blobs {
+			if blob == nil {
+				continue
+			}
+			// tiles failing to decode are left unmatched
+			decoded[start+i], _ = decodeTile(blob)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	imgs := make([]image.Image, len(acs))
+	for i, j := range index {
+		if j < 0 || decoded[j] == nil {
+			tiles[i] = Tile{}
+			continue
+		}
+		imgs[i] = decoded[j]
+	}
+
+	return tiles, imgs, nil
 }
 
-func base64StringToImage(str string) (image.Image, error) {
-	//TODO: p is nil....needs to be allocated memory (?)
-	decodedLen := base64.StdEncoding.DecodedLen(len(str))
-	p := make([]byte, decodedLen)
+// Match resolves acs to the records of their nearest tiles. The searches
+// only return tile keys, sent in pipelines of BatchSize FT.SEARCH commands
+// with up to Concurrency pipelines in flight, and the records of the
+// distinct tiles found are read afterwards. Colors without a match are left
+// with an empty ID, the first command failing for another reason fails the
+// whole batch.
+func (ri *RedisIndex) Match(acs [][3]float64) ([]Tile, error) {
+	tiles := make([]Tile, len(acs))
 
-	_, err := base64.StdEncoding.Decode(p, []byte(str))
+	err := ri.inBatches(len(acs), func(start, end int) error {
+		return ri.pipelinedSearch(acs[start:end], tiles[start:end])
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	//TODO: this needs to be abstracted...we dont have a fixed tile image format..
-	img, err := jpeg.Decode(bytes.NewReader(p))
+	ids, index := distinctIDs(tiles)
+	records := make([]*tilestore.Tile, len(ids))
+
+	err = ri.inBatches(len(ids), func(start, end int) error {
+		batch, err := ri.Set.Records(context.Background(), ids[start:end])
+		if err != nil {
+			return err
+		}
+		copy(records[start:end], batch)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return img, nil
+	for i, j := range index {
+		if j < 0 {
+			continue
+		}
+		if records[j] == nil {
+			// deleted since the search
+			tiles[i] = Tile{}
+			continue
+		}
+
+		tiles[i].Descriptor = records[j].Vector
+		tiles[i].Distance = Distance(records[j].Vector, acs[i])
+		tiles[i].Source = records[j].Source
+		tiles[i].Author = records[j].Author
+	}
+
+	return tiles, nil
 }
 
-func (ri *RedisIndex) FTSEARCH(searchFor [3]float64) (interface{}, error) {
-	searchForBinary, err := binaryFloat64bit(searchFor)
+// distinctIDs lists the distinct IDs of tiles, index maps every tile to its
+// ID in ids and is -1 for the tiles without one.
+func distinctIDs(tiles []Tile) (ids []string, index []int) {
+	index = make([]int, len(tiles))
+	seen := make(map[string]int)
+
+	for i, tile := range tiles {
+		if tile.ID == "" {
+			index[i] = -1
+			continue
+		}
+
+		j, ok := seen[tile.ID]
+		if !ok {
+			j = len(ids)
+			seen[tile.ID] = j
+			ids = append(ids, tile.ID)
+		}
+		index[i] = j
+	}
+
+	return ids, index
+}
+
+// ImageByID fetches and decodes the tile stored under the key id.
+func (ri *RedisIndex) ImageByID(id string) (image.Image, error) {
+	blob, err := ri.Set.Image(context.Background(), id)
+	if errors.Is(err, tilestore.ErrNoTile) {
+		return nil, ErrNoResult
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := ri.Client.Do(context.Background(),
-		"FT.SEARCH", ri.Name,
-		"(*)=>[KNN 5 @average_color $vec]",
-		"PARAMS", "2", "vec", searchForBinary,
-		"SORTBY", "__average_color_score",
-		"RETURN", "2", "img", "average_color",
-		"DIALECT", "2",
-	).Result()
+	return decodeTile(blob)
+}
+
+// inBatches calls f for consecutive ranges of at most BatchSize of the n
+// queries, from up to Concurrency goroutines, and returns the first error.
+func (ri *RedisIndex) inBatches(n int, f func(start, end int) error) error {
+	batchSize := max(ri.BatchSize, 1)
+	sem := make(chan struct{}, max(ri.Concurrency, 1))
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for start := 0; start < n; start += batchSize {
+		end := min(start+batchSize, n)
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer func() {
+				if r := recover(); r != nil {
+					once.Do(func() { firstErr = fmt.Errorf("%w: %v", ErrLookupPanic, r) })
+				}
+			}()
+
+			if err := f(start, end); err != nil {
+				once.Do(func() { firstErr = err })
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return firstErr
+}
+
+// pipelinedSearch sends one FT.SEARCH per color of acs in a single pipeline
+// and stores the key of the nearest tile and the set it belongs to in tiles.
+func (ri *RedisIndex) pipelinedSearch(acs [][3]float64, tiles []Tile) error {
+	ctx := context.Background()
+
+	pipe := ri.Set.Client.Pipeline()
+	cmds := make([]*redis.Cmd, len(acs))
+	for i, ac := range acs {
+		cmds[i] = pipe.Do(ctx, ri.Set.SearchArgs(ac, knn, tilestore.FieldScore)...)
+	}
+
+	// the error of every command is checked below
+	_, _ = pipe.Exec(ctx)
+
+	for i, cmd := range cmds {
+		ftSearchResults, err := cmd.Result()
+		if err != nil {
+			return err
+		}
+
+		tile, err := NearestNeighbourRedisTile(ftSearchResults)
+		if err != nil {
+			continue
+		}
+
+		tile.Set = ri.Set.Name
+		tiles[i] = tile
+	}
+
+	return nil
+}
+
+func (ri *RedisIndex) FTSEARCH(searchFor [3]float64) (interface{}, error) {
+	args := ri.Set.SearchArgs(searchFor, knn, tilestore.FieldScore)
+
+	result, err := ri.Set.Client.Do(context.Background(), args...).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -98,54 +285,48 @@ func (ri *RedisIndex) PipeFTSEARCHAndRemove(searchFor [3]float64) (interface{},
 	return nil, nil
 }
 
-func binaryFloat64bit(indexVector [3]float64) ([]byte, error) {
-	avColorBinary := make([]byte, 8*len(indexVector))
-	for i, f := range indexVector {
-		binary.LittleEndian.PutUint64(avColorBinary[i*8:], math.Float64bits(f))
-	}
-
-	return avColorBinary, nil
-}
-
-var (
-	ErrNoResult          = errors.New("no images found in results")
-	ErrInvalidResultType = errors.New("invalid result type")
-	ErrNoImageResult     = errors.New("invalid result, no \"img\" field")
-	ErrInvalidField      = errors.New("invalid type of \"img\" field")
-)
+var ErrNoResult = errors.New("no images found in results")
 
-func NearestNeighbourRedisResult(result interface{}) (string, error) {
-	redisResultMap := result.(map[interface{}]interface{})
+// NearestNeighbourRedisTile reads the key of the first document of a
+// FT.SEARCH reply and, when the query returned them, its distance and
+// record fields.
+func NearestNeighbourRedisTile(result interface{}) (Tile, error) {
+	doc, err := nearestNeighbourDoc(result)
+	if err != nil {
+		return Tile{}, err
+	}
 
-	allResults := redisResultMap["results"].([]interface{})
-	if len(allResults) == 0 {
-		return "", ErrNoResult
+	tile := Tile{
+		ID:     doc.ID,
+		Source: doc.Fields[tilestore.FieldSource],
+		Author: doc.Fields[tilestore.FieldAuthor],
 	}
 
-	firstResult := allResults[0]
+	// the score of L2 indexes is the squared distance
+	if score, ok := doc.Fields[tilestore.FieldScore]; ok {
+		if tile.Distance, err = strconv.ParseFloat(score, 64); err != nil {
+			return Tile{}, fmt.Errorf("%w: score %q", tilestore.ErrUnexpectedReply, score)
+		}
+		tile.Distance = math.Sqrt(tile.Distance)
+	}
 
-	var firstResultMap map[interface{}]interface{}
-	switch firstResult.(type) {
-	case map[interface{}]interface{}:
-		firstResultMap = firstResult.(map[interface{}]interface{})
-	default:
-		return "", ErrInvalidResultType
+	if ac, ok := doc.Fields[tilestore.FieldVector]; ok {
+		if tile.Descriptor, err = tilestore.DecodeVector([]byte(ac)); err != nil {
+			return Tile{}, err
+		}
 	}
 
-	firstResultExtraAttributesMap := firstResultMap["extra_attributes"].(map[interface{}]interface{})
+	return tile, nil
+}
 
-	actualImg := firstResultExtraAttributesMap["img"]
-	if actualImg == nil {
-		return "", ErrNoImageResult
+func nearestNeighbourDoc(result interface{}) (tilestore.SearchDoc, error) {
+	sr, err := tilestore.ParseSearchResult(result, false)
+	if err != nil {
+		return tilestore.SearchDoc{}, err
 	}
-
-	var res string
-	switch actualImg.(type) {
-	case string:
-		res = actualImg.(string)
-	default:
-		return "", ErrInvalidField
+	if len(sr.Docs) == 0 {
+		return tilestore.SearchDoc{}, ErrNoResult
 	}
 
-	return res, nil
+	return sr.Docs[0], nil
 }
@@ -0,0 +1,222 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/color"
+	"image/png"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ChrisShia/tilestore"
+	"github.com/redis/go-redis/v9"
+)
+
+const redisTestURL = "redis://localhost:6378"
+
+// FT.SEARCH replies of the tile index as decoded by go-redis, with the img
+// field cut short
+var (
+	recordedVector = tilestore.EncodeVector([3]float64{148.91, 153.07, 147.55}, tilestore.Float32)
+
+	recordedRESP3 = map[interface{}]interface{}{
+		"attributes": []interface{}{},
+		"format":     "STRING",
+		"results": []interface{}{
+			map[interface{}]interface{}{
+				"id": "img:0.0.0.0:1",
+				"extra_attributes": map[interface{}]interface{}{
+					"average_color": string(recordedVector),
+					"img":           "/9j/2wCEAAgGBgcGBQgHBw",
+				},
+				"values": []interface{}{},
+			},
+			map[interface{}]interface{}{
+				"id": "img:0.0.0.0:2",
+				"extra_attributes": map[interface{}]interface{}{
+					"average_color": string(recordedVector),
+					"img":           "/9j/2wCEcGBQgHBwAAgGBgcGBQgHBw",
+				},
+				"values": []interface{}{},
+			},
+		},
+		"total_results": int64(2),
+		"warning":       []interface{}{},
+	}
+
+	// replies of the searches of RedisIndex, returning only the score
+	recordedRESP3Score = map[interface{}]interface{}{
+		"attributes": []interface{}{},
+		"format":     "STRING",
+		"results": []interface{}{
+			map[interface{}]interface{}{
+				"id":               "img:0.0.0.0:2",
+				"extra_attributes": map[interface{}]interface{}{"__average_color_score": "16"},
+				"values":           []interface{}{},
+			},
+		},
+		"total_results": int64(1),
+		"warning":       []interface{}{},
+	}
+
+	recordedRESP2Score = []interface{}{
+		int64(1),
+		"img:0.0.0.0:2",
+		[]interface{}{"__average_color_score", "16"},
+	}
+
+	recordedRESP3Empty = map[interface{}]interface{}{
+		"attributes":    []interface{}{},
+		"format":        "STRING",
+		"results":       []interface{}{},
+		"total_results": int64(0),
+		"warning":       []interface{}{},
+	}
+
+	recordedRESP3NoContent = map[interface{}]interface{}{
+		"attributes": []interface{}{},
+		"format":     "STRING",
+		"results": []interface{}{
+			map[interface{}]interface{}{"id": "img:0.0.0.0:1", "values": []interface{}{}},
+		},
+		"total_results": int64(1),
+		"warning":       []interface{}{},
+	}
+
+	recordedRESP2 = []interface{}{
+		int64(2),
+		"img:0.0.0.0:1",
+		[]interface{}{"average_color", string(recordedVector), "img", "/9j/2wCEAAgGBgcGBQgHBw"},
+		"img:0.0.0.0:2",
+		[]interface{}{"average_color", string(recordedVector), "img", "/9j/2wCEcGBQgHBwAAgGBgcGBQgHBw"},
+	}
+)
+
+func Test_NearestNeighbourRedisTile(t *testing.T) {
+	var tt = []struct {
+		name     string
+		reply    interface{}
+		id       string
+		distance float64
+		err      error
+	}{
+		{"resp3", recordedRESP3, "img:0.0.0.0:1", 0, nil},
+		{"resp2", recordedRESP2, "img:0.0.0.0:1", 0, nil},
+		{"resp3 score", recordedRESP3Score, "img:0.0.0.0:2", 4, nil},
+		{"resp2 score", recordedRESP2Score, "img:0.0.0.0:2", 4, nil},
+		{"nocontent", recordedRESP3NoContent, "img:0.0.0.0:1", 0, nil},
+		{"empty", recordedRESP3Empty, "", 0, ErrNoResult},
+		{"malformed", map[interface{}]interface{}{}, "", 0, tilestore.ErrUnexpectedReply},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tile, err := NearestNeighbourRedisTile(tc.reply)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+			if tile.ID != tc.id || tile.Distance != tc.distance {
+				t.Errorf("expected %s at %v, got %s at %v", tc.id, tc.distance, tile.ID, tile.Distance)
+			}
+		})
+	}
+}
+
+// Test_RedisIndexTiles needs redis stack on localhost:6378.
+func Test_RedisIndexTiles(t *testing.T) {
+	c := redisTestClient(t)
+	ctx := context.Background()
+
+	set := tilestore.New(c, "mosaic-redis-index-test")
+	drop := func() {
+		c.Do(ctx, "FT.DROPINDEX", set.Name)
+		keys, _ := c.Keys(ctx, set.Pattern()).Result()
+		blobs, _ := c.Keys(ctx, tilestore.BlobKey(set.Pattern())).Result()
+		c.Del(ctx, append(append(keys, blobs...), "tilestore:"+set.Name, set.Name+":counter")...)
+	}
+	drop()
+	t.Cleanup(drop)
+
+	if err := set.EnsureIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	colors := []color.NRGBA{{R: 255, A: 0xff}, {G: 255, A: 0xff}, {B: 255, A: 0xff}}
+	keys := make([]string, len(colors))
+	for i, col := range colors {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, solidImage(col)); err != nil {
+			t.Fatal(err)
+		}
+
+		key, err := set.Add(ctx, tilestore.Tile{
+			Image:  buf.Bytes(),
+			Vector: [3]float64{float64(col.R), float64(col.G), float64(col.B)},
+			Author: "author " + strconv.Itoa(i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+
+	ri := NewRedisIndex(set)
+	ri.BatchSize = 2
+
+	acs := [][3]float64{{250, 10, 10}, {0, 0, 240}, {240, 0, 0}, {10, 250, 0}}
+	expected := []int{0, 2, 0, 1}
+
+	tiles, imgs, err := ri.Tiles(acs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, j := range expected {
+		if tiles[i].ID != keys[j] {
+			t.Errorf("%v: expected %s, got %s", acs[i], keys[j], tiles[i].ID)
+		}
+		if tiles[i].Author != "author "+strconv.Itoa(j) || tiles[i].Set != set.Name {
+			t.Errorf("%v: expected the record of %s, got %+v", acs[i], keys[j], tiles[i])
+		}
+		if imgs[i] == nil {
+			t.Fatalf("%v: expected an image", acs[i])
+		}
+		if r, g, b, _ := imgs[i].At(0, 0).RGBA(); [3]uint32{r >> 8, g >> 8, b >> 8} != [3]uint32{uint32(colors[j].R), uint32(colors[j].G), uint32(colors[j].B)} {
+			t.Errorf("%v: expected the image of %s", acs[i], keys[j])
+		}
+	}
+	if imgs[0] != imgs[2] {
+		t.Errorf("expected tiles matched twice to be decoded once")
+	}
+
+	if _, err = ri.ImageByID(set.Key(100)); !errors.Is(err, ErrNoResult) {
+		t.Errorf("expected %v, got %v", ErrNoResult, err)
+	}
+
+	m, err := LoadMemoryIndexFromRedis(ctx, set, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != len(colors) {
+		t.Errorf("expected %d tiles in memory, got %d", len(colors), m.Len())
+	}
+}
+
+func redisTestClient(t *testing.T) *redis.Client {
+	opt, err := redis.ParseURL(redisTestURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := redis.NewClient(opt)
+	t.Cleanup(func() { c.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = c.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis unavailable: %v", err)
+	}
+
+	return c
+}
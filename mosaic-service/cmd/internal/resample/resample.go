@@ -0,0 +1,264 @@
+// Package resample scales images to any size, down or up and at fractional
+// ratios, by separable convolution with a reconstruction filter.
+package resample
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/ChrisShia/tilestore"
+	"github.com/ChrisShia/tilestore/pixel"
+)
+
+var ErrUnknownFilter = errors.New("unknown resampling filter")
+
+// Filter is a reconstruction filter. Kernel is evaluated at distances from
+// the sampled point in source pixels and is zero outside [-Support, Support].
+// When downscaling, the kernel is stretched by the ratio so that every source
+// pixel contributes to the output.
+//
+// Nearest, with no support, and Box, with no kernel, are not convolutions:
+// the first copies the source pixel under every output pixel and the second
+// averages the source pixels an output pixel covers, weighted by the area
+// covered.
+type Filter struct {
+	Name    string
+	Support float64
+	Kernel  func(x float64) float64
+}
+
+var (
+	Nearest  = Filter{Name: "nearest"}
+	Box      = Filter{Name: "box", Support: 0.5}
+	Bilinear = Filter{Name: "bilinear", Support: 1, Kernel: triangle}
+	Bicubic  = Filter{Name: "bicubic", Support: 2, Kernel: catmullRom}
+	Lanczos3 = Filter{Name: "lanczos3", Support: 3, Kernel: lanczos3}
+)
+
+// Filters are the filters known by ByName.
+var Filters = []Filter{Nearest, Box, Bilinear, Bicubic, Lanczos3}
+
+// ByName returns the filter of the given name.
+func ByName(name string) (Filter, error) {
+	for _, f := range Filters {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+
+	return Filter{}, fmt.Errorf("%w: %q", ErrUnknownFilter, name)
+}
+
+func triangle(x float64) float64 {
+	x = math.Abs(x)
+	if x < 1 {
+		return 1 - x
+	}
+	return 0
+}
+
+// catmullRom is the cubic convolution kernel of Keys with a = -0.5.
+func catmullRom(x float64) float64 {
+	x = math.Abs(x)
+	switch {
+	case x < 1:
+		return (1.5*x-2.5)*x*x + 1
+	case x < 2:
+		return ((-0.5*x+2.5)*x-4)*x + 2
+	}
+	return 0
+}
+
+func lanczos3(x float64) float64 {
+	x = math.Abs(x)
+	switch {
+	case x == 0:
+		return 1
+	case x < 3:
+		px := math.Pi * x
+		return 3 * math.Sin(px) * math.Sin(px/3) / (px * px)
+	}
+	return 0
+}
+
+// Resize scales src to size with the filter f. Edges are extended by
+// repeating the border pixels and colors are filtered in linear light,
+// premultiplied by alpha, so transparent pixels do not bleed their color into
+// their neighbours.
+func Resize(src image.Image, size image.Point, f Filter) *image.NRGBA {
+	size = image.Pt(max(size.X, 0), max(size.Y, 0))
+	out := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+	bounds := src.Bounds()
+	if out.Bounds().Empty() || bounds.Empty() {
+		return out
+	}
+
+	if f.Support == 0 {
+		resizeNearest(src, out)
+		return out
+	}
+
+	pixels := load(src)
+	sw, sh := bounds.Dx(), bounds.Dy()
+
+	// horizontal pass, into a size.X by sh buffer
+	cols := weights(sw, size.X, f)
+	tmp := make([]float32, size.X*sh*4)
+	for y := 0; y < sh; y++ {
+		row := pixels[y*sw*4 : (y+1)*sw*4]
+		for x, c := range cols {
+			convolve(tmp[(y*size.X+x)*4:], row, c, 4)
+		}
+	}
+
+	// vertical pass, into out
+	rows := weights(sh, size.Y, f)
+	var px [4]float32
+	for y, c := range rows {
+		for x := 0; x < size.X; x++ {
+			convolve(px[:], tmp[x*4:], c, size.X*4)
+			store(out.Pix[y*out.Stride+x*4:], px)
+		}
+	}
+
+	return out
+}
+
+// contribution lists the weights of the source pixels from start on to one
+// output pixel.
+type contribution struct {
+	start   int
+	weights []float32
+}
+
+// weights computes the contributions of a source line of n pixels to each of
+// the m pixels of the output line. The centre of output pixel i lies at
+// (i+0.5)*n/m in the source, pixels past the edges are clamped to it and the
+// weights of every output pixel sum to one.
+func weights(n, m int, f Filter) []contribution {
+	scale := float64(n) / float64(m)
+	stretch := math.Max(scale, 1)
+	support := f.Support * stretch
+
+	out := make([]contribution, m)
+	w := make([]float64, n)
+	for i := range out {
+		center := (float64(i) + 0.5) * scale
+		lo := int(math.Floor(center - support))
+		hi := int(math.Ceil(center + support))
+
+		first, last := n, -1
+		for j := lo; j < hi; j++ {
+			var v float64
+			if f.Kernel == nil {
+				v = overlap(float64(j), float64(j+1), center-support, center+support)
+			} else {
+				v = f.Kernel((float64(j) + 0.5 - center) / stretch)
+			}
+			if v == 0 {
+				continue
+			}
+
+			k := min(max(j, 0), n-1)
+			w[k] += v
+			first, last = min(first, k), max(last, k)
+		}
+
+		var sum float64
+		for k := first; k <= last; k++ {
+			sum += w[k]
+		}
+
+		c := contribution{start: first, weights: make([]float32, max(last-first+1, 0))}
+		for k := range c.weights {
+			c.weights[k] = float32(w[first+k] / sum)
+			w[first+k] = 0
+		}
+		if sum == 0 {
+			// a kernel with no weight around the centre of the pixel
+			c = contribution{start: min(int(center), n-1), weights: []float32{1}}
+		}
+		out[i] = c
+	}
+
+	return out
+}
+
+// overlap is the length of the intersection of [a0, a1] and [b0, b1].
+func overlap(a0, a1, b0, b1 float64) float64 {
+	return math.Max(0, math.Min(a1, b1)-math.Max(a0, b0))
+}
+
+// convolve sums the 4 channel pixels of src from c.start on, stride floats
+// apart, weighted by c, into dst.
+func convolve(dst, src []float32, c contribution, stride int) {
+	var r, g, b, a float32
+	for k, w := range c.weights {
+		p := src[(c.start+k)*stride:]
+		r += p[0] * w
+		g += p[1] * w
+		b += p[2] * w
+		a += p[3] * w
+	}
+	dst[0], dst[1], dst[2], dst[3] = r, g, b, a
+}
+
+// load reads src into rows of premultiplied linear light channels, from 0 to
+// 255.
+func load(src image.Image) []float32 {
+	bounds := src.Bounds()
+	w := bounds.Dx()
+	pixels := make([]float32, w*bounds.Dy()*4)
+
+	row := make([]uint16, w*4)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		pixel.Row(src, y, bounds.Min.X, bounds.Max.X, row)
+		dst := pixels[(y-bounds.Min.Y)*w*4:]
+		for i := 0; i < len(row); i += 4 {
+			r, g, b, a := tilestore.LinearRGBA(row[i], row[i+1], row[i+2], row[i+3])
+			dst[i], dst[i+1], dst[i+2], dst[i+3] = float32(r)/257, float32(g)/257, float32(b)/257, float32(a)/257
+		}
+	}
+
+	return pixels
+}
+
+// store encodes the premultiplied linear light pixel px, clamped to the valid
+// range, into the non premultiplied sRGB pixel at the start of pix. Kernels
+// with negative lobes overshoot around sharp edges.
+func store(pix []uint8, px [4]float32) {
+	a := min(max(px[3], 0), 255)
+	if a == 0 {
+		pix[0], pix[1], pix[2], pix[3] = 0, 0, 0, 0
+		return
+	}
+
+	for k := 0; k < 3; k++ {
+		c := min(max(px[k], 0), a)
+		pix[k] = tilestore.SRGB8(uint16(c/a*0xffff + 0.5))
+	}
+	pix[3] = uint8(a + 0.5)
+}
+
+// resizeNearest copies into every pixel of out the source pixel under its
+// centre.
+func resizeNearest(src image.Image, out *image.NRGBA) {
+	bounds := src.Bounds()
+	size := out.Bounds().Size()
+
+	xs := make([]int, size.X)
+	for i := range xs {
+		xs[i] = bounds.Min.X + min((2*i+1)*bounds.Dx()/(2*size.X), bounds.Dx()-1)
+	}
+
+	for j := 0; j < size.Y; j++ {
+		y := bounds.Min.Y + min((2*j+1)*bounds.Dy()/(2*size.Y), bounds.Dy()-1)
+		for i, x := range xs {
+			c := color.NRGBAModel.Convert(src.At(x, y)).(color.NRGBA)
+			out.SetNRGBA(i, j, c)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package resample
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func Test_Kernels(t *testing.T) {
+	var tt = []struct {
+		name     string
+		kernel   func(float64) float64
+		x        float64
+		expected float64
+	}{
+		{"triangle centre", triangle, 0, 1},
+		{"triangle half", triangle, -0.5, 0.5},
+		{"triangle edge", triangle, 1, 0},
+		{"catmull-rom centre", catmullRom, 0, 1},
+		{"catmull-rom quarter", catmullRom, 0.25, 0.8671875},
+		{"catmull-rom half", catmullRom, 0.5, 0.5625},
+		{"catmull-rom one", catmullRom, 1, 0},
+		{"catmull-rom lobe", catmullRom, -1.5, -0.0625},
+		{"catmull-rom edge", catmullRom, 2, 0},
+		{"lanczos3 centre", lanczos3, 0, 1},
+		{"lanczos3 half", lanczos3, 0.5, 0.6079271},
+		{"lanczos3 one", lanczos3, 1, 0},
+		{"lanczos3 lobe", lanczos3, 1.5, -0.1350949},
+		{"lanczos3 edge", lanczos3, 3, 0},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if v := tc.kernel(tc.x); math.Abs(v-tc.expected) > 1e-6 {
+				t.Errorf("expected %v, got %v", tc.expected, v)
+			}
+		})
+	}
+}
+
+func Test_ByName(t *testing.T) {
+	for _, f := range Filters {
+		got, err := ByName(f.Name)
+		if err != nil || got.Name != f.Name {
+			t.Errorf("%s: got %q, %v", f.Name, got.Name, err)
+		}
+	}
+
+	if _, err := ByName("sinc"); !errors.Is(err, ErrUnknownFilter) {
+		t.Errorf("expected %v, got %v", ErrUnknownFilter, err)
+	}
+}
+
+// Test_ResizeReference resizes lines of gray pixels and compares them with
+// outputs worked out by hand from the kernels, in linear light with the sRGB
+// transfer function.
+func Test_ResizeReference(t *testing.T) {
+	var tt = []struct {
+		name     string
+		filter   Filter
+		src      []uint8
+		size     int
+		expected []uint8
+	}{
+		{"nearest down", Nearest, []uint8{0, 50, 100, 150, 200, 250}, 3, []uint8{50, 150, 250}},
+		{"nearest up", Nearest, []uint8{0, 255}, 4, []uint8{0, 0, 255, 255}},
+		{"box halves", Box, []uint8{0, 100, 200, 40}, 2, []uint8{71, 149}},
+		{"box two thirds", Box, []uint8{0, 90, 180}, 2, []uint8{52, 157}},
+		{"box up", Box, []uint8{0, 255}, 4, []uint8{0, 137, 225, 255}},
+		{"bilinear up", Bilinear, []uint8{0, 255}, 4, []uint8{0, 137, 225, 255}},
+		{"bilinear down", Bilinear, []uint8{0, 0, 255, 255}, 2, []uint8{99, 240}},
+		{"bicubic up", Bicubic, []uint8{0, 255}, 4, []uint8{0, 124, 231, 255}},
+		{"bicubic down", Bicubic, []uint8{0, 0, 255, 255}, 2, []uint8{73, 247}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			horizontal := Resize(grayLine(tc.src, false), image.Pt(tc.size, 1), tc.filter)
+			if got := grayValues(horizontal); !reflect.DeepEqual(tc.expected, got) {
+				t.Errorf("horizontal: expected %v, got %v", tc.expected, got)
+			}
+
+			vertical := Resize(grayLine(tc.src, true), image.Pt(1, tc.size), tc.filter)
+			if got := grayValues(vertical); !reflect.DeepEqual(tc.expected, got) {
+				t.Errorf("vertical: expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+// Test_ResizeNyquist halves columns alternating between black and white.
+// Every filter symmetric about the centre of the output pixels averages them
+// to half the light away from the edges, 188 in sRGB.
+func Test_ResizeNyquist(t *testing.T) {
+	src := make([]uint8, 40)
+	for i := range src {
+		src[i] = uint8(255 * (i % 2))
+	}
+
+	for _, f := range []Filter{Box, Bilinear, Bicubic, Lanczos3} {
+		t.Run(f.Name, func(t *testing.T) {
+			got := grayValues(Resize(grayLine(src, false), image.Pt(20, 1), f))
+			for i := 3; i < len(got)-3; i++ {
+				if got[i] < 187 || got[i] > 188 {
+					t.Errorf("pixel %d: expected half the light, got %d", i, got[i])
+				}
+			}
+		})
+	}
+}
+
+// Test_ResizeCheckerboard boxes a black and white checkerboard down to one
+// pixel, which holds half its light. Averaging the sRGB values would give
+// mid gray, 128.
+func Test_ResizeCheckerboard(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			v := uint8(255 * ((x + y) % 2))
+			src.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: 0xff})
+		}
+	}
+
+	got := Resize(src, image.Pt(1, 1), Box).NRGBAAt(0, 0)
+	if expected := (color.NRGBA{R: 188, G: 188, B: 188, A: 0xff}); got != expected {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func Test_ResizeIdentity(t *testing.T) {
+	src := randomImage(rand.New(rand.NewSource(1)), image.Rect(3, -2, 20, 11), true)
+
+	for _, f := range Filters {
+		t.Run(f.Name, func(t *testing.T) {
+			out := Resize(src, src.Bounds().Size(), f)
+			b := src.Bounds()
+			for y := 0; y < b.Dy(); y++ {
+				for x := 0; x < b.Dx(); x++ {
+					expected := src.NRGBAAt(b.Min.X+x, b.Min.Y+y)
+					if got := out.NRGBAAt(x, y); !closeNRGBA(expected, got) {
+						t.Fatalf("pixel %d,%d: expected %v, got %v", x, y, expected, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_ResizeConstant(t *testing.T) {
+	c := color.NRGBA{R: 200, G: 30, B: 90, A: 255}
+	src := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+	for i := 0; i < len(src.Pix); i += 4 {
+		src.Pix[i], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+
+	sizes := []image.Point{{7, 7}, {300, 200}, {451, 13}, {1000, 3}, {1, 1}}
+
+	for _, f := range Filters {
+		for _, size := range sizes {
+			out := Resize(src, size, f)
+			if out.Bounds() != image.Rect(0, 0, size.X, size.Y) {
+				t.Fatalf("%s %v: got bounds %v", f.Name, size, out.Bounds())
+			}
+			for y := 0; y < size.Y; y++ {
+				for x := 0; x < size.X; x++ {
+					if got := out.NRGBAAt(x, y); got != c {
+						t.Fatalf("%s %v: pixel %d,%d: expected %v, got %v", f.Name, size, x, y, c, got)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Test_ResizeTransparent checks that fully transparent pixels do not bleed
+// their color into opaque neighbours.
+func Test_ResizeTransparent(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 1))
+	src.SetNRGBA(0, 0, color.NRGBA{R: 255, A: 0})
+	src.SetNRGBA(1, 0, color.NRGBA{R: 255, A: 0})
+	src.SetNRGBA(2, 0, color.NRGBA{G: 255, A: 255})
+	src.SetNRGBA(3, 0, color.NRGBA{G: 255, A: 255})
+
+	for _, f := range []Filter{Box, Bilinear, Bicubic, Lanczos3} {
+		out := Resize(src, image.Pt(3, 1), f)
+		for x := 0; x < 3; x++ {
+			if c := out.NRGBAAt(x, 0); c.A != 0 && c.R != 0 {
+				t.Errorf("%s: pixel %d: red bled into %v", f.Name, x, c)
+			}
+		}
+	}
+}
+
+func Test_ResizeEmpty(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+
+	for _, size := range []image.Point{{0, 5}, {5, 0}, {-1, -1}} {
+		if out := Resize(src, size, Bilinear); !out.Bounds().Empty() {
+			t.Errorf("%v: expected an empty image, got %v", size, out.Bounds())
+		}
+	}
+	if out := Resize(image.NewNRGBA(image.Rectangle{}), image.Pt(3, 3), Bilinear); out.Bounds().Size() != image.Pt(3, 3) {
+		t.Errorf("expected a 3x3 image, got %v", out.Bounds())
+	}
+}
+
+func Benchmark_Resize(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	r := image.Rect(0, 0, 300, 300)
+
+	ycbcr := image.NewYCbCr(r, image.YCbCrSubsampleRatio420)
+	for _, p := range [][]uint8{ycbcr.Y, ycbcr.Cb, ycbcr.Cr} {
+		rnd.Read(p)
+	}
+
+	var tt = []struct {
+		name string
+		img  image.Image
+	}{
+		{"nrgba", randomImage(rnd, r, false)},
+		{"ycbcr", ycbcr},
+	}
+
+	for _, tc := range tt {
+		for _, f := range Filters {
+			b.Run(tc.name+"/"+f.Name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					Resize(tc.img, image.Pt(32, 32), f)
+				}
+			})
+		}
+	}
+}
+
+// grayLine is an opaque image of a single row, or column, of gray values.
+func grayLine(values []uint8, vertical bool) *image.NRGBA {
+	r := image.Rect(0, 0, len(values), 1)
+	if vertical {
+		r = image.Rect(0, 0, 1, len(values))
+	}
+
+	img := image.NewNRGBA(r)
+	for i, v := range values {
+		img.Pix[i*4], img.Pix[i*4+1], img.Pix[i*4+2], img.Pix[i*4+3] = v, v, v, 255
+	}
+	return img
+}
+
+func grayValues(img *image.NRGBA) []uint8 {
+	values := make([]uint8, 0, len(img.Pix)/4)
+	for i := 0; i < len(img.Pix); i += 4 {
+		values = append(values, img.Pix[i])
+	}
+	return values
+}
+
+func randomImage(rnd *rand.Rand, r image.Rectangle, translucent bool) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2] = uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), uint8(rnd.Intn(256))
+		img.Pix[i+3] = 255
+		if translucent {
+			img.Pix[i+3] = uint8(1 + rnd.Intn(255))
+		}
+	}
+	return img
+}
+
+// closeNRGBA compares colors after a round trip through premultiplied alpha,
+// which loses precision on the color of translucent pixels.
+func closeNRGBA(a, b color.NRGBA) bool {
+	if a.A != b.A {
+		return false
+	}
+
+	tolerance := 255/int(a.A) + 1
+	diff := func(x, y uint8) bool { return max(int(x)-int(y), int(y)-int(x)) <= tolerance }
+	return diff(a.R, b.R) && diff(a.G, b.G) && diff(a.B, b.B)
+}
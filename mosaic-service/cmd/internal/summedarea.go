@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"image"
+
+	"github.com/ChrisShia/tilestore"
+	"github.com/ChrisShia/tilestore/pixel"
+)
+
+// maxSummedArea is the largest area whose sums fit in 32 bits.
+const maxSummedArea = (1<<32 - 1) / 0xffff
+
+// SummedArea is a summed-area table of the alpha-premultiplied 16 bit linear
+// light red, green, blue and alpha of an image, as tile descriptors are
+// averaged. It is built in a single pass over the image, after which the
+// sums of any rectangle are read from four entries of the table, whatever
+// its size.
+//
+// Entries are kept modulo 2^32, which still gives the exact sums of
+// rectangles of up to maxSummedArea pixels. Larger ones are averaged
+// directly.
+type SummedArea struct {
+	img    image.Image
+	rect   image.Rectangle
+	stride int
+	sums   []uint32
+}
+
+// NewSummedArea builds the table of img, reading it once.
+func NewSummedArea(img image.Image) *SummedArea {
+	rect := img.Bounds()
+	w, h := rect.Dx(), rect.Dy()
+
+	s := &SummedArea{
+		img:    img,
+		rect:   rect,
+		stride: (w + 1) * 4,
+		sums:   make([]uint32, (w+1)*(h+1)*4),
+	}
+
+	row := make([]uint16, w*4)
+	for y := 0; y < h; y++ {
+		pixel.Row(img, rect.Min.Y+y, rect.Min.X, rect.Max.X, row)
+
+		above := s.sums[y*s.stride : (y+1)*s.stride]
+		current := s.sums[(y+1)*s.stride : (y+2)*s.stride]
+		var acc [4]uint32
+		for x := 0; x < w*4; x += 4 {
+			r, g, b, a := tilestore.LinearRGBA(row[x], row[x+1], row[x+2], row[x+3])
+			acc[0] += uint32(r)
+			acc[1] += uint32(g)
+			acc[2] += uint32(b)
+			acc[3] += uint32(a)
+			for c := range acc {
+				current[x+4+c] = above[x+4+c] + acc[c]
+			}
+		}
+	}
+
+	return s
+}
+
+// AverageRGBArea is AverageRGBArea of the image over the given area, clipped
+// to the bounds of the image.
+func (s *SummedArea) AverageRGBArea(xMin, xMax, yMin, yMax int) [3]float64 {
+	avg, _ := s.AverageRGBAArea(xMin, xMax, yMin, yMax)
+	return avg
+}
+
+// AverageRGBAArea is tilestore.AverageArea of the image over the given area,
+// clipped to the bounds of the image.
+func (s *SummedArea) AverageRGBAArea(xMin, xMax, yMin, yMax int) ([3]float64, float64) {
+	r := image.Rect(xMin, yMin, xMax, yMax).Intersect(s.rect)
+	if r.Empty() {
+		return [3]float64{0, 0, 0}, 0
+	}
+
+	area := r.Dx() * r.Dy()
+	if area > maxSummedArea {
+		return tilestore.AverageArea(s.img, r)
+	}
+
+	x0, x1 := (r.Min.X-s.rect.Min.X)*4, (r.Max.X-s.rect.Min.X)*4
+	y0, y1 := (r.Min.Y-s.rect.Min.Y)*s.stride, (r.Max.Y-s.rect.Min.Y)*s.stride
+
+	sum := func(c int) uint64 {
+		return uint64(s.sums[y1+x1+c] - s.sums[y1+x0+c] - s.sums[y0+x1+c] + s.sums[y0+x0+c])
+	}
+
+	return tilestore.ColorSum{R: sum(0), G: sum(1), B: sum(2), A: sum(3)}.Average(uint64(area))
+}
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/ChrisShia/tilestore"
+)
+
+func Test_SummedAreaMatchesAverageRGBArea(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	var tt = []struct {
+		name string
+		img  image.Image
+	}{
+		{"nrgba", randomNRGBA(rnd, image.Rect(-7, 4, 90, 61))},
+		{"ycbcr", randomYCbCr(rnd, image.Rect(0, 0, 64, 48))},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewSummedArea(tc.img)
+			b := tc.img.Bounds()
+
+			for q := 0; q < 500; q++ {
+				x0, x1 := b.Min.X+rnd.Intn(b.Dx()), b.Min.X+rnd.Intn(b.Dx())
+				y0, y1 := b.Min.Y+rnd.Intn(b.Dy()), b.Min.Y+rnd.Intn(b.Dy())
+				r := image.Rect(x0, y0, x1+1, y1+1)
+
+				expected, expectedCoverage := tilestore.AverageArea(tc.img, r)
+				got, coverage := s.AverageRGBAArea(r.Min.X, r.Max.X, r.Min.Y, r.Max.Y)
+				if !closeColors(expected, got) || math.Abs(expectedCoverage-coverage) > 1e-9 {
+					t.Fatalf("%v: expected %v covering %v, got %v covering %v", r, expected, expectedCoverage, got, coverage)
+				}
+			}
+
+			whole := AverageRGBArea(tc.img, b.Min.X, b.Max.X, b.Min.Y, b.Max.Y)
+			clipped := s.AverageRGBArea(b.Min.X-10, b.Max.X+10, b.Min.Y-10, b.Max.Y+10)
+			if !closeColors(whole, clipped) {
+				t.Errorf("expected the area clipped to the image, %v, got %v", whole, clipped)
+			}
+
+			if empty := s.AverageRGBArea(b.Max.X, b.Max.X+5, b.Min.Y, b.Max.Y); empty != [3]float64{} {
+				t.Errorf("expected no color outside of the image, got %v", empty)
+			}
+		})
+	}
+}
+
+// Benchmark_CellAverages averages the cells of a grid, and of the same grid
+// shifted by half a cell as brick and hex layouts overlap it, directly and
+// through a summed-area table built for them.
+func Benchmark_CellAverages(b *testing.B) {
+	const size, cell = 1400, 20
+	r := image.Rect(0, 0, size, size)
+
+	var tt = []struct {
+		name string
+		img  image.Image
+	}{
+		{"nrgba", opaque(randomNRGBA(rand.New(rand.NewSource(1)), r))},
+		{"ycbcr", randomYCbCr(rand.New(rand.NewSource(1)), r)},
+	}
+
+	cells := func(average func(xMin, xMax, yMin, yMax int) [3]float64) {
+		for _, offset := range []int{0, cell / 2} {
+			for y := offset; y < size; y += cell {
+				for x := offset; x < size; x += cell {
+					average(x, x+cell, y, y+cell)
+				}
+			}
+		}
+	}
+
+	for _, tc := range tt {
+		b.Run(tc.name+"/direct", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				cells(func(xMin, xMax, yMin, yMax int) [3]float64 {
+					return AverageRGBArea(tc.img, xMin, xMax, yMin, yMax)
+				})
+			}
+		})
+
+		b.Run(tc.name+"/summed area", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				cells(NewSummedArea(tc.img).AverageRGBArea)
+			}
+		})
+	}
+}
+
+func randomNRGBA(rnd *rand.Rand, r image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(rnd.Intn(256)), G: uint8(rnd.Intn(256)), B: uint8(rnd.Intn(256)), A: uint8(rnd.Intn(256)),
+			})
+		}
+	}
+	return img
+}
+
+func opaque(img *image.NRGBA) *image.NRGBA {
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+	return img
+}
+
+func randomYCbCr(rnd *rand.Rand, r image.Rectangle) *image.YCbCr {
+	img := image.NewYCbCr(r, image.YCbCrSubsampleRatio420)
+	for _, p := range [][]uint8{img.Y, img.Cb, img.Cr} {
+		rnd.Read(p)
+	}
+	return img
+}
+
+func closeColors(a, b [3]float64) bool {
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
@@ -1,7 +1,101 @@
 package internal
 
-import "image"
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"sync"
+)
 
+var ErrLookupPanic = errors.New("panic while looking up a tile")
+
+// Tile describes a tile of a tile set as matched for a color.
+type Tile struct {
+	ID         string     `json:"id"`
+	Set        string     `json:"set,omitempty"`
+	Descriptor [3]float64 `json:"descriptor"`
+
+	// Distance is the euclidean distance between Descriptor and the color
+	// the tile was matched for.
+	Distance float64 `json:"distance"`
+
+	Source string `json:"source,omitempty"`
+	Author string `json:"author,omitempty"`
+}
+
+// TileRepository returns the tile nearest to a color along with its image.
+// Repositories that do not name their tiles leave the ID of the record
+// empty.
 type TileRepository interface {
-	Image(ac [3]float64) (image.Image, error)
+	Tile(ac [3]float64) (Tile, image.Image, error)
+}
+
+// BatchTileRepository is implemented by repositories able to resolve many
+// lookups at once. The returned slices are parallel to acs and hold a nil
+// image for the lookups that found no tile, the error reports a failure of
+// the batch as a whole.
+type BatchTileRepository interface {
+	TileRepository
+	Tiles(acs [][3]float64) ([]Tile, []image.Image, error)
+}
+
+// KeyedTileRepository is implemented by repositories whose tiles have a
+// stable ID, letting callers that cache decoded tiles skip fetching the ones
+// they already hold. Match resolves colors to tile records without their
+// images and is parallel to acs, the ID is empty for the colors without a
+// match. ImageByID decodes a tile.
+type KeyedTileRepository interface {
+	TileRepository
+	Match(acs [][3]float64) ([]Tile, error)
+	ImageByID(id string) (image.Image, error)
+}
+
+// Tiles resolves every color of acs against repo, through its batch API when
+// it has one and otherwise with up to concurrency lookups in flight. Failed
+// single lookups leave a nil image, a panicking one fails the whole call.
+func Tiles(repo TileRepository, acs [][3]float64, concurrency int) ([]Tile, []image.Image, error) {
+	if batch, ok := repo.(BatchTileRepository); ok {
+		return batch.Tiles(acs)
+	}
+
+	tiles := make([]Tile, len(acs))
+	imgs := make([]image.Image, len(acs))
+
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	var once sync.Once
+	var panicErr error
+
+	for i, ac := range acs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer func() {
+				if r := recover(); r != nil {
+					once.Do(func() { panicErr = fmt.Errorf("%w: %v", ErrLookupPanic, r) })
+				}
+			}()
+
+			tile, img, err := repo.Tile(ac)
+			if err != nil {
+				return
+			}
+			tiles[i], imgs[i] = tile, img
+		}()
+	}
+	wg.Wait()
+
+	if panicErr != nil {
+		return nil, nil, panicErr
+	}
+
+	return tiles, imgs, nil
+}
+
+// Distance is the euclidean distance between two descriptors.
+func Distance(a, b [3]float64) float64 {
+	return math.Sqrt(squaredDistance(a, b))
 }
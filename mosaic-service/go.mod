@@ -9,6 +9,9 @@ require (
 )
 
 require (
+	github.com/ChrisShia/tilestore v0.0.0
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 )
+
+replace github.com/ChrisShia/tilestore => ../tilestore
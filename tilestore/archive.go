@@ -0,0 +1,449 @@
+package tilestore
+
+import (
+	"archive/tar"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ArchiveFormat is the version of the layout of the archives Export writes.
+//
+// An archive is a tar holding manifest.json first, then the image of every
+// tile under tiles/, in the order of the manifest: tiles before their
+// variants.
+const ArchiveFormat = 1
+
+const (
+	manifestName = "manifest.json"
+	tilesDir     = "tiles/"
+
+	// maxArchivedImage bounds the images read from archives, maxManifest
+	// their manifest.
+	maxArchivedImage = 32 << 20
+	maxManifest      = 256 << 20
+)
+
+var (
+	ErrInvalidArchive = errors.New("invalid tile set archive")
+	ErrCorruptArchive = errors.New("corrupt tile set archive")
+)
+
+// Manifest describes a tile set archive: the set it was exported from, at
+// which schema and with which index, and its tiles.
+type Manifest struct {
+	Format  int            `json:"format"`
+	Name    string         `json:"name"`
+	Schema  int            `json:"schema_version"`
+	Index   Index          `json:"index"`
+	Created time.Time      `json:"created"`
+	Tiles   []ArchivedTile `json:"tiles"`
+}
+
+// ArchivedTile is a tile of an archive. ID is its key in the set it was
+// exported from, File the name of its image in the archive, SHA256 the hash
+// of the image and Parent the ID of the tile a variant was made from.
+type ArchivedTile struct {
+	ID        string     `json:"id"`
+	File      string     `json:"file"`
+	SHA256    string     `json:"sha256"`
+	Size      int64      `json:"size"`
+	Vector    [3]float64 `json:"vector"`
+	Source    string     `json:"source,omitempty"`
+	Author    string     `json:"author,omitempty"`
+	Parent    string     `json:"parent,omitempty"`
+	Transform string     `json:"transform,omitempty"`
+}
+
+// Export writes the tiles of s to w as a tar archive, reading them batchSize
+// at a time, and returns its manifest. The tiles are read twice, once to
+// write the manifest ahead of the images, and tiles added in between are
+// not exported. Variants of tiles removed in between are left out. Sets must
+// be at the current schema.
+func Export(ctx context.Context, s *Set, w io.Writer, batchSize int) (*Manifest, error) {
+	if s.Schema.Version != SchemaVersion {
+		return nil, fmt.Errorf("%w: version %d", ErrOutdatedSchema, s.Schema.Version)
+	}
+	batchSize = max(batchSize, 1)
+
+	m := &Manifest{
+		Format:  ArchiveFormat,
+		Name:    s.Name,
+		Schema:  s.Schema.Version,
+		Index:   s.Index,
+		Created: time.Now().UTC().Truncate(time.Second),
+	}
+
+	err := s.scanBatches(ctx, batchSize, func(keys []string) error {
+		records, err := s.Records(ctx, keys)
+		if err != nil {
+			return err
+		}
+		imgs, err := s.Images(ctx, keys)
+		if err != nil {
+			return err
+		}
+
+		for i, record := range records {
+			// removed since the scan
+			if record == nil || imgs[i] == nil {
+				continue
+			}
+
+			m.Tiles = append(m.Tiles, ArchivedTile{
+				ID:        keys[i],
+				File:      tilesDir + strings.TrimPrefix(keys[i], s.Prefix+":") + extension(imgs[i]),
+				SHA256:    Hash(imgs[i]),
+				Size:      int64(len(imgs[i])),
+				Vector:    record.Vector,
+				Source:    record.Source,
+				Author:    record.Author,
+				Parent:    record.Parent,
+				Transform: record.Transform,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.sort(s.Prefix)
+
+	tw := tar.NewWriter(w)
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = writeEntry(tw, manifestName, manifest, m.Created); err != nil {
+		return nil, err
+	}
+
+	for start := 0; start < len(m.Tiles); start += batchSize {
+		batch := m.Tiles[start:min(start+batchSize, len(m.Tiles))]
+
+		keys := make([]string, len(batch))
+		for i, t := range batch {
+			keys[i] = t.ID
+		}
+		imgs, err := s.Images(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, t := range batch {
+			if imgs[i] == nil {
+				return nil, fmt.Errorf("%w: %s", ErrNoTile, t.ID)
+			}
+			if Hash(imgs[i]) != t.SHA256 {
+				return nil, fmt.Errorf("%s changed during the export", t.ID)
+			}
+			if err = writeEntry(tw, t.File, imgs[i], m.Created); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return m, tw.Close()
+}
+
+// sort orders the tiles of m by key, tiles before variants, and leaves out
+// the variants whose parent is not in m.
+func (m *Manifest) sort(prefix string) {
+	id := func(key string) int {
+		n, _ := strconv.Atoi(strings.TrimPrefix(key, prefix+":"))
+		return n
+	}
+	isVariant := func(t ArchivedTile) int {
+		if t.Parent != "" {
+			return 1
+		}
+		return 0
+	}
+
+	slices.SortFunc(m.Tiles, func(a, b ArchivedTile) int {
+		return cmp.Or(cmp.Compare(isVariant(a), isVariant(b)), cmp.Compare(id(a.ID), id(b.ID)), strings.Compare(a.ID, b.ID))
+	})
+
+	exported := make(map[string]bool, len(m.Tiles))
+	for _, t := range m.Tiles {
+		exported[t.ID] = true
+	}
+	m.Tiles = slices.DeleteFunc(m.Tiles, func(t ArchivedTile) bool {
+		return t.Parent != "" && !exported[t.Parent]
+	})
+}
+
+// validate checks that the tiles of m are named once each and that variants
+// follow their parent, itself no variant.
+func (m *Manifest) validate() error {
+	if m.Format != ArchiveFormat {
+		return fmt.Errorf("%w: format %d", ErrInvalidArchive, m.Format)
+	}
+
+	ids := make(map[string]bool, len(m.Tiles))
+	files := make(map[string]bool, len(m.Tiles))
+	parents := make(map[string]bool, len(m.Tiles))
+	for _, t := range m.Tiles {
+		switch {
+		case t.ID == "" || ids[t.ID]:
+			return fmt.Errorf("%w: tile id %q", ErrInvalidArchive, t.ID)
+		case !strings.HasPrefix(t.File, tilesDir) || files[t.File]:
+			return fmt.Errorf("%w: file %q", ErrInvalidArchive, t.File)
+		case len(t.SHA256) != 64:
+			return fmt.Errorf("%w: %s: hash %q", ErrInvalidArchive, t.ID, t.SHA256)
+		case t.Parent != "" && !parents[t.Parent]:
+			return fmt.Errorf("%w: %s: variant of %q, which does not precede it", ErrInvalidArchive, t.ID, t.Parent)
+		}
+
+		ids[t.ID] = true
+		files[t.File] = true
+		if t.Parent == "" {
+			parents[t.ID] = true
+		}
+	}
+
+	return nil
+}
+
+// ImportReport counts the tiles of an archive, those imported and those
+// skipped as the set held them already.
+type ImportReport struct {
+	Tiles    int `json:"tiles"`
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
+
+// Import adds the tiles of the archive read from r to the set named name,
+// creating it with the index of the archive when it does not exist. Tiles
+// get new keys and variants are linked to the new keys of their parents.
+// Tiles whose image the set holds already are skipped, so importing an
+// archive again, or after an import that failed, adds only what is missing.
+// Images are checked against the hashes of the manifest as they are read,
+// archives that do not match are reported as ErrCorruptArchive once the
+// tiles before the mismatch are imported. Descriptors are recomputed from the
+// images, those of archives exported since descriptors are averaged in linear
+// light must match them. Like Export, Import only writes sets at the current
+// schema, existing sets of an older one are reported as ErrOutdatedSchema.
+func Import(ctx context.Context, c *redis.Client, name string, r io.Reader, batchSize int) (ImportReport, error) {
+	if err := ValidateName(name); err != nil {
+		return ImportReport{}, err
+	}
+	tr := tar.NewReader(r)
+
+	m, err := readManifest(tr)
+	if err != nil {
+		return ImportReport{}, err
+	}
+	report := ImportReport{Tiles: len(m.Tiles)}
+
+	schema, err := SchemaAt(m.Schema)
+	if err != nil {
+		return report, err
+	}
+
+	s := New(c, name)
+	version, err := s.Version(ctx)
+	if err != nil {
+		return report, err
+	}
+	if version > 0 && version < SchemaVersion {
+		return report, fmt.Errorf("%w: version %d", ErrOutdatedSchema, version)
+	}
+
+	s.Index = m.Index
+	if err = s.EnsureIndex(ctx); err != nil {
+		return report, err
+	}
+
+	hashes, err := s.Hashes(ctx, max(batchSize, 1))
+	if err != nil {
+		return report, err
+	}
+
+	keys := make(map[string]string, len(m.Tiles))
+	for _, t := range m.Tiles {
+		data, err := readEntry(tr, t.File)
+		if err != nil {
+			return report, err
+		}
+		if Hash(data) != t.SHA256 {
+			return report, fmt.Errorf("%w: %s does not match its hash", ErrCorruptArchive, t.File)
+		}
+
+		if key, ok := hashes[t.SHA256]; ok {
+			keys[t.ID] = key
+			report.Skipped++
+			continue
+		}
+
+		v, err := DecodeDescriptor(data)
+		if err != nil {
+			return report, fmt.Errorf("%w: %s: %v", ErrCorruptArchive, t.File, err)
+		}
+		if schema.Linear && !sameDescriptor(v, t.Vector) {
+			return report, fmt.Errorf("%w: %s does not match its descriptor", ErrCorruptArchive, t.File)
+		}
+
+		tile := Tile{
+			Image:     data,
+			Vector:    v,
+			Source:    t.Source,
+			Author:    t.Author,
+			Parent:    keys[t.Parent],
+			Transform: t.Transform,
+		}
+
+		key, err := s.Add(ctx, tile)
+		if err != nil {
+			return report, err
+		}
+		keys[t.ID] = key
+		hashes[t.SHA256] = key
+		report.Imported++
+	}
+
+	return report, nil
+}
+
+// sameDescriptor reports whether a and b are the same descriptor, up to the
+// precision of FLOAT32 vectors.
+func sameDescriptor(a, b [3]float64) bool {
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-3 {
+			return false
+		}
+	}
+	return true
+}
+
+// Hashes maps the hashes of the images of the tiles of s to their keys. The
+// hashes of the tiles stored before tiles were hashed are computed from
+// their images and stored.
+func (s *Set) Hashes(ctx context.Context, batchSize int) (map[string]string, error) {
+	hashes := make(map[string]string)
+
+	err := s.scanBatches(ctx, batchSize, func(keys []string) error {
+		records, err := s.Records(ctx, keys)
+		if err != nil {
+			return err
+		}
+
+		var unhashed []string
+		for i, record := range records {
+			switch {
+			case record == nil:
+			case record.Hash == "":
+				unhashed = append(unhashed, keys[i])
+			default:
+				hashes[record.Hash] = keys[i]
+			}
+		}
+		if len(unhashed) == 0 {
+			return nil
+		}
+
+		imgs, err := s.Images(ctx, unhashed)
+		if err != nil {
+			return err
+		}
+
+		pipe := s.Client.Pipeline()
+		for i, img := range imgs {
+			if img == nil {
+				continue
+			}
+			hash := Hash(img)
+			hashes[hash] = unhashed[i]
+			pipe.HSet(ctx, unhashed[i], FieldHash, hash)
+		}
+
+		_, err = pipe.Exec(ctx)
+		return err
+	})
+
+	return hashes, err
+}
+
+// readManifest reads and validates the manifest, the first entry of the
+// archive.
+func readManifest(tr *tar.Reader) (*Manifest, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if hdr.Name != manifestName || hdr.Size > maxManifest {
+		return nil, fmt.Errorf("%w: starts with %s, expected %s", ErrInvalidArchive, hdr.Name, manifestName)
+	}
+
+	var m Manifest
+	if err = json.NewDecoder(tr).Decode(&m); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidArchive, manifestName, err)
+	}
+
+	return &m, m.validate()
+}
+
+// readEntry reads the next file of the archive, which must be named name.
+func readEntry(tr *tar.Reader, name string) ([]byte, error) {
+	hdr, err := tr.Next()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %s is missing", ErrCorruptArchive, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptArchive, err)
+	}
+	if hdr.Name != name {
+		return nil, fmt.Errorf("%w: found %s, expected %s", ErrInvalidArchive, hdr.Name, name)
+	}
+	if hdr.Size > maxArchivedImage {
+		return nil, fmt.Errorf("%w: %s is %d bytes", ErrInvalidArchive, name, hdr.Size)
+	}
+
+	data, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrCorruptArchive, name, err)
+	}
+
+	return data, nil
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Write(data)
+	return err
+}
+
+// extension is the file extension of an encoded image.
+func extension(img []byte) string {
+	switch http.DetectContentType(img) {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	default:
+		return ".img"
+	}
+}
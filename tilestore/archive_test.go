@@ -0,0 +1,193 @@
+package tilestore
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ManifestValidate(t *testing.T) {
+	hash := strings.Repeat("a", 64)
+	tile := func(id, parent string) ArchivedTile {
+		return ArchivedTile{ID: id, File: tilesDir + id + ".jpg", SHA256: hash, Parent: parent}
+	}
+
+	var tt = []struct {
+		name  string
+		tiles []ArchivedTile
+		err   error
+	}{
+		{"valid", []ArchivedTile{tile("1", ""), tile("2", ""), tile("3", "1")}, nil},
+		{"empty", nil, nil},
+		{"duplicate id", []ArchivedTile{tile("1", ""), tile("1", "")}, ErrInvalidArchive},
+		{"outside of tiles", []ArchivedTile{{ID: "1", File: "manifest.json", SHA256: hash}}, ErrInvalidArchive},
+		{"bad hash", []ArchivedTile{{ID: "1", File: tilesDir + "1.jpg", SHA256: "abc"}}, ErrInvalidArchive},
+		{"variant first", []ArchivedTile{tile("3", "1"), tile("1", "")}, ErrInvalidArchive},
+		{"variant of a variant", []ArchivedTile{tile("1", ""), tile("2", "1"), tile("3", "2")}, ErrInvalidArchive},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			m := Manifest{Format: ArchiveFormat, Tiles: tc.tiles}
+			if err := m.validate(); !errors.Is(err, tc.err) {
+				t.Errorf("expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+
+	if err := (&Manifest{Format: ArchiveFormat + 1}).validate(); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("expected %v for an unknown format, got %v", ErrInvalidArchive, err)
+	}
+}
+
+func Test_ManifestSort(t *testing.T) {
+	m := Manifest{Tiles: []ArchivedTile{
+		{ID: "img:s:10"},
+		{ID: "img:s:3", Parent: "img:s:2"},
+		{ID: "img:s:2"},
+		{ID: "img:s:11", Parent: "img:s:7"},
+		{ID: "img:s:9", Parent: "img:s:10"},
+	}}
+	m.sort("img:s")
+
+	ids := make([]string, len(m.Tiles))
+	for i, t := range m.Tiles {
+		ids[i] = t.ID
+	}
+	// the variant of the missing 7 is left out
+	expected := []string{"img:s:2", "img:s:10", "img:s:3", "img:s:9"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected %v, got %v", expected, ids)
+	}
+	if err := (&Manifest{Format: ArchiveFormat, Tiles: m.Tiles}).validate(); err == nil {
+		t.Errorf("expected the tiles without files to be invalid")
+	}
+}
+
+func Test_readManifest(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := writeEntry(tw, tilesDir+"1.jpg", []byte{1}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+
+	if _, err := readManifest(tar.NewReader(&buf)); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("expected %v for an archive not starting with its manifest, got %v", ErrInvalidArchive, err)
+	}
+	if _, err := readManifest(tar.NewReader(strings.NewReader("not a tar"))); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("expected %v, got %v", ErrInvalidArchive, err)
+	}
+}
+
+// Test_ExportImport needs redis stack on localhost:6378.
+func Test_ExportImport(t *testing.T) {
+	c := redisTestClient(t)
+	ctx := context.Background()
+
+	s := New(c, "tilestore-export-test")
+	dropSet(t, s)
+	imported := New(c, "tilestore-import-test")
+	dropSet(t, imported)
+
+	if err := s.EnsureIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	imgs := [][]byte{
+		checkerPNG(t, color.White, color.Black),
+		checkerPNG(t, color.NRGBA{R: 200, A: 255}, color.Black),
+		checkerPNG(t, color.NRGBA{G: 200, A: 255}, color.Black),
+	}
+	vectors := make([][3]float64, len(imgs))
+	for i, img := range imgs {
+		var err error
+		if vectors[i], err = DecodeDescriptor(img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parent, err := s.Add(ctx, Tile{Image: imgs[0], Vector: vectors[0], Source: "https://picsum.photos/id/1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Add(ctx, Tile{Image: imgs[1], Vector: vectors[1], Parent: parent, Transform: "hue120"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Add(ctx, Tile{Image: imgs[2], Vector: vectors[2]}); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	m, err := Export(ctx, s, &archive, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Tiles) != 3 || m.Tiles[2].Parent != parent {
+		t.Fatalf("expected 3 tiles, the variant last, got %+v", m.Tiles)
+	}
+
+	report, err := Import(ctx, c, imported.Name, bytes.NewReader(archive.Bytes()), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (ImportReport{Tiles: 3, Imported: 3}) {
+		t.Errorf("expected 3 tiles imported, got %+v", report)
+	}
+
+	if report, err = Import(ctx, c, imported.Name, bytes.NewReader(archive.Bytes()), 2); err != nil || report.Skipped != 3 {
+		t.Errorf("expected importing again to skip every tile, got %+v, %v", report, err)
+	}
+
+	opened, err := Open(ctx, c, imported.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	variants, err := opened.Variants(ctx, opened.Key(1))
+	if err != nil || len(variants) != 1 {
+		t.Fatalf("expected the variant linked to its parent, got %v, %v", variants, err)
+	}
+	records, err := opened.Records(ctx, variants)
+	if err != nil || records[0].Vector != m.Tiles[2].Vector || records[0].Transform != "hue120" {
+		t.Errorf("expected the variant as exported, got %+v, %v", records[0], err)
+	}
+
+	// an image altered after export
+	corrupt := bytes.Clone(archive.Bytes())
+	i := bytes.Index(corrupt, imgs[2])
+	corrupt[i+len(imgs[2])-1] ^= 0xff
+	dropSet(t, imported)
+	report, err = Import(ctx, c, imported.Name, bytes.NewReader(corrupt), 2)
+	if !errors.Is(err, ErrCorruptArchive) {
+		t.Errorf("expected %v, got %v", ErrCorruptArchive, err)
+	}
+	if report.Imported != 1 {
+		t.Errorf("expected the tiles before the corrupt one imported, got %+v", report)
+	}
+
+	// a descriptor that does not match its image
+	if _, err = s.Add(ctx, Tile{Image: checkerPNG(t, color.NRGBA{B: 200, A: 255}, color.Black), Vector: [3]float64{7, 8, 9}}); err != nil {
+		t.Fatal(err)
+	}
+	archive.Reset()
+	if _, err = Export(ctx, s, &archive, 2); err != nil {
+		t.Fatal(err)
+	}
+	dropSet(t, imported)
+	if _, err = Import(ctx, c, imported.Name, bytes.NewReader(archive.Bytes()), 2); !errors.Is(err, ErrCorruptArchive) {
+		t.Errorf("expected %v, got %v", ErrCorruptArchive, err)
+	}
+
+	// a set of an older schema
+	dropSet(t, imported)
+	if err = c.HSet(ctx, imported.metaKey(), "version", SchemaVersion-1).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Import(ctx, c, imported.Name, bytes.NewReader(archive.Bytes()), 2); !errors.Is(err, ErrOutdatedSchema) {
+		t.Errorf("expected %v, got %v", ErrOutdatedSchema, err)
+	}
+}
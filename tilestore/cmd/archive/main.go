@@ -0,0 +1,125 @@
+// Command archive exports tile sets stored in redis to tar archives and
+// imports them back, into the same or another redis. Archives named .gz or
+// .tgz are gzipped.
+//
+//	archive -redis redis://localhost:6379 -set 172.18.0.1 -export set.tar.gz
+//	archive -redis redis://localhost:6379 -set 172.18.0.1 -import set.tar.gz
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ChrisShia/tilestore"
+	"github.com/redis/go-redis/v9"
+)
+
+func main() {
+	var (
+		addr       string
+		name       string
+		exportPath string
+		importPath string
+		batchSize  int
+	)
+
+	flag.StringVar(&addr, "redis", "redis://localhost:6379", "redis server URL")
+	flag.StringVar(&name, "set", "", "name of the tile set")
+	flag.StringVar(&exportPath, "export", "", "archive to export the tile set to")
+	flag.StringVar(&importPath, "import", "", "archive to import into the tile set")
+	flag.IntVar(&batchSize, "batch", 256, "tiles read or written per pipeline")
+	flag.Parse()
+
+	if name == "" || (exportPath == "") == (importPath == "") {
+		fmt.Fprintln(os.Stderr, "-set and exactly one of -export and -import are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opt, err := redis.ParseURL(addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client := redis.NewClient(opt)
+	defer client.Close()
+
+	ctx := context.Background()
+
+	if exportPath != "" {
+		err = export(ctx, client, name, exportPath, batchSize)
+	} else {
+		err = importArchive(ctx, client, name, importPath, batchSize)
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", name, err)
+	}
+}
+
+func export(ctx context.Context, client *redis.Client, name, path string, batchSize int) error {
+	s, err := tilestore.Open(ctx, client, name)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	var zw *gzip.Writer
+	if gzipped(path) {
+		zw = gzip.NewWriter(f)
+		w = zw
+	}
+
+	m, err := tilestore.Export(ctx, s, w, batchSize)
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	if zw != nil {
+		if err = zw.Close(); err != nil {
+			return err
+		}
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	log.Printf("%s: exported %d tiles to %s", name, len(m.Tiles), path)
+	return nil
+}
+
+func importArchive(ctx context.Context, client *redis.Client, name, path string, batchSize int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped(path) {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	report, err := tilestore.Import(ctx, client, name, r, batchSize)
+	log.Printf("%s: imported %d of %d tiles from %s, %d already in the set", name, report.Imported, report.Tiles, path, report.Skipped)
+	return err
+}
+
+func gzipped(path string) bool {
+	return strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".tgz")
+}
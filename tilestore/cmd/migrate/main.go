@@ -0,0 +1,87 @@
+// Command migrate upgrades tile sets stored in redis to the current schema
+// version.
+//
+//	migrate -redis redis://localhost:6379 -set 172.18.0.1
+//	migrate -redis redis://localhost:6379 -all
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ChrisShia/tilestore"
+	"github.com/redis/go-redis/v9"
+)
+
+func main() {
+	var (
+		addr      string
+		name      string
+		all       bool
+		dryRun    bool
+		batchSize int
+	)
+
+	flag.StringVar(&addr, "redis", "redis://localhost:6379", "redis server URL")
+	flag.StringVar(&name, "set", "", "name of the tile set to migrate")
+	flag.BoolVar(&all, "all", false, "migrate every tile set with an index")
+	flag.BoolVar(&dryRun, "dry-run", false, "only report the version of the tile sets")
+	flag.IntVar(&batchSize, "batch", 256, "tiles rewritten per pipeline")
+	flag.Parse()
+
+	if (name == "") == !all {
+		fmt.Fprintln(os.Stderr, "exactly one of -set and -all is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opt, err := redis.ParseURL(addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client := redis.NewClient(opt)
+	defer client.Close()
+
+	ctx := context.Background()
+
+	names := []string{name}
+	if all {
+		if names, err = tilestore.List(ctx, client); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	failed := false
+	for _, name := range names {
+		if dryRun {
+			version, err := tilestore.New(client, name).Version(ctx)
+			if err != nil {
+				log.Printf("%s: %v", name, err)
+				failed = true
+				continue
+			}
+			log.Printf("%s: version %d of %d", name, version, tilestore.SchemaVersion)
+			continue
+		}
+
+		from, err := tilestore.Migrate(ctx, client, name, batchSize)
+		if err != nil {
+			log.Printf("%s: %v", name, err)
+			failed = true
+			continue
+		}
+
+		if from == tilestore.SchemaVersion {
+			log.Printf("%s: already at version %d", name, from)
+		} else {
+			log.Printf("%s: migrated from version %d to %d", name, from, tilestore.SchemaVersion)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,144 @@
+package tilestore
+
+import (
+	"bytes"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+
+	"github.com/ChrisShia/tilestore/pixel"
+)
+
+// Descriptors, the vectors tiles are indexed by, are the average colors of
+// the tiles in 8 bit sRGB, from 0 to 255. Since schema version 4 they are
+// averaged in linear light with 16 bit precision, as every pixel of both the
+// tiles and the originals must be for their averages to be comparable.
+
+// linear maps 16 bit sRGB values to 16 bit linear light and srgb8 16 bit
+// linear light back to 8 bit sRGB.
+var (
+	linear [1 << 16]uint16
+	srgb8  [1 << 16]uint8
+)
+
+func init() {
+	for v := range linear {
+		linear[v] = uint16(math.Round(decodeSRGB(float64(v)/0xffff) * 0xffff))
+		srgb8[v] = uint8(math.Round(encodeSRGB(float64(v)/0xffff) * 0xff))
+	}
+}
+
+func decodeSRGB(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func encodeSRGB(l float64) float64 {
+	if l <= 0.0031308 {
+		return l * 12.92
+	}
+	return 1.055*math.Pow(l, 1/2.4) - 0.055
+}
+
+// LinearRGBA converts an alpha-premultiplied 16 bit sRGB pixel, as
+// color.Color.RGBA returns it, to alpha-premultiplied 16 bit linear light.
+func LinearRGBA(r, g, b, a uint16) (uint16, uint16, uint16, uint16) {
+	switch a {
+	case 0xffff:
+		return linear[r], linear[g], linear[b], a
+	case 0:
+		return 0, 0, 0, 0
+	}
+
+	// the transfer function applies to the colors before premultiplication
+	premultiplied := func(v uint16) uint16 {
+		straight := min(uint32(v)*0xffff/uint32(a), 0xffff)
+		return uint16(uint32(linear[straight]) * uint32(a) / 0xffff)
+	}
+	return premultiplied(r), premultiplied(g), premultiplied(b), a
+}
+
+// SRGB8 converts a straight, not premultiplied, 16 bit linear light value
+// to 8 bit sRGB.
+func SRGB8(l uint16) uint8 {
+	return srgb8[l]
+}
+
+// ColorSum sums alpha-premultiplied 16 bit linear light pixels into a
+// descriptor.
+type ColorSum struct {
+	R, G, B, A uint64
+}
+
+// Add adds an alpha-premultiplied 16 bit sRGB pixel, as color.Color.RGBA
+// returns it.
+func (s *ColorSum) Add(r, g, b, a uint16) {
+	lr, lg, lb, la := LinearRGBA(r, g, b, a)
+	s.R += uint64(lr)
+	s.G += uint64(lg)
+	s.B += uint64(lb)
+	s.A += uint64(la)
+}
+
+// Average returns the average color of the pixels summed, weighted by alpha
+// and encoded back to 8 bit sRGB, and their coverage of count pixels, their
+// mean alpha from 0 to 1. Sums without coverage average to black.
+func (s ColorSum) Average(count uint64) ([3]float64, float64) {
+	if s.A == 0 || count == 0 {
+		return [3]float64{0, 0, 0}, 0
+	}
+
+	a := float64(s.A)
+	avg := [3]float64{
+		encodeSRGB(float64(s.R)/a) * 255,
+		encodeSRGB(float64(s.G)/a) * 255,
+		encodeSRGB(float64(s.B)/a) * 255,
+	}
+
+	return avg, a / 0xffff / float64(count)
+}
+
+// Descriptor averages the color of img.
+func Descriptor(img image.Image) [3]float64 {
+	avg, _ := AverageArea(img, img.Bounds())
+	return avg
+}
+
+// AverageArea averages the color of img over area the way descriptors are,
+// weighting every pixel by its alpha so that translucent pixels count for
+// what they cover and transparent ones not at all. It also returns the
+// coverage of the area, its mean alpha from 0 to 1. Pixels of the area
+// outside of img are transparent and areas with no coverage average to
+// black.
+func AverageArea(img image.Image, area image.Rectangle) ([3]float64, float64) {
+	if area.Empty() {
+		return [3]float64{0, 0, 0}, 0
+	}
+
+	var sum ColorSum
+
+	r := area.Intersect(img.Bounds())
+	row := make([]uint16, r.Dx()*4)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		pixel.Row(img, y, r.Min.X, r.Max.X, row)
+		for i := 0; i < len(row); i += 4 {
+			sum.Add(row[i], row[i+1], row[i+2], row[i+3])
+		}
+	}
+
+	return sum.Average(uint64(area.Dx()) * uint64(area.Dy()))
+}
+
+// DecodeDescriptor decodes an encoded JPEG or PNG tile and averages its
+// color.
+func DecodeDescriptor(data []byte) ([3]float64, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return [3]float64{}, err
+	}
+
+	return Descriptor(img), nil
+}
@@ -0,0 +1,169 @@
+package tilestore
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"testing"
+)
+
+func Test_LinearRGBA(t *testing.T) {
+	var tt = []struct {
+		name       string
+		r, g, b, a uint16
+		expected   [4]uint16
+	}{
+		{"black", 0, 0, 0, 0xffff, [4]uint16{0, 0, 0, 0xffff}},
+		{"white", 0xffff, 0xffff, 0xffff, 0xffff, [4]uint16{0xffff, 0xffff, 0xffff, 0xffff}},
+		// sRGB 0.502 is 0.216 linear
+		{"mid gray", 0x8080, 0x8080, 0x8080, 0xffff, [4]uint16{14146, 14146, 14146, 0xffff}},
+		{"transparent", 0, 0, 0, 0, [4]uint16{0, 0, 0, 0}},
+		// premultiplied white at half alpha stays premultiplied white
+		{"translucent white", 0x8000, 0x8000, 0x8000, 0x8000, [4]uint16{0x8000, 0x8000, 0x8000, 0x8000}},
+		// straight mid gray at half alpha is premultiplied after decoding
+		{"translucent gray", 0x4040, 0, 0, 0x8000, [4]uint16{7072, 0, 0, 0x8000}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, g, b, a := LinearRGBA(tc.r, tc.g, tc.b, tc.a)
+			if got := [4]uint16{r, g, b, a}; got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+// Test_SRGB8 decodes every 8 bit sRGB value to linear light and encodes it
+// back.
+func Test_SRGB8(t *testing.T) {
+	for v := 0; v < 0x100; v++ {
+		l, _, _, _ := LinearRGBA(uint16(v*0x101), 0, 0, 0xffff)
+		if got := SRGB8(l); got != uint8(v) {
+			t.Errorf("expected %d, got %d", v, got)
+		}
+	}
+}
+
+func Test_ColorSumAverage(t *testing.T) {
+	add := func(s *ColorSum, c color.Color, n int) {
+		r, g, b, a := c.RGBA()
+		for i := 0; i < n; i++ {
+			s.Add(uint16(r), uint16(g), uint16(b), uint16(a))
+		}
+	}
+
+	var tt = []struct {
+		name     string
+		pixels   map[color.Color]int
+		expected [3]float64
+		coverage float64
+	}{
+		// linear 0.5, not the 127.5 averaging sRGB values gives
+		{"black and white", map[color.Color]int{color.White: 1, color.Black: 1}, [3]float64{187.516, 187.516, 187.516}, 1},
+		{"uniform", map[color.Color]int{color.NRGBA{R: 17, G: 128, B: 250, A: 255}: 3}, [3]float64{17, 128, 250}, 1},
+		{"translucent", map[color.Color]int{color.NRGBA{R: 17, G: 128, B: 250, A: 100}: 3}, [3]float64{17, 128, 250}, 100.0 / 255},
+		{"transparent", map[color.Color]int{color.NRGBA{R: 255}: 1, color.NRGBA{G: 40, A: 255}: 1}, [3]float64{0, 40, 0}, 0.5},
+		{"empty", map[color.Color]int{}, [3]float64{}, 0},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var s ColorSum
+			count := 0
+			for c, n := range tc.pixels {
+				add(&s, c, n)
+				count += n
+			}
+
+			// premultiplied 16 bit pixels lose some precision on the dark
+			// channels of translucent colors
+			avg, coverage := s.Average(uint64(count))
+			for i := range avg {
+				if math.Abs(avg[i]-tc.expected[i]) > 0.15 {
+					t.Fatalf("expected %v, got %v", tc.expected, avg)
+				}
+			}
+			if math.Abs(coverage-tc.coverage) > 1e-3 {
+				t.Errorf("expected a coverage of %v, got %v", tc.coverage, coverage)
+			}
+		})
+	}
+}
+
+func Test_AverageArea(t *testing.T) {
+	// fill paints the left and right halves of a 10x4 image
+	fill := func(left, right color.NRGBA) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, 10, 4))
+		draw.Draw(img, image.Rect(0, 0, 5, 4), image.NewUniform(left), image.Point{}, draw.Src)
+		draw.Draw(img, image.Rect(5, 0, 10, 4), image.NewUniform(right), image.Point{}, draw.Src)
+		return img
+	}
+
+	// expected colors are worked out in linear light with the sRGB transfer
+	// function, up to the 16 bit precision of the sums
+	var tt = []struct {
+		name     string
+		img      image.Image
+		area     image.Rectangle
+		expected [3]float64
+		coverage float64
+	}{
+		{"uniform", fill(color.NRGBA{R: 200, G: 10, B: 90, A: 255}, color.NRGBA{R: 200, G: 10, B: 90, A: 255}),
+			image.Rect(0, 0, 10, 4), [3]float64{200, 10, 90}, 1},
+		{"black and white", fill(color.NRGBA{R: 255, G: 255, B: 255, A: 255}, color.NRGBA{A: 255}),
+			image.Rect(0, 0, 10, 4), [3]float64{187.516, 187.516, 187.516}, 1},
+		{"transparent half", fill(color.NRGBA{R: 255, G: 255, B: 255, A: 0}, color.NRGBA{G: 120, A: 255}),
+			image.Rect(0, 0, 10, 4), [3]float64{0, 120, 0}, 0.5},
+		{"translucent", fill(color.NRGBA{R: 255, A: 51}, color.NRGBA{R: 255, A: 51}),
+			image.Rect(0, 0, 10, 4), [3]float64{255, 0, 0}, 0.2},
+		{"translucent over opaque", fill(color.NRGBA{R: 255, A: 85}, color.NRGBA{B: 255, A: 255}),
+			image.Rect(0, 0, 10, 4), [3]float64{136.960, 0, 224.610}, 340.0 / 510},
+		{"outside", fill(color.NRGBA{R: 10, A: 255}, color.NRGBA{R: 10, A: 255}),
+			image.Rect(5, 0, 15, 4), [3]float64{10, 0, 0}, 0.5},
+		{"fully transparent", fill(color.NRGBA{R: 255}, color.NRGBA{G: 255}),
+			image.Rect(0, 0, 10, 4), [3]float64{}, 0},
+		{"empty", fill(color.NRGBA{R: 255, A: 255}, color.NRGBA{R: 255, A: 255}),
+			image.Rect(3, 3, 3, 4), [3]float64{}, 0},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			avg, coverage := AverageArea(tc.img, tc.area)
+			for i := range avg {
+				if math.Abs(tc.expected[i]-avg[i]) > 0.05 {
+					t.Fatalf("expected %v, got %v", tc.expected, avg)
+				}
+			}
+			if math.Abs(tc.coverage-coverage) > 1e-9 {
+				t.Errorf("expected a coverage of %v, got %v", tc.coverage, coverage)
+			}
+		})
+	}
+}
+
+func Test_DecodeDescriptor(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	draw.Draw(img, image.Rect(0, 0, 2, 2), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(2, 0, 4, 2), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := DecodeDescriptor(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != Descriptor(img) || math.Abs(v[0]-187.516) > 0.05 {
+		t.Errorf("expected %v, got %v", Descriptor(img), v)
+	}
+
+	if _, err = DecodeDescriptor([]byte("not an image")); err == nil {
+		t.Errorf("expected an error decoding garbage")
+	}
+}
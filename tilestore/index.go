@@ -0,0 +1,150 @@
+package tilestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var ErrInvalidIndex = errors.New("invalid index definition")
+
+// Algorithm is the vector index algorithm of a set, as named by FT.CREATE.
+type Algorithm string
+
+const (
+	// HNSW searches an approximate graph, trading recall for speed on large
+	// sets.
+	HNSW Algorithm = "HNSW"
+	// FLAT compares the query with every tile, exact and fast enough for
+	// small sets.
+	FLAT Algorithm = "FLAT"
+)
+
+// Index is the definition of the vector index of a set. The HNSW parameters
+// keep the defaults of redis when zero and must be zero for FLAT indexes.
+type Index struct {
+	Algorithm Algorithm  `json:"algorithm"`
+	Vector    VectorType `json:"vector"`
+
+	// M is the number of edges per node of the graph.
+	M int `json:"m,omitempty"`
+	// EFConstruction is the number of candidates kept while building the
+	// graph.
+	EFConstruction int `json:"ef_construction,omitempty"`
+	// EFRuntime is the number of candidates kept while searching it.
+	EFRuntime int `json:"ef_runtime,omitempty"`
+}
+
+// DefaultIndex is the index sets are created with unless told otherwise.
+func DefaultIndex() Index {
+	return Index{Algorithm: HNSW, Vector: schemas[SchemaVersion].Vector}
+}
+
+// Validate reports definitions FT.CREATE would reject as ErrInvalidIndex.
+func (i Index) Validate() error {
+	switch i.Vector {
+	case Float32, Float64:
+	default:
+		return fmt.Errorf("%w: vector type %q", ErrInvalidIndex, i.Vector)
+	}
+
+	if i.M < 0 || i.EFConstruction < 0 || i.EFRuntime < 0 {
+		return fmt.Errorf("%w: negative HNSW parameter", ErrInvalidIndex)
+	}
+
+	switch i.Algorithm {
+	case HNSW:
+	case FLAT:
+		if i.M != 0 || i.EFConstruction != 0 || i.EFRuntime != 0 {
+			return fmt.Errorf("%w: HNSW parameters on a FLAT index", ErrInvalidIndex)
+		}
+	default:
+		return fmt.Errorf("%w: algorithm %q", ErrInvalidIndex, i.Algorithm)
+	}
+
+	return nil
+}
+
+// args are the FT.CREATE arguments declaring the vector field.
+func (i Index) args() []interface{} {
+	attrs := []interface{}{
+		"TYPE", string(i.Vector),
+		"DIM", "3",
+		"DISTANCE_METRIC", "L2",
+	}
+
+	hnsw := []struct {
+		name  string
+		value int
+	}{
+		{"M", i.M},
+		{"EF_CONSTRUCTION", i.EFConstruction},
+		{"EF_RUNTIME", i.EFRuntime},
+	}
+	for _, p := range hnsw {
+		if p.value > 0 {
+			attrs = append(attrs, p.name, strconv.Itoa(p.value))
+		}
+	}
+
+	args := []interface{}{FieldVector, "VECTOR", string(i.Algorithm), strconv.Itoa(len(attrs))}
+	return append(args, attrs...)
+}
+
+// Fields of the meta hash recording the index of a set.
+const (
+	metaAlgorithm      = "algorithm"
+	metaVector         = "vector"
+	metaM              = "m"
+	metaEFConstruction = "ef_construction"
+	metaEFRuntime      = "ef_runtime"
+)
+
+func (i Index) meta() map[string]interface{} {
+	return map[string]interface{}{
+		metaAlgorithm:      string(i.Algorithm),
+		metaVector:         string(i.Vector),
+		metaM:              i.M,
+		metaEFConstruction: i.EFConstruction,
+		metaEFRuntime:      i.EFRuntime,
+	}
+}
+
+// readIndex reads the index recorded in the meta hash. Sets that predate
+// configurable indexes have the default index of their schema.
+func (s *Set) readIndex(ctx context.Context) (Index, error) {
+	index := Index{Algorithm: HNSW, Vector: s.Schema.Vector}
+
+	meta, err := s.Client.HGetAll(ctx, s.metaKey()).Result()
+	if err != nil {
+		return index, err
+	}
+
+	if v, ok := meta[metaAlgorithm]; ok {
+		index.Algorithm = Algorithm(v)
+	}
+	if v, ok := meta[metaVector]; ok {
+		index.Vector = VectorType(v)
+	}
+
+	params := []struct {
+		field string
+		value *int
+	}{
+		{metaM, &index.M},
+		{metaEFConstruction, &index.EFConstruction},
+		{metaEFRuntime, &index.EFRuntime},
+	}
+	for _, p := range params {
+		v, ok := meta[p.field]
+		if !ok {
+			continue
+		}
+		if *p.value, err = strconv.Atoi(v); err != nil {
+			return index, fmt.Errorf("%w: %s %q", ErrInvalidIndex, p.field, v)
+		}
+	}
+
+	return index, index.Validate()
+}
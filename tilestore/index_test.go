@@ -0,0 +1,217 @@
+package tilestore
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func Test_IndexArgs(t *testing.T) {
+	var tt = []struct {
+		name     string
+		index    Index
+		expected []interface{}
+	}{
+		{"default", DefaultIndex(), []interface{}{
+			"average_color", "VECTOR", "HNSW", "6",
+			"TYPE", "FLOAT32", "DIM", "3", "DISTANCE_METRIC", "L2",
+		}},
+		{"flat", Index{Algorithm: FLAT, Vector: Float64}, []interface{}{
+			"average_color", "VECTOR", "FLAT", "6",
+			"TYPE", "FLOAT64", "DIM", "3", "DISTANCE_METRIC", "L2",
+		}},
+		{"tuned", Index{Algorithm: HNSW, Vector: Float32, M: 32, EFConstruction: 400, EFRuntime: 20}, []interface{}{
+			"average_color", "VECTOR", "HNSW", "12",
+			"TYPE", "FLOAT32", "DIM", "3", "DISTANCE_METRIC", "L2",
+			"M", "32", "EF_CONSTRUCTION", "400", "EF_RUNTIME", "20",
+		}},
+		{"partly tuned", Index{Algorithm: HNSW, Vector: Float32, EFRuntime: 50}, []interface{}{
+			"average_color", "VECTOR", "HNSW", "8",
+			"TYPE", "FLOAT32", "DIM", "3", "DISTANCE_METRIC", "L2",
+			"EF_RUNTIME", "50",
+		}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if args := tc.index.args(); !reflect.DeepEqual(tc.expected, args) {
+				t.Errorf("expected %v, got %v", tc.expected, args)
+			}
+		})
+	}
+}
+
+func Test_IndexValidate(t *testing.T) {
+	var tt = []struct {
+		name  string
+		index Index
+		valid bool
+	}{
+		{"default", DefaultIndex(), true},
+		{"flat", Index{Algorithm: FLAT, Vector: Float32}, true},
+		{"tuned", Index{Algorithm: HNSW, Vector: Float64, M: 8, EFConstruction: 100, EFRuntime: 10}, true},
+		{"unknown algorithm", Index{Algorithm: "IVF", Vector: Float32}, false},
+		{"unknown vector", Index{Algorithm: HNSW, Vector: "FLOAT16"}, false},
+		{"negative", Index{Algorithm: HNSW, Vector: Float32, M: -1}, false},
+		{"flat with HNSW parameters", Index{Algorithm: FLAT, Vector: Float32, EFRuntime: 10}, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.index.Validate()
+			if tc.valid && err != nil {
+				t.Errorf("expected a valid index, got %v", err)
+			}
+			if !tc.valid && !errors.Is(err, ErrInvalidIndex) {
+				t.Errorf("expected %v, got %v", ErrInvalidIndex, err)
+			}
+		})
+	}
+}
+
+// Test_OpenIndex needs redis stack on localhost:6378.
+func Test_OpenIndex(t *testing.T) {
+	c := redisTestClient(t)
+	ctx := context.Background()
+
+	s := New(c, "tilestore-index-test")
+	s.Index = Index{Algorithm: HNSW, Vector: Float64, M: 8, EFConstruction: 100, EFRuntime: 20}
+	dropSet(t, s)
+
+	if err := s.EnsureIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add(ctx, Tile{Image: []byte("img"), Vector: [3]float64{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := Open(ctx, c, s.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.Index != s.Index {
+		t.Errorf("expected %+v, got %+v", s.Index, opened.Index)
+	}
+
+	b, err := c.HGet(ctx, s.Key(1), FieldVector).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 24 {
+		t.Errorf("expected a FLOAT64 vector, got %d bytes", len(b))
+	}
+}
+
+// Benchmark_Recall searches sets of random tiles indexed in different ways
+// and reports the share of the true k nearest tiles every search finds, as
+// found by brute force over the stored vectors. It needs redis stack on
+// localhost:6378.
+func Benchmark_Recall(b *testing.B) {
+	const (
+		tiles   = 5000
+		queries = 200
+		k       = 5
+	)
+
+	c := redisTestClient(b)
+	ctx := context.Background()
+
+	rnd := rand.New(rand.NewSource(1))
+	vectors := randomVectors(rnd, tiles)
+	searches := randomVectors(rnd, queries)
+
+	var tt = []struct {
+		name  string
+		index Index
+	}{
+		{"flat", Index{Algorithm: FLAT, Vector: Float32}},
+		{"hnsw", DefaultIndex()},
+		{"hnsw-float64", Index{Algorithm: HNSW, Vector: Float64}},
+		{"hnsw-m4-ef10", Index{Algorithm: HNSW, Vector: Float32, M: 4, EFConstruction: 10, EFRuntime: 10}},
+		{"hnsw-m32-ef400", Index{Algorithm: HNSW, Vector: Float32, M: 32, EFConstruction: 400, EFRuntime: 100}},
+	}
+
+	for _, tc := range tt {
+		b.Run(tc.name, func(b *testing.B) {
+			s := New(c, "tilestore-recall-"+tc.name)
+			s.Index = tc.index
+			dropSet(b, s)
+
+			if err := s.EnsureIndex(ctx); err != nil {
+				b.Fatal(err)
+			}
+
+			stored := make([][3]float64, len(vectors))
+			pipe := c.Pipeline()
+			for i, v := range vectors {
+				encoded := EncodeVector(v, s.Index.Vector)
+				stored[i], _ = DecodeVector(encoded)
+				pipe.HSet(ctx, s.Key(int64(i)), FieldVector, encoded)
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				b.Fatal(err)
+			}
+
+			exact := make([]map[string]bool, len(searches))
+			for i, v := range searches {
+				exact[i] = make(map[string]bool, k)
+				for _, id := range nearest(stored, v, k) {
+					exact[i][s.Key(int64(id))] = true
+				}
+			}
+
+			found := 0
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				q := i % len(searches)
+
+				reply, err := c.Do(ctx, s.SearchArgs(searches[q], k)...).Result()
+				if err != nil {
+					b.Fatal(err)
+				}
+				result, err := ParseSearchResult(reply, false)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				for _, doc := range result.Docs {
+					if exact[q][doc.ID] {
+						found++
+					}
+				}
+			}
+
+			b.ReportMetric(float64(found)/float64(b.N*k), "recall")
+		})
+	}
+}
+
+func randomVectors(rnd *rand.Rand, n int) [][3]float64 {
+	vectors := make([][3]float64, n)
+	for i := range vectors {
+		vectors[i] = [3]float64{rnd.Float64() * 255, rnd.Float64() * 255, rnd.Float64() * 255}
+	}
+	return vectors
+}
+
+// nearest returns the positions of the k vectors nearest to v.
+func nearest(vectors [][3]float64, v [3]float64, k int) []int {
+	distance := func(i int) float64 {
+		var d float64
+		for j := range v {
+			d += (vectors[i][j] - v[j]) * (vectors[i][j] - v[j])
+		}
+		return d
+	}
+
+	ids := make([]int, len(vectors))
+	for i := range ids {
+		ids[i] = i
+	}
+	sort.Slice(ids, func(i, j int) bool { return distance(ids[i]) < distance(ids[j]) })
+
+	return ids[:k]
+}
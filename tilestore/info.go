@@ -0,0 +1,235 @@
+package tilestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoIndex       = errors.New("tile set has no index")
+	ErrIndexMismatch = errors.New("tile set index does not match its definition")
+	ErrIndexNotReady = errors.New("tile set index is still indexing")
+)
+
+// IndexInfo is the part of a FT.INFO reply describing the index of a set.
+// The HNSW parameters of Index are the ones redis reports, defaults
+// included.
+type IndexInfo struct {
+	Prefixes       []string
+	Index          Index
+	Dim            int
+	DistanceMetric string
+
+	Docs     int64
+	Failures int64
+
+	// Indexing tells whether redis is still indexing the tiles stored before
+	// the index was created, PercentIndexed how far it got.
+	Indexing       bool
+	PercentIndexed float64
+}
+
+// Describe reads the definition and the state of the index of the set.
+// Sets without an index are reported as ErrNoIndex.
+func (s *Set) Describe(ctx context.Context) (*IndexInfo, error) {
+	reply, err := s.Client.Do(ctx, "FT.INFO", s.Name).Result()
+	if err != nil {
+		if isUnknownIndex(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNoIndex, s.Name)
+		}
+		return nil, err
+	}
+
+	return ParseIndexInfo(reply)
+}
+
+// CheckIndex describes the index of the set and reports ErrIndexMismatch
+// when it does not cover the tiles of the set the way Index defines. HNSW
+// parameters left to the defaults of redis are not compared.
+func (s *Set) CheckIndex(ctx context.Context) (*IndexInfo, error) {
+	info, err := s.Describe(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.compare(info); err != nil {
+		return info, fmt.Errorf("%w: %s: %v", ErrIndexMismatch, s.Name, err)
+	}
+
+	return info, nil
+}
+
+func (s *Set) compare(info *IndexInfo) error {
+	if len(info.Prefixes) != 1 || info.Prefixes[0] != s.indexPrefix() {
+		return fmt.Errorf("prefixes %v, expected %s", info.Prefixes, s.indexPrefix())
+	}
+	if info.Dim != 3 {
+		return fmt.Errorf("dim %d, expected 3", info.Dim)
+	}
+	if !strings.EqualFold(info.DistanceMetric, "L2") {
+		return fmt.Errorf("distance metric %s, expected L2", info.DistanceMetric)
+	}
+
+	actual, expected := info.Index, s.Index
+	if !strings.EqualFold(string(actual.Algorithm), string(expected.Algorithm)) {
+		return fmt.Errorf("algorithm %s, expected %s", actual.Algorithm, expected.Algorithm)
+	}
+	if !strings.EqualFold(string(actual.Vector), string(expected.Vector)) {
+		return fmt.Errorf("vector type %s, expected %s", actual.Vector, expected.Vector)
+	}
+
+	params := []struct {
+		name             string
+		actual, expected int
+	}{
+		{"M", actual.M, expected.M},
+		{"EF_CONSTRUCTION", actual.EFConstruction, expected.EFConstruction},
+		{"EF_RUNTIME", actual.EFRuntime, expected.EFRuntime},
+	}
+	for _, p := range params {
+		// zero on either side is the default of redis or not reported
+		if p.actual != 0 && p.expected != 0 && p.actual != p.expected {
+			return fmt.Errorf("%s %d, expected %d", p.name, p.actual, p.expected)
+		}
+	}
+
+	return nil
+}
+
+// WaitReady checks the index of the set and polls it every interval until
+// redis has indexed every tile stored before the index was created. It gives
+// up with ErrIndexNotReady once ctx is done.
+func (s *Set) WaitReady(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		info, err := s.CheckIndex(ctx)
+		if err != nil {
+			return err
+		}
+		if !info.Indexing {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %s at %.0f%%", ErrIndexNotReady, s.Name, info.PercentIndexed*100)
+		case <-ticker.C:
+		}
+	}
+}
+
+// DropIndex drops the index of the set, keeping its tiles. Dropping an index
+// that does not exist is not an error.
+func (s *Set) DropIndex(ctx context.Context) error {
+	err := s.Client.Do(ctx, "FT.DROPINDEX", s.Name).Err()
+	if err != nil && !isUnknownIndex(err) {
+		return err
+	}
+
+	return nil
+}
+
+func isUnknownIndex(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "unknown index name") || strings.Contains(msg, "no such index")
+}
+
+// ParseIndexInfo decodes a FT.INFO reply as returned by go-redis, either a
+// RESP3 map or a RESP2 array of alternating keys and values. Replies without
+// a vector attribute are reported as ErrUnexpectedReply.
+func ParseIndexInfo(reply interface{}) (*IndexInfo, error) {
+	fields, ok := replyMap(reply)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrUnexpectedReply, reply)
+	}
+
+	info := &IndexInfo{
+		Docs:           int64(infoNumber(fields["num_docs"])),
+		Failures:       int64(infoNumber(fields["hash_indexing_failures"])),
+		Indexing:       infoNumber(fields["indexing"]) != 0,
+		PercentIndexed: infoNumber(fields["percent_indexed"]),
+	}
+
+	if definition, ok := replyMap(fields["index_definition"]); ok {
+		prefixes, _ := definition["prefixes"].([]interface{})
+		for _, p := range prefixes {
+			info.Prefixes = append(info.Prefixes, replyString(p))
+		}
+	}
+
+	attributes, _ := fields["attributes"].([]interface{})
+	for _, a := range attributes {
+		attr, ok := replyMap(a)
+		if !ok || !strings.EqualFold(replyString(attr["type"]), "VECTOR") {
+			continue
+		}
+
+		info.Index = Index{
+			Algorithm:      Algorithm(strings.ToUpper(replyString(attr["algorithm"]))),
+			Vector:         VectorType(strings.ToUpper(replyString(attr["data_type"]))),
+			M:              int(infoNumber(attr["M"])),
+			EFConstruction: int(infoNumber(attr["ef_construction"])),
+			EFRuntime:      int(infoNumber(attr["ef_runtime"])),
+		}
+		info.Dim = int(infoNumber(attr["dim"]))
+		info.DistanceMetric = replyString(attr["distance_metric"])
+
+		return info, nil
+	}
+
+	return nil, fmt.Errorf("%w: no vector attribute", ErrUnexpectedReply)
+}
+
+// replyMap reads a RESP3 map or a RESP2 array of alternating keys and
+// values.
+func replyMap(v interface{}) (map[string]interface{}, bool) {
+	switch r := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(r))
+		for k, v := range r {
+			m[replyString(k)] = v
+		}
+		return m, true
+	case []interface{}:
+		if len(r)%2 != 0 {
+			return nil, false
+		}
+		m := make(map[string]interface{}, len(r)/2)
+		for i := 0; i < len(r); i += 2 {
+			k, ok := r[i].(string)
+			if !ok {
+				return nil, false
+			}
+			m[k] = r[i+1]
+		}
+		return m, true
+	default:
+		return nil, false
+	}
+}
+
+func replyString(v interface{}) string {
+	switch r := v.(type) {
+	case string:
+		return r
+	case []byte:
+		return string(r)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(r)
+	}
+}
+
+// infoNumber reads the numbers of FT.INFO, sent as integers, doubles or
+// strings depending on the protocol and the version of redis. Missing or
+// malformed ones read as zero.
+func infoNumber(v interface{}) float64 {
+	f, _ := replyFloat(v)
+	return f
+}
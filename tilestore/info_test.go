@@ -0,0 +1,198 @@
+package tilestore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// FT.INFO replies of a FLOAT32 HNSW index as decoded by go-redis, cut down to
+// the fields read
+var (
+	recordedInfoRESP3 = map[interface{}]interface{}{
+		"index_name": "0.0.0.0",
+		"index_definition": map[interface{}]interface{}{
+			"key_type":      "HASH",
+			"prefixes":      []interface{}{"img:0.0.0.0:"},
+			"default_score": float64(1),
+		},
+		"attributes": []interface{}{
+			map[interface{}]interface{}{
+				"identifier":      "average_color",
+				"attribute":       "average_color",
+				"type":            "VECTOR",
+				"algorithm":       "HNSW",
+				"data_type":       "FLOAT32",
+				"dim":             int64(3),
+				"distance_metric": "L2",
+				"M":               int64(16),
+				"ef_construction": int64(200),
+			},
+		},
+		"num_docs":               float64(120),
+		"hash_indexing_failures": float64(0),
+		"indexing":               float64(1),
+		"percent_indexed":        float64(0.5),
+	}
+
+	recordedInfoRESP2 = []interface{}{
+		"index_name", "0.0.0.0",
+		"index_definition", []interface{}{"key_type", "HASH", "prefixes", []interface{}{"img:0.0.0.0:"}, "default_score", "1"},
+		"attributes", []interface{}{
+			[]interface{}{
+				"identifier", "average_color", "attribute", "average_color", "type", "VECTOR",
+				"algorithm", "HNSW", "data_type", "FLOAT32", "dim", int64(3), "distance_metric", "L2",
+				"M", int64(16), "ef_construction", int64(200),
+			},
+		},
+		"num_docs", "120",
+		"hash_indexing_failures", "0",
+		"indexing", "1",
+		"percent_indexed", "0.5",
+	}
+)
+
+func Test_ParseIndexInfo(t *testing.T) {
+	expected := &IndexInfo{
+		Prefixes:       []string{"img:0.0.0.0:"},
+		Index:          Index{Algorithm: HNSW, Vector: Float32, M: 16, EFConstruction: 200},
+		Dim:            3,
+		DistanceMetric: "L2",
+		Docs:           120,
+		Indexing:       true,
+		PercentIndexed: 0.5,
+	}
+
+	var tt = []struct {
+		name  string
+		reply interface{}
+	}{
+		{"resp3", recordedInfoRESP3},
+		{"resp2", recordedInfoRESP2},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := ParseIndexInfo(tc.reply)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(expected, info) {
+				t.Errorf("expected %+v, got %+v", expected, info)
+			}
+		})
+	}
+
+	if _, err := ParseIndexInfo(map[interface{}]interface{}{"attributes": []interface{}{}}); !errors.Is(err, ErrUnexpectedReply) {
+		t.Errorf("expected %v, got %v", ErrUnexpectedReply, err)
+	}
+}
+
+func Test_CompareIndex(t *testing.T) {
+	info := func(modify func(*IndexInfo)) *IndexInfo {
+		i, err := ParseIndexInfo(recordedInfoRESP3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if modify != nil {
+			modify(i)
+		}
+		return i
+	}
+
+	var tt = []struct {
+		name  string
+		index Index
+		info  *IndexInfo
+		match bool
+	}{
+		{"default", DefaultIndex(), info(nil), true},
+		{"tuned alike", Index{Algorithm: HNSW, Vector: Float32, M: 16}, info(nil), true},
+		{"other M", Index{Algorithm: HNSW, Vector: Float32, M: 32}, info(nil), false},
+		{"other vector", Index{Algorithm: HNSW, Vector: Float64}, info(nil), false},
+		{"other algorithm", Index{Algorithm: FLAT, Vector: Float32}, info(nil), false},
+		{"other prefix", DefaultIndex(), info(func(i *IndexInfo) { i.Prefixes = []string{"img:"} }), false},
+		{"unseparated prefix", DefaultIndex(), info(func(i *IndexInfo) { i.Prefixes = []string{"img:0.0.0.0"} }), false},
+		{"other dim", DefaultIndex(), info(func(i *IndexInfo) { i.Dim = 4 }), false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := New(nil, "0.0.0.0")
+			s.Index = tc.index
+
+			err := s.compare(tc.info)
+			if tc.match && err != nil {
+				t.Errorf("expected a match, got %v", err)
+			}
+			if !tc.match && err == nil {
+				t.Errorf("expected a mismatch")
+			}
+		})
+	}
+}
+
+// Test_EnsureIndex needs redis stack on localhost:6378.
+func Test_EnsureIndex(t *testing.T) {
+	c := redisTestClient(t)
+	ctx := context.Background()
+
+	s := New(c, "tilestore-ensure-test")
+	dropSet(t, s)
+
+	if _, err := s.Describe(ctx); !errors.Is(err, ErrNoIndex) {
+		t.Fatalf("expected %v, got %v", ErrNoIndex, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.EnsureIndex(ctx); err != nil {
+			t.Fatalf("ensure %d: %v", i, err)
+		}
+	}
+	if _, err := s.Add(ctx, Tile{Image: []byte("img"), Vector: [3]float64{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a dropped index is recreated over the stored tiles
+	for i := 0; i < 2; i++ {
+		if err := s.DropIndex(ctx); err != nil {
+			t.Fatalf("drop %d: %v", i, err)
+		}
+	}
+	if err := s.EnsureIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := s.WaitReady(waitCtx, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := s.Describe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Docs != 1 {
+		t.Errorf("expected 1 tile indexed, got %d", info.Docs)
+	}
+
+	// an index recreated by hand with another vector type
+	if err = s.DropIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	other := *s
+	other.Index.Vector = Float64
+	if err = other.createIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = New(c, s.Name).EnsureIndex(ctx); !errors.Is(err, ErrIndexMismatch) {
+		t.Errorf("expected %v, got %v", ErrIndexMismatch, err)
+	}
+	if err = s.WaitReady(ctx, time.Millisecond); !errors.Is(err, ErrIndexMismatch) {
+		t.Errorf("expected %v, got %v", ErrIndexMismatch, err)
+	}
+}
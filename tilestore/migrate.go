@@ -0,0 +1,200 @@
+package tilestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// migrations upgrade a set from the version they are keyed by to the next
+// one. They run with the index dropped and must be safe to run again on a
+// set they were interrupted on.
+var migrations = map[int]func(ctx context.Context, s *Set, batchSize int) error{
+	1: migrateFloat64ToFloat32,
+	2: migrateImagesToBlobs,
+	3: migrateLinearDescriptors,
+	4: migrateKinds,
+	5: migrateIndexPrefix,
+}
+
+// Migrate upgrades the set named name to SchemaVersion in place and returns
+// the version it was at. Its index is dropped while the tiles are rewritten
+// and recreated afterwards, searches fail in between.
+func Migrate(ctx context.Context, c *redis.Client, name string, batchSize int) (int, error) {
+	s, err := Open(ctx, c, name)
+	if err != nil {
+		return 0, err
+	}
+
+	from := s.Schema.Version
+	if from == SchemaVersion {
+		return from, nil
+	}
+	if from > SchemaVersion {
+		return from, fmt.Errorf("%w: %d", ErrUnknownSchema, from)
+	}
+
+	if err = s.DropIndex(ctx); err != nil {
+		return from, err
+	}
+
+	for v := from; v < SchemaVersion; v++ {
+		migrate, ok := migrations[v]
+		if !ok {
+			return from, fmt.Errorf("%w: no migration from version %d", ErrUnknownSchema, v)
+		}
+
+		if err = migrate(ctx, s, max(batchSize, 1)); err != nil {
+			return from, fmt.Errorf("migrating %s from version %d: %w", name, v, err)
+		}
+
+		s.Schema = schemas[v+1]
+		if err = s.saveMeta(ctx); err != nil {
+			return from, err
+		}
+	}
+
+	return from, s.createIndex(ctx)
+}
+
+// List returns the names of the sets with an index.
+func List(ctx context.Context, c *redis.Client) ([]string, error) {
+	return c.Do(ctx, "FT._LIST").StringSlice()
+}
+
+// migrateFloat64ToFloat32 halves the size of the vectors. Vectors already
+// converted are recognized by their length and left alone.
+func migrateFloat64ToFloat32(ctx context.Context, s *Set, batchSize int) error {
+	s.Index.Vector = Float32
+
+	return s.scanBatches(ctx, batchSize, func(keys []string) error {
+		pipe := s.Client.Pipeline()
+		cmds := make([]*redis.StringCmd, len(keys))
+		for i, key := range keys {
+			cmds[i] = pipe.HGet(ctx, key, FieldVector)
+		}
+		if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+
+		pipe = s.Client.Pipeline()
+		for i, cmd := range cmds {
+			b, err := cmd.Bytes()
+			if errors.Is(err, redis.Nil) || len(b) == Float32.size()*3 {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			v, err := DecodeVector(b)
+			if err != nil {
+				return fmt.Errorf("%s: %w", keys[i], err)
+			}
+			pipe.HSet(ctx, keys[i], FieldVector, EncodeVector(v, Float32))
+		}
+
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+}
+
+// migrateImagesToBlobs moves the base64 encoded images out of the tile
+// hashes into decoded blobs. Tiles without an image field were moved
+// already.
+func migrateImagesToBlobs(ctx context.Context, s *Set, batchSize int) error {
+	return s.scanBatches(ctx, batchSize, func(keys []string) error {
+		imgs, err := s.hashImages(ctx, keys)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, img := range imgs {
+				if img == nil {
+					continue
+				}
+				pipe.Set(ctx, BlobKey(keys[i]), img, 0)
+				pipe.HDel(ctx, keys[i], FieldImage)
+			}
+			return nil
+		})
+		return err
+	})
+}
+
+// migrateLinearDescriptors recomputes the descriptors of the tiles from
+// their images, averaging them in linear light. Tiles without an image are
+// left alone.
+func migrateLinearDescriptors(ctx context.Context, s *Set, batchSize int) error {
+	return s.scanBatches(ctx, batchSize, func(keys []string) error {
+		imgs, err := s.Images(ctx, keys)
+		if err != nil {
+			return err
+		}
+
+		pipe := s.Client.Pipeline()
+		for i, img := range imgs {
+			if img == nil {
+				continue
+			}
+
+			v, err := DecodeDescriptor(img)
+			if err != nil {
+				return fmt.Errorf("%s: %w", keys[i], err)
+			}
+			pipe.HSet(ctx, keys[i], FieldVector, EncodeVector(v, s.Index.Vector))
+		}
+
+		_, err = pipe.Exec(ctx)
+		return err
+	})
+}
+
+// migrateKinds records the kind of the tiles, all downloaded as sets had no
+// variants before.
+func migrateKinds(ctx context.Context, s *Set, batchSize int) error {
+	return s.scanBatches(ctx, batchSize, func(keys []string) error {
+		pipe := s.Client.Pipeline()
+		for _, key := range keys {
+			pipe.HSetNX(ctx, key, FieldKind, KindTile)
+		}
+
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+}
+
+// migrateIndexPrefix leaves the tiles alone, the index Migrate recreates
+// covers the prefix ending with the separator.
+func migrateIndexPrefix(context.Context, *Set, int) error {
+	return nil
+}
+
+// scanBatches calls f with the keys of the tiles of the set, at most
+// batchSize at a time.
+func (s *Set) scanBatches(ctx context.Context, batchSize int, f func(keys []string) error) error {
+	iter := s.Client.Scan(ctx, 0, s.Pattern(), int64(batchSize)).Iterator()
+
+	keys := make([]string, 0, batchSize)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == batchSize {
+			if err := f(keys); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	if len(keys) > 0 {
+		return f(keys)
+	}
+
+	return nil
+}
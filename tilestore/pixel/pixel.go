@@ -0,0 +1,103 @@
+// Package pixel reads rows of pixels straight from the Pix slices of the
+// common image types, without going through image.Image.At and the
+// color.Color interface for every pixel.
+package pixel
+
+import "image"
+
+// Row reads the pixels of img from (xMin, y) to (xMax, y) into dst as
+// alpha-premultiplied 16 bit red, green, blue and alpha, the values
+// img.At(x, y).RGBA() returns. dst must hold 4 values per pixel. *image.NRGBA,
+// *image.RGBA, *image.YCbCr and *image.Gray are read from their Pix slices,
+// other types through At.
+func Row(img image.Image, y, xMin, xMax int, dst []uint16) {
+	switch src := img.(type) {
+	case *image.NRGBA:
+		nrgbaRow(src, y, xMin, xMax, dst)
+	case *image.RGBA:
+		rgbaRow(src, y, xMin, xMax, dst)
+	case *image.YCbCr:
+		ycbcrRow(src, y, xMin, xMax, dst)
+	case *image.Gray:
+		grayRow(src, y, xMin, xMax, dst)
+	default:
+		for x, i := xMin, 0; x < xMax; x, i = x+1, i+4 {
+			r, g, b, a := img.At(x, y).RGBA()
+			dst[i], dst[i+1], dst[i+2], dst[i+3] = uint16(r), uint16(g), uint16(b), uint16(a)
+		}
+	}
+}
+
+func nrgbaRow(src *image.NRGBA, y, xMin, xMax int, dst []uint16) {
+	pix := src.Pix[src.PixOffset(xMin, y):]
+	for i := 0; i < (xMax-xMin)*4; i += 4 {
+		a := uint32(pix[i+3])
+		if a == 0xff {
+			dst[i] = uint16(pix[i]) * 0x101
+			dst[i+1] = uint16(pix[i+1]) * 0x101
+			dst[i+2] = uint16(pix[i+2]) * 0x101
+			dst[i+3] = 0xffff
+			continue
+		}
+
+		// as color.NRGBA.RGBA premultiplies
+		dst[i] = uint16(uint32(pix[i]) * 0x101 * a / 0xff)
+		dst[i+1] = uint16(uint32(pix[i+1]) * 0x101 * a / 0xff)
+		dst[i+2] = uint16(uint32(pix[i+2]) * 0x101 * a / 0xff)
+		dst[i+3] = uint16(a * 0x101)
+	}
+}
+
+func rgbaRow(src *image.RGBA, y, xMin, xMax int, dst []uint16) {
+	pix := src.Pix[src.PixOffset(xMin, y):]
+	for i := 0; i < (xMax-xMin)*4; i++ {
+		dst[i] = uint16(pix[i]) * 0x101
+	}
+}
+
+func grayRow(src *image.Gray, y, xMin, xMax int, dst []uint16) {
+	pix := src.Pix[src.PixOffset(xMin, y):]
+	for i, v := range pix[:xMax-xMin] {
+		g := uint16(v) * 0x101
+		dst[i*4], dst[i*4+1], dst[i*4+2], dst[i*4+3] = g, g, g, 0xffff
+	}
+}
+
+func ycbcrRow(src *image.YCbCr, y, xMin, xMax int, dst []uint16) {
+	// chroma samples are shared by d pixels of a row
+	d := 1
+	switch src.SubsampleRatio {
+	case image.YCbCrSubsampleRatio422, image.YCbCrSubsampleRatio420:
+		d = 2
+	case image.YCbCrSubsampleRatio411, image.YCbCrSubsampleRatio410:
+		d = 4
+	}
+
+	yRow := src.Y[src.YOffset(xMin, y):]
+	cRow := src.COffset(src.Rect.Min.X, y) - src.Rect.Min.X/d
+	for x, i := xMin, 0; x < xMax; x, i = x+1, i+4 {
+		ci := cRow + x/d
+		dst[i], dst[i+1], dst[i+2] = ycbcrToRGB(yRow[x-xMin], src.Cb[ci], src.Cr[ci])
+		dst[i+3] = 0xffff
+	}
+}
+
+// ycbcrToRGB is color.YCbCr.RGBA without the method call per pixel.
+func ycbcrToRGB(y, cb, cr uint8) (uint16, uint16, uint16) {
+	yy1 := int32(y) * 0x10101
+	cb1 := int32(cb) - 128
+	cr1 := int32(cr) - 128
+
+	r := clamp16(yy1 + 91881*cr1)
+	g := clamp16(yy1 - 22554*cb1 - 46802*cr1)
+	b := clamp16(yy1 + 116130*cb1)
+
+	return r, g, b
+}
+
+func clamp16(v int32) uint16 {
+	if uint32(v)&0xff000000 == 0 {
+		return uint16(v >> 8)
+	}
+	return uint16(^(v >> 31) & 0xffff)
+}
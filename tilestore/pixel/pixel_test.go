@@ -0,0 +1,125 @@
+package pixel
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func Test_Row(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	r := image.Rect(-3, 5, 29, 22)
+
+	var tt = []struct {
+		name string
+		img  image.Image
+	}{
+		{"nrgba", randomImage(rnd, image.NewNRGBA(r))},
+		{"rgba", randomImage(rnd, image.NewRGBA(r))},
+		{"gray", randomImage(rnd, image.NewGray(r))},
+		{"nrgba64", randomImage(rnd, image.NewNRGBA64(r))},
+		{"sub nrgba", randomImage(rnd, image.NewNRGBA(r)).(*image.NRGBA).SubImage(image.Rect(2, 7, 11, 13))},
+	}
+	for _, ratio := range []image.YCbCrSubsampleRatio{
+		image.YCbCrSubsampleRatio444, image.YCbCrSubsampleRatio422, image.YCbCrSubsampleRatio420,
+		image.YCbCrSubsampleRatio440, image.YCbCrSubsampleRatio411, image.YCbCrSubsampleRatio410,
+	} {
+		tt = append(tt, struct {
+			name string
+			img  image.Image
+		}{"ycbcr " + ratio.String(), randomYCbCr(rnd, r, ratio)})
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			b := tc.img.Bounds()
+			row := make([]uint16, b.Dx()*4)
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				Row(tc.img, y, b.Min.X, b.Max.X, row)
+				for x := b.Min.X; x < b.Max.X; x++ {
+					r, g, bl, a := tc.img.At(x, y).RGBA()
+					expected := [4]uint16{uint16(r), uint16(g), uint16(bl), uint16(a)}
+					i := (x - b.Min.X) * 4
+					if got := [4]uint16(row[i : i+4]); got != expected {
+						t.Fatalf("pixel %d,%d: expected %v, got %v", x, y, expected, got)
+					}
+				}
+			}
+
+			// a part of a row
+			Row(tc.img, b.Min.Y, b.Min.X, b.Max.X, row)
+			part := make([]uint16, 8)
+			Row(tc.img, b.Min.Y, b.Min.X+1, b.Min.X+3, part)
+			if [8]uint16(part) != [8]uint16(row[4:12]) {
+				t.Errorf("expected %v, got %v", row[4:12], part)
+			}
+		})
+	}
+}
+
+func Benchmark_Row(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	r := image.Rect(0, 0, 700, 700)
+
+	var tt = []struct {
+		name string
+		img  image.Image
+	}{
+		{"nrgba", randomImage(rnd, image.NewNRGBA(r))},
+		{"rgba", randomImage(rnd, image.NewRGBA(r))},
+		{"gray", randomImage(rnd, image.NewGray(r))},
+		{"ycbcr", randomYCbCr(rnd, r, image.YCbCrSubsampleRatio420)},
+	}
+
+	for _, tc := range tt {
+		row := make([]uint16, r.Dx()*4)
+
+		b.Run(tc.name+"/at", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for y := r.Min.Y; y < r.Max.Y; y++ {
+					for x := r.Min.X; x < r.Max.X; x++ {
+						cr, cg, cb, ca := tc.img.At(x, y).RGBA()
+						j := x * 4
+						row[j], row[j+1], row[j+2], row[j+3] = uint16(cr), uint16(cg), uint16(cb), uint16(ca)
+					}
+				}
+			}
+		})
+
+		b.Run(tc.name+"/row", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for y := r.Min.Y; y < r.Max.Y; y++ {
+					Row(tc.img, y, r.Min.X, r.Max.X, row)
+				}
+			}
+		})
+	}
+}
+
+// randomImage fills img with random pixels, translucent ones included.
+func randomImage(rnd *rand.Rand, img interface {
+	image.Image
+	Set(x, y int, c color.Color)
+}) image.Image {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			img.Set(x, y, color.NRGBA{
+				R: uint8(rnd.Intn(256)),
+				G: uint8(rnd.Intn(256)),
+				B: uint8(rnd.Intn(256)),
+				A: uint8(rnd.Intn(256)),
+			})
+		}
+	}
+	return img
+}
+
+func randomYCbCr(rnd *rand.Rand, r image.Rectangle, ratio image.YCbCrSubsampleRatio) *image.YCbCr {
+	img := image.NewYCbCr(r, ratio)
+	for _, p := range [][]uint8{img.Y, img.Cb, img.Cr} {
+		rnd.Read(p)
+	}
+	return img
+}
@@ -0,0 +1,229 @@
+package tilestore
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var ErrUnexpectedReply = errors.New("unexpected FT.SEARCH reply")
+
+// SearchArgs builds the FT.SEARCH command of the k tiles nearest to v,
+// nearest first, returning the fields listed or only the keys of the tiles
+// when there are none. Variants are left out when the set excludes them.
+func (s *Set) SearchArgs(v [3]float64, k int, fields ...string) []interface{} {
+	filter := "*"
+	if s.ExcludeVariants && s.Schema.Variants {
+		filter = fmt.Sprintf("@%s:{%s}", FieldKind, KindTile)
+	}
+
+	args := []interface{}{
+		"FT.SEARCH", s.Name,
+		fmt.Sprintf("(%s)=>[KNN %d @%s $vec]", filter, k, FieldVector),
+		"PARAMS", "2", "vec", EncodeVector(v, s.Index.Vector),
+		"SORTBY", FieldScore,
+	}
+
+	if len(fields) == 0 {
+		args = append(args, "NOCONTENT")
+	} else {
+		args = append(args, "RETURN", strconv.Itoa(len(fields)))
+		for _, field := range fields {
+			args = append(args, field)
+		}
+	}
+
+	return append(args, "DIALECT", "2")
+}
+
+// SearchResult is a decoded FT.SEARCH reply.
+type SearchResult struct {
+	Total int64
+	Docs  []SearchDoc
+}
+
+// SearchDoc is a document of a FT.SEARCH reply. Score is only set for
+// queries sent WITHSCORES and Fields is empty for NOCONTENT ones.
+type SearchDoc struct {
+	ID     string
+	Score  float64
+	Fields map[string]string
+}
+
+// ParseSearchResult decodes a FT.SEARCH reply as returned by go-redis, either
+// a RESP3 map or a RESP2 array. RESP2 replies do not say whether they carry
+// scores, withScores must tell how the query was sent. Malformed replies are
+// reported as ErrUnexpectedReply.
+func ParseSearchResult(reply interface{}, withScores bool) (*SearchResult, error) {
+	switch r := reply.(type) {
+	case map[interface{}]interface{}:
+		return parseSearchResultRESP3(r)
+	case []interface{}:
+		return parseSearchResultRESP2(r, withScores)
+	case error:
+		return nil, r
+	default:
+		return nil, fmt.Errorf("%w: reply of type %T", ErrUnexpectedReply, reply)
+	}
+}
+
+// parseSearchResultRESP3 decodes
+//
+//	{total_results: n, results: [{id: .., score: .., extra_attributes: {..}}, ..], ..}
+func parseSearchResultRESP3(reply map[interface{}]interface{}) (*SearchResult, error) {
+	total, err := replyInt(reply["total_results"])
+	if err != nil {
+		return nil, fmt.Errorf("total_results: %w", err)
+	}
+
+	results, ok := reply["results"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: results of type %T", ErrUnexpectedReply, reply["results"])
+	}
+
+	sr := &SearchResult{Total: total, Docs: make([]SearchDoc, 0, len(results))}
+	for i, r := range results {
+		result, ok := r.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: result %d of type %T", ErrUnexpectedReply, i, r)
+		}
+
+		var doc SearchDoc
+		if doc.ID, ok = result["id"].(string); !ok {
+			return nil, fmt.Errorf("%w: result %d without id", ErrUnexpectedReply, i)
+		}
+
+		if score, ok := result["score"]; ok {
+			if doc.Score, err = replyFloat(score); err != nil {
+				return nil, fmt.Errorf("%s score: %w", doc.ID, err)
+			}
+		}
+
+		doc.Fields = make(map[string]string)
+		if attributes, ok := result["extra_attributes"]; ok {
+			attributesMap, ok := attributes.(map[interface{}]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%w: %s attributes of type %T", ErrUnexpectedReply, doc.ID, attributes)
+			}
+			for k, v := range attributesMap {
+				if err = setField(doc.Fields, k, v); err != nil {
+					return nil, fmt.Errorf("%s: %w", doc.ID, err)
+				}
+			}
+		}
+
+		sr.Docs = append(sr.Docs, doc)
+	}
+
+	return sr, nil
+}
+
+// parseSearchResultRESP2 decodes
+//
+//	[n, id, (score,) [field, value, ..], id, ..]
+//
+// where the field lists are missing for NOCONTENT queries.
+func parseSearchResultRESP2(reply []interface{}, withScores bool) (*SearchResult, error) {
+	if len(reply) == 0 {
+		return nil, fmt.Errorf("%w: empty reply", ErrUnexpectedReply)
+	}
+
+	total, err := replyInt(reply[0])
+	if err != nil {
+		return nil, fmt.Errorf("total: %w", err)
+	}
+
+	sr := &SearchResult{Total: total, Docs: make([]SearchDoc, 0)}
+	for i := 1; i < len(reply); {
+		var doc SearchDoc
+		var ok bool
+		if doc.ID, ok = reply[i].(string); !ok {
+			return nil, fmt.Errorf("%w: element %d of type %T instead of an id", ErrUnexpectedReply, i, reply[i])
+		}
+		i++
+
+		if withScores {
+			if i == len(reply) {
+				return nil, fmt.Errorf("%w: %s without score", ErrUnexpectedReply, doc.ID)
+			}
+			if doc.Score, err = replyFloat(reply[i]); err != nil {
+				return nil, fmt.Errorf("%s score: %w", doc.ID, err)
+			}
+			i++
+		}
+
+		doc.Fields = make(map[string]string)
+		if i < len(reply) {
+			if fields, ok := reply[i].([]interface{}); ok {
+				if len(fields)%2 != 0 {
+					return nil, fmt.Errorf("%w: %s has an odd number of field elements", ErrUnexpectedReply, doc.ID)
+				}
+				for j := 0; j < len(fields); j += 2 {
+					if err = setField(doc.Fields, fields[j], fields[j+1]); err != nil {
+						return nil, fmt.Errorf("%s: %w", doc.ID, err)
+					}
+				}
+				i++
+			}
+		}
+
+		sr.Docs = append(sr.Docs, doc)
+	}
+
+	return sr, nil
+}
+
+func setField(fields map[string]string, k, v interface{}) error {
+	key, ok := k.(string)
+	if !ok {
+		return fmt.Errorf("%w: field name of type %T", ErrUnexpectedReply, k)
+	}
+
+	switch value := v.(type) {
+	case string:
+		fields[key] = value
+	case []byte:
+		fields[key] = string(value)
+	case int64:
+		fields[key] = strconv.FormatInt(value, 10)
+	case float64:
+		fields[key] = strconv.FormatFloat(value, 'g', -1, 64)
+	case nil:
+	default:
+		return fmt.Errorf("%w: field %s of type %T", ErrUnexpectedReply, key, v)
+	}
+
+	return nil
+}
+
+func replyInt(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case int64:
+		return n, nil
+	case string:
+		i, err := strconv.ParseInt(n, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrUnexpectedReply, err)
+		}
+		return i, nil
+	default:
+		return 0, fmt.Errorf("%w: integer of type %T", ErrUnexpectedReply, v)
+	}
+}
+
+func replyFloat(v interface{}) (float64, error) {
+	switch f := v.(type) {
+	case float64:
+		return f, nil
+	case int64:
+		return float64(f), nil
+	case string:
+		x, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrUnexpectedReply, err)
+		}
+		return x, nil
+	default:
+		return 0, fmt.Errorf("%w: number of type %T", ErrUnexpectedReply, v)
+	}
+}
@@ -0,0 +1,177 @@
+package tilestore
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// FT.SEARCH replies of the tile index as decoded by go-redis, with the img
+// field cut short
+var (
+	recordedVector = EncodeVector([3]float64{148.91, 153.07, 147.55}, Float64)
+
+	recordedRESP3 = map[interface{}]interface{}{
+		"attributes": []interface{}{},
+		"format":     "STRING",
+		"results": []interface{}{
+			map[interface{}]interface{}{
+				"id": "img:0.0.0.0:1",
+				"extra_attributes": map[interface{}]interface{}{
+					"average_color": string(recordedVector),
+					"img":           "/9j/2wCEAAgGBgcGBQgHBw",
+				},
+				"values": []interface{}{},
+			},
+			map[interface{}]interface{}{
+				"id": "img:0.0.0.0:2",
+				"extra_attributes": map[interface{}]interface{}{
+					"average_color": string(recordedVector),
+					"img":           "/9j/2wCEcGBQgHBwAAgGBgcGBQgHBw",
+				},
+				"values": []interface{}{},
+			},
+		},
+		"total_results": int64(2),
+		"warning":       []interface{}{},
+	}
+
+	recordedRESP3Empty = map[interface{}]interface{}{
+		"attributes":    []interface{}{},
+		"format":        "STRING",
+		"results":       []interface{}{},
+		"total_results": int64(0),
+		"warning":       []interface{}{},
+	}
+
+	recordedRESP3NoContent = map[interface{}]interface{}{
+		"attributes": []interface{}{},
+		"format":     "STRING",
+		"results": []interface{}{
+			map[interface{}]interface{}{"id": "img:0.0.0.0:1", "values": []interface{}{}},
+		},
+		"total_results": int64(1),
+		"warning":       []interface{}{},
+	}
+
+	recordedRESP3WithScores = map[interface{}]interface{}{
+		"attributes": []interface{}{},
+		"format":     "STRING",
+		"results": []interface{}{
+			map[interface{}]interface{}{
+				"id":               "img:0.0.0.0:1",
+				"score":            float64(1),
+				"extra_attributes": map[interface{}]interface{}{"img": "/9j/2wCEAAgGBgcGBQgHBw"},
+				"values":           []interface{}{},
+			},
+		},
+		"total_results": int64(1),
+		"warning":       []interface{}{},
+	}
+
+	recordedRESP2 = []interface{}{
+		int64(2),
+		"img:0.0.0.0:1",
+		[]interface{}{"average_color", string(recordedVector), "img", "/9j/2wCEAAgGBgcGBQgHBw"},
+		"img:0.0.0.0:2",
+		[]interface{}{"average_color", string(recordedVector), "img", "/9j/2wCEcGBQgHBwAAgGBgcGBQgHBw"},
+	}
+
+	recordedRESP2NoContent = []interface{}{int64(2), "img:0.0.0.0:1", "img:0.0.0.0:2"}
+
+	recordedRESP2WithScores = []interface{}{
+		int64(1),
+		"img:0.0.0.0:1",
+		"1",
+		[]interface{}{"img", "/9j/2wCEAAgGBgcGBQgHBw"},
+	}
+)
+
+func Test_ParseSearchResult(t *testing.T) {
+	twoDocs := &SearchResult{
+		Total: 2,
+		Docs: []SearchDoc{
+			{ID: "img:0.0.0.0:1", Fields: map[string]string{"average_color": string(recordedVector), "img": "/9j/2wCEAAgGBgcGBQgHBw"}},
+			{ID: "img:0.0.0.0:2", Fields: map[string]string{"average_color": string(recordedVector), "img": "/9j/2wCEcGBQgHBwAAgGBgcGBQgHBw"}},
+		},
+	}
+
+	var tt = []struct {
+		name       string
+		reply      interface{}
+		withScores bool
+		expected   *SearchResult
+	}{
+		{"resp3", recordedRESP3, false, twoDocs},
+		{"resp3 empty", recordedRESP3Empty, false, &SearchResult{Docs: []SearchDoc{}}},
+		{"resp3 nocontent", recordedRESP3NoContent, false, &SearchResult{
+			Total: 1,
+			Docs:  []SearchDoc{{ID: "img:0.0.0.0:1", Fields: map[string]string{}}},
+		}},
+		{"resp3 withscores", recordedRESP3WithScores, true, &SearchResult{
+			Total: 1,
+			Docs:  []SearchDoc{{ID: "img:0.0.0.0:1", Score: 1, Fields: map[string]string{"img": "/9j/2wCEAAgGBgcGBQgHBw"}}},
+		}},
+		{"resp2", recordedRESP2, false, twoDocs},
+		{"resp2 empty", []interface{}{int64(0)}, false, &SearchResult{Docs: []SearchDoc{}}},
+		{"resp2 nocontent", recordedRESP2NoContent, false, &SearchResult{
+			Total: 2,
+			Docs: []SearchDoc{
+				{ID: "img:0.0.0.0:1", Fields: map[string]string{}},
+				{ID: "img:0.0.0.0:2", Fields: map[string]string{}},
+			},
+		}},
+		{"resp2 withscores", recordedRESP2WithScores, true, &SearchResult{
+			Total: 1,
+			Docs:  []SearchDoc{{ID: "img:0.0.0.0:1", Score: 1, Fields: map[string]string{"img": "/9j/2wCEAAgGBgcGBQgHBw"}}},
+		}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sr, err := ParseSearchResult(tc.reply, tc.withScores)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tc.expected, sr) {
+				t.Errorf("expected %+v, got %+v", tc.expected, sr)
+			}
+		})
+	}
+}
+
+func Test_ParseSearchResultMalformed(t *testing.T) {
+	var tt = []struct {
+		name  string
+		reply interface{}
+	}{
+		{"nil", nil},
+		{"string", "OK"},
+		{"resp3 without results", map[interface{}]interface{}{"total_results": int64(1)}},
+		{"resp3 without total", map[interface{}]interface{}{"results": []interface{}{}}},
+		{"resp3 result not a map", map[interface{}]interface{}{"total_results": int64(1), "results": []interface{}{"img:1"}}},
+		{"resp3 result without id", map[interface{}]interface{}{"total_results": int64(1), "results": []interface{}{
+			map[interface{}]interface{}{"extra_attributes": map[interface{}]interface{}{}},
+		}}},
+		{"resp3 attributes not a map", map[interface{}]interface{}{"total_results": int64(1), "results": []interface{}{
+			map[interface{}]interface{}{"id": "img:1", "extra_attributes": []interface{}{}},
+		}}},
+		{"resp3 field of unknown type", map[interface{}]interface{}{"total_results": int64(1), "results": []interface{}{
+			map[interface{}]interface{}{"id": "img:1", "extra_attributes": map[interface{}]interface{}{"img": []interface{}{}}},
+		}}},
+		{"resp2 empty", []interface{}{}},
+		{"resp2 total not a number", []interface{}{"two"}},
+		{"resp2 id not a string", []interface{}{int64(1), int64(1)}},
+		{"resp2 odd fields", []interface{}{int64(1), "img:1", []interface{}{"img"}}},
+		{"resp2 missing score", []interface{}{int64(1), "img:1"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			withScores := tc.name == "resp2 missing score"
+			if _, err := ParseSearchResult(tc.reply, withScores); !errors.Is(err, ErrUnexpectedReply) {
+				t.Errorf("expected %v, got %v", ErrUnexpectedReply, err)
+			}
+		})
+	}
+}
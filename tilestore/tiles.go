@@ -0,0 +1,121 @@
+package tilestore
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Records reads the tiles stored under keys without their images, in one
+// pipeline. Keys of tiles that do not exist are left nil.
+func (s *Set) Records(ctx context.Context, keys []string) ([]*Tile, error) {
+	pipe := s.Client.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.HMGet(ctx, key, FieldVector, FieldSource, FieldAuthor, FieldParent, FieldTransform, FieldHash)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	tiles := make([]*Tile, len(keys))
+	for i, cmd := range cmds {
+		values := cmd.Val()
+
+		vector, ok := values[0].(string)
+		if !ok {
+			continue
+		}
+
+		v, err := DecodeVector([]byte(vector))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", keys[i], err)
+		}
+
+		tile := &Tile{Vector: v}
+		tile.Source, _ = values[1].(string)
+		tile.Author, _ = values[2].(string)
+		tile.Parent, _ = values[3].(string)
+		tile.Transform, _ = values[4].(string)
+		tile.Hash, _ = values[5].(string)
+		tiles[i] = tile
+	}
+
+	return tiles, nil
+}
+
+// Image reads the encoded image of the tile stored under key.
+func (s *Set) Image(ctx context.Context, key string) ([]byte, error) {
+	imgs, err := s.Images(ctx, []string{key})
+	if err != nil {
+		return nil, err
+	}
+	if imgs[0] == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoTile, key)
+	}
+
+	return imgs[0], nil
+}
+
+// Images reads the encoded images of the tiles stored under keys in one
+// pipeline. Keys of tiles that do not exist are left nil.
+func (s *Set) Images(ctx context.Context, keys []string) ([][]byte, error) {
+	if !s.Schema.Blobs {
+		return s.hashImages(ctx, keys)
+	}
+
+	pipe := s.Client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Get(ctx, BlobKey(key))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	imgs := make([][]byte, len(keys))
+	for i, cmd := range cmds {
+		img, err := cmd.Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		imgs[i] = img
+	}
+
+	return imgs, nil
+}
+
+// hashImages reads the base64 encoded images of sets that predate blobs.
+func (s *Set) hashImages(ctx context.Context, keys []string) ([][]byte, error) {
+	pipe := s.Client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.HGet(ctx, key, FieldImage)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	imgs := make([][]byte, len(keys))
+	for i, cmd := range cmds {
+		encoded, err := cmd.Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if imgs[i], err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("%s: %w", keys[i], err)
+		}
+	}
+
+	return imgs, nil
+}
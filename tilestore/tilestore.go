@@ -0,0 +1,444 @@
+// Package tilestore is the redis layout of the tile sets shared by the
+// downloader, which fills them, and the mosaic service, which searches them.
+//
+// A tile set holds the tiles downloaded for a client. Every tile is a hash
+// under the set's prefix holding the average color vector searched by the
+// set's index, next to a blob key holding the encoded image so searches never
+// transfer images. Tiles may be synthetic variants of another tile of the set,
+// their parent, which searches can leave out. The schema version of a set is
+// kept in its meta hash and upgraded in place by Migrate.
+package tilestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SchemaVersion is the version new tile sets are created at and the one
+// Migrate upgrades existing sets to.
+const SchemaVersion = 6
+
+// Fields of the tile hashes. FieldImage only exists in sets that predate
+// blobs, FieldScore is not stored but returned by searches. FieldKind is
+// indexed as a tag, FieldParent and FieldTransform are only set on variants.
+// FieldHash is missing from the tiles stored before archives.
+const (
+	FieldImage     = "img"
+	FieldVector    = "average_color"
+	FieldSource    = "source"
+	FieldAuthor    = "author"
+	FieldKind      = "kind"
+	FieldParent    = "parent"
+	FieldTransform = "transform"
+	FieldHash      = "sha256"
+	FieldScore     = "__" + FieldVector + "_score"
+)
+
+// Kinds of tiles: the tiles downloaded, or imported, and the variants
+// generated from them.
+const (
+	KindTile    = "tile"
+	KindVariant = "variant"
+)
+
+var (
+	ErrNoTileSet      = errors.New("tile set does not exist")
+	ErrUnknownSchema  = errors.New("unknown tile set schema version")
+	ErrOutdatedSchema = errors.New("tile set schema is outdated, migrate it")
+	ErrNoTile         = errors.New("tile does not exist")
+	ErrInvalidName    = errors.New("invalid tile set name")
+)
+
+// Schema is the layout of a tile set at a version. Vector is the vector type
+// of the sets created without choosing one, Blobs tells whether images are
+// kept in blob keys rather than base64 encoded in the tile hashes, Linear
+// whether the descriptors are averaged in linear light, see Descriptor,
+// Variants whether tiles record their kind, in a tag of the index, and
+// Separated whether the index prefix ends with the key separator.
+type Schema struct {
+	Version   int
+	Vector    VectorType
+	Blobs     bool
+	Linear    bool
+	Variants  bool
+	Separated bool
+}
+
+// schemas lists every layout tile sets were stored with. Version 1 sets
+// predate the meta hash and store FLOAT64 vectors.
+var schemas = map[int]Schema{
+	1: {Version: 1, Vector: Float64},
+	2: {Version: 2, Vector: Float32},
+	3: {Version: 3, Vector: Float32, Blobs: true},
+	4: {Version: 4, Vector: Float32, Blobs: true, Linear: true},
+	5: {Version: 5, Vector: Float32, Blobs: true, Linear: true, Variants: true},
+	6: {Version: 6, Vector: Float32, Blobs: true, Linear: true, Variants: true, Separated: true},
+}
+
+// SchemaAt returns the layout of the given version.
+func SchemaAt(version int) (Schema, error) {
+	s, ok := schemas[version]
+	if !ok {
+		return Schema{}, fmt.Errorf("%w: %d", ErrUnknownSchema, version)
+	}
+	return s, nil
+}
+
+// Set is a tile set. Its index is named after the set and covers the hashes
+// under Prefix. ExcludeVariants leaves the variants out of the searches of
+// sets that record kinds.
+type Set struct {
+	Name   string
+	Prefix string
+	Schema Schema
+	Index  Index
+	Client *redis.Client
+
+	ExcludeVariants bool
+}
+
+// Prefix is the key prefix of the tiles of the set named name.
+func Prefix(name string) string {
+	return fmt.Sprintf("img:%s", name)
+}
+
+// ValidateName reports names that are empty or hold a key separator or a
+// character special to the key patterns of redis as ErrInvalidName. Such
+// names would make the keys of one set match the pattern of another.
+func ValidateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: empty name", ErrInvalidName)
+	}
+	if i := strings.IndexAny(name, `*?[]\:`); i >= 0 {
+		return fmt.Errorf("%w: %q holds %q", ErrInvalidName, name, name[i])
+	}
+	return nil
+}
+
+// New returns the set named name at the current schema and the default
+// index without reading redis, the way sets about to be created are opened.
+// Index may be changed before EnsureIndex creates the set.
+func New(c *redis.Client, name string) *Set {
+	return &Set{
+		Name:   name,
+		Prefix: Prefix(name),
+		Schema: schemas[SchemaVersion],
+		Index:  DefaultIndex(),
+		Client: c,
+	}
+}
+
+// Open returns the set named name at the schema and with the index it is
+// stored with. Sets holding neither tiles nor an index are reported as
+// ErrNoTileSet, invalid names as ErrInvalidName.
+func Open(ctx context.Context, c *redis.Client, name string) (*Set, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	s := New(c, name)
+
+	version, err := s.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		return nil, ErrNoTileSet
+	}
+
+	if s.Schema, err = SchemaAt(version); err != nil {
+		return nil, err
+	}
+	if s.Index, err = s.readIndex(ctx); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Key is the key of the tile numbered id.
+func (s *Set) Key(id int64) string {
+	return fmt.Sprintf("%s:%d", s.Prefix, id)
+}
+
+// Pattern matches the keys of every tile of the set.
+func (s *Set) Pattern() string {
+	return s.Prefix + ":*"
+}
+
+// indexPrefix is the key prefix the index of the set covers. Before the
+// index prefixes ended with the separator, the index of the set 10.0.0.1 also
+// covered the tiles of 10.0.0.12.
+func (s *Set) indexPrefix() string {
+	if !s.Schema.Separated {
+		return s.Prefix
+	}
+	return s.Prefix + ":"
+}
+
+// metaKey is kept outside of Prefix so the index never covers it.
+func (s *Set) metaKey() string {
+	return fmt.Sprintf("tilestore:%s", s.Name)
+}
+
+func (s *Set) counterKey() string {
+	return fmt.Sprintf("%s:counter", s.Name)
+}
+
+// Version reads the schema version of the set. Sets created before versions
+// were recorded report 1, sets that do not exist 0.
+func (s *Set) Version(ctx context.Context) (int, error) {
+	v, err := s.Client.HGet(ctx, s.metaKey(), "version").Result()
+	if err == nil {
+		return strconv.Atoi(v)
+	}
+	if !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+
+	exists, err := s.exists(ctx)
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	return 1, nil
+}
+
+// Revision counts the tiles ever added to the set. Every Add changes it, so
+// copies of the set taken at the same schema version and revision hold the
+// same tiles.
+func (s *Set) Revision(ctx context.Context) (int64, error) {
+	n, err := s.Client.Get(ctx, s.counterKey()).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+
+	return n, err
+}
+
+// exists reports whether the set has an index or at least one tile.
+func (s *Set) exists(ctx context.Context) (bool, error) {
+	indexes, err := s.Client.Do(ctx, "FT._LIST").StringSlice()
+	if err != nil {
+		return false, err
+	}
+	for _, index := range indexes {
+		if index == s.Name {
+			return true, nil
+		}
+	}
+
+	keys, _, err := s.Client.Scan(ctx, 0, s.Pattern(), 1).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return len(keys) > 0, nil
+}
+
+// EnsureIndex creates the index of the set at the current schema and records
+// the version and index in the meta hash, unless the set exists already.
+// Existing sets keep the index they were created with, it is recreated when
+// missing and reported as ErrIndexMismatch when it differs from the recorded
+// one. Existing sets of an older schema are reported as ErrOutdatedSchema.
+// Calling EnsureIndex again is harmless.
+func (s *Set) EnsureIndex(ctx context.Context) error {
+	if err := ValidateName(s.Name); err != nil {
+		return err
+	}
+	if err := s.Index.Validate(); err != nil {
+		return err
+	}
+
+	version, err := s.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case version > SchemaVersion:
+		return fmt.Errorf("%w: %d", ErrUnknownSchema, version)
+	case version > 0 && version < SchemaVersion:
+		return fmt.Errorf("%w: version %d", ErrOutdatedSchema, version)
+	case version == SchemaVersion:
+		s.Schema = schemas[SchemaVersion]
+		if s.Index, err = s.readIndex(ctx); err != nil {
+			return err
+		}
+
+		_, err = s.CheckIndex(ctx)
+		if !errors.Is(err, ErrNoIndex) {
+			return err
+		}
+		return s.createIndex(ctx)
+	}
+
+	s.Schema = schemas[SchemaVersion]
+	if err = s.createIndex(ctx); err != nil {
+		return err
+	}
+
+	return s.saveMeta(ctx)
+}
+
+// createIndex creates the index of the set. An index created concurrently
+// is checked instead.
+func (s *Set) createIndex(ctx context.Context) error {
+	args := []interface{}{
+		"FT.CREATE", s.Name,
+		"ON", "HASH",
+		"PREFIX", "1", s.indexPrefix(),
+		"SCHEMA",
+	}
+
+	args = append(args, s.Index.args()...)
+	if s.Schema.Variants {
+		args = append(args, FieldKind, "TAG")
+	}
+
+	err := s.Client.Do(ctx, args...).Err()
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "index already exists") {
+		_, err = s.CheckIndex(ctx)
+	}
+
+	return err
+}
+
+// saveMeta records the schema version and the index of the set.
+func (s *Set) saveMeta(ctx context.Context) error {
+	meta := s.Index.meta()
+	meta["version"] = s.Schema.Version
+
+	return s.Client.HSet(ctx, s.metaKey(), meta).Err()
+}
+
+// BlobKey is the key of the image of the tile stored under key. Blob keys
+// are kept outside of Prefix so the index never covers them.
+func BlobKey(key string) string {
+	return "blob:" + key
+}
+
+// VariantsKey is the key of the set of the keys of the variants of the tile
+// stored under key, kept outside of Prefix like blob keys.
+func VariantsKey(key string) string {
+	return "variants:" + key
+}
+
+// Tile is a tile as stored in a set. Image is the encoded image, as
+// downloaded, and Vector the average color the tile is searched by. Variants
+// have the key of their Parent and the name of the Transform that made them
+// from it. Hash is the hex SHA-256 of the image, computed by Add.
+type Tile struct {
+	Image     []byte
+	Vector    [3]float64
+	Source    string
+	Author    string
+	Parent    string
+	Transform string
+	Hash      string
+}
+
+// Hash is the hex SHA-256 tiles are identified by in archives.
+func Hash(img []byte) string {
+	sum := sha256.Sum256(img)
+	return hex.EncodeToString(sum[:])
+}
+
+// Kind is KindVariant for variants and KindTile for the other tiles.
+func (t Tile) Kind() string {
+	if t.Parent != "" {
+		return KindVariant
+	}
+	return KindTile
+}
+
+// Add stores t under a new key of the set and returns the key. Variants are
+// linked to their parent under VariantsKey. Sets must be written to at the
+// current schema.
+func (s *Set) Add(ctx context.Context, t Tile) (string, error) {
+	if s.Schema.Version != SchemaVersion {
+		return "", fmt.Errorf("%w: version %d", ErrOutdatedSchema, s.Schema.Version)
+	}
+
+	id, err := s.Client.Incr(ctx, s.counterKey()).Result()
+	if err != nil {
+		return "", err
+	}
+
+	fields := map[string]interface{}{
+		FieldVector: EncodeVector(t.Vector, s.Index.Vector),
+		FieldKind:   t.Kind(),
+		FieldHash:   Hash(t.Image),
+	}
+	if t.Source != "" {
+		fields[FieldSource] = t.Source
+	}
+	if t.Author != "" {
+		fields[FieldAuthor] = t.Author
+	}
+	if t.Parent != "" {
+		fields[FieldParent] = t.Parent
+		fields[FieldTransform] = t.Transform
+	}
+
+	// written in one transaction so every indexed tile has its image
+	key := s.Key(id)
+	_, err = s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, BlobKey(key), t.Image, 0)
+		pipe.HSet(ctx, key, fields)
+		if t.Parent != "" {
+			pipe.SAdd(ctx, VariantsKey(t.Parent), key)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// Variants returns the keys of the variants of the tile stored under key.
+func (s *Set) Variants(ctx context.Context, key string) ([]string, error) {
+	return s.Client.SMembers(ctx, VariantsKey(key)).Result()
+}
+
+// Delete drops the index of the set and deletes its tiles, their images and
+// variant links, and its meta, at most batchSize tiles per pipeline, and
+// returns the number of tiles deleted. Deleting a set that does not exist
+// deletes nothing. The counter is kept, so a set created again under the same
+// name reuses neither the keys nor the revisions of the deleted one, which
+// the caches of tiles and indexes are keyed by.
+func (s *Set) Delete(ctx context.Context, batchSize int) (int, error) {
+	if err := ValidateName(s.Name); err != nil {
+		return 0, err
+	}
+	if err := s.DropIndex(ctx); err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	err := s.scanBatches(ctx, batchSize, func(keys []string) error {
+		pipe := s.Client.Pipeline()
+		for _, key := range keys {
+			pipe.Del(ctx, key, BlobKey(key), VariantsKey(key))
+		}
+
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+		deleted += len(keys)
+		return nil
+	})
+	if err != nil {
+		return deleted, err
+	}
+
+	return deleted, s.Client.Del(ctx, s.metaKey()).Err()
+}
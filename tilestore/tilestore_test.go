@@ -0,0 +1,420 @@
+package tilestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisTestURL = "redis://localhost:6378"
+
+func Test_VectorRoundTrip(t *testing.T) {
+	v := [3]float64{148.91, 153.07, 147.55}
+
+	var tt = []struct {
+		vectorType VectorType
+		length     int
+	}{
+		{Float32, 12},
+		{Float64, 24},
+	}
+
+	for _, tc := range tt {
+		t.Run(string(tc.vectorType), func(t *testing.T) {
+			b := EncodeVector(v, tc.vectorType)
+			if len(b) != tc.length {
+				t.Fatalf("expected %d bytes, got %d", tc.length, len(b))
+			}
+
+			decoded, err := DecodeVector(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range v {
+				if d := decoded[i] - v[i]; d > 1e-4 || d < -1e-4 {
+					t.Errorf("expected %v, got %v", v, decoded)
+				}
+			}
+		})
+	}
+
+	if _, err := DecodeVector(make([]byte, 16)); err != ErrInvalidVector {
+		t.Errorf("expected %v, got %v", ErrInvalidVector, err)
+	}
+}
+
+func Test_SearchArgs(t *testing.T) {
+	s := New(nil, "0.0.0.0")
+	vec := EncodeVector([3]float64{1, 2, 3}, Float32)
+
+	var tt = []struct {
+		name     string
+		fields   []string
+		expected []interface{}
+	}{
+		{"fields", []string{FieldImage, FieldVector}, []interface{}{
+			"FT.SEARCH", "0.0.0.0", "(*)=>[KNN 5 @average_color $vec]",
+			"PARAMS", "2", "vec", vec,
+			"SORTBY", "__average_color_score",
+			"RETURN", "2", "img", "average_color",
+			"DIALECT", "2",
+		}},
+		{"nocontent", nil, []interface{}{
+			"FT.SEARCH", "0.0.0.0", "(*)=>[KNN 5 @average_color $vec]",
+			"PARAMS", "2", "vec", vec,
+			"SORTBY", "__average_color_score",
+			"NOCONTENT",
+			"DIALECT", "2",
+		}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			args := s.SearchArgs([3]float64{1, 2, 3}, 5, tc.fields...)
+			if !reflect.DeepEqual(tc.expected, args) {
+				t.Errorf("expected %v, got %v", tc.expected, args)
+			}
+		})
+	}
+
+	s.ExcludeVariants = true
+	if query := s.SearchArgs([3]float64{1, 2, 3}, 5)[2]; query != "(@kind:{tile})=>[KNN 5 @average_color $vec]" {
+		t.Errorf("expected variants to be filtered out, got %v", query)
+	}
+
+	// sets that predate kinds have no variants to leave out
+	s.Schema = schemas[4]
+	if query := s.SearchArgs([3]float64{1, 2, 3}, 5)[2]; query != "(*)=>[KNN 5 @average_color $vec]" {
+		t.Errorf("expected no filter, got %v", query)
+	}
+}
+
+func Test_SchemaAt(t *testing.T) {
+	if s, err := SchemaAt(SchemaVersion); err != nil || s.Version != SchemaVersion {
+		t.Errorf("expected the current schema, got %+v, %v", s, err)
+	}
+	if _, err := SchemaAt(SchemaVersion + 1); !errors.Is(err, ErrUnknownSchema) {
+		t.Errorf("expected %v, got %v", ErrUnknownSchema, err)
+	}
+}
+
+func Test_ValidateName(t *testing.T) {
+	var tt = []struct {
+		name  string
+		valid bool
+	}{
+		{"172.18.0.1", true},
+		{"landscapes", true},
+		{"", false},
+		{"a*", false},
+		{"a?", false},
+		{"[a]", false},
+		{`a\b`, false},
+		{"::1", false},
+		{"a:b", false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateName(tc.name)
+			if tc.valid && err != nil {
+				t.Errorf("expected a valid name, got %v", err)
+			}
+			if !tc.valid && !errors.Is(err, ErrInvalidName) {
+				t.Errorf("expected %v, got %v", ErrInvalidName, err)
+			}
+		})
+	}
+
+	// names are checked before redis is reached
+	if _, err := Open(context.Background(), nil, "a:b"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Open: expected %v, got %v", ErrInvalidName, err)
+	}
+	if err := New(nil, "*").EnsureIndex(context.Background()); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("EnsureIndex: expected %v, got %v", ErrInvalidName, err)
+	}
+	if _, err := New(nil, "").Delete(context.Background(), 10); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Delete: expected %v, got %v", ErrInvalidName, err)
+	}
+	if _, err := Import(context.Background(), nil, "a?", nil, 10); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Import: expected %v, got %v", ErrInvalidName, err)
+	}
+}
+
+// Test_Add needs redis stack on localhost:6378.
+func Test_Add(t *testing.T) {
+	c := redisTestClient(t)
+	ctx := context.Background()
+
+	s := New(c, "tilestore-add-test")
+	dropSet(t, s)
+
+	if err := s.EnsureIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	tiles := []Tile{
+		{Image: []byte{0xff, 0xd8, 0x01}, Vector: [3]float64{10, 20, 30}, Source: "https://picsum.photos/id/1", Author: "Alejandro Escamilla"},
+		{Image: []byte{0xff, 0xd8, 0x02}, Vector: [3]float64{200, 100, 50}},
+		{Image: []byte{0xff, 0xd8, 0x03}, Vector: [3]float64{20, 30, 10}, Parent: s.Key(1), Transform: "hue120"},
+	}
+	keys := make([]string, 0, len(tiles)+1)
+	for _, tile := range tiles {
+		key, err := s.Add(ctx, tile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	keys = append(keys, s.Key(100))
+
+	records, err := s.Records(ctx, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgs, err := s.Images(ctx, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tile := range tiles {
+		if records[i] == nil {
+			t.Fatalf("expected a record for %s", keys[i])
+		}
+		if records[i].Vector != tile.Vector || records[i].Source != tile.Source || records[i].Author != tile.Author ||
+			records[i].Parent != tile.Parent || records[i].Transform != tile.Transform {
+			t.Errorf("expected %+v, got %+v", tile, *records[i])
+		}
+		if records[i].Image != nil {
+			t.Errorf("expected records without images")
+		}
+		if !bytes.Equal(tile.Image, imgs[i]) {
+			t.Errorf("expected image %v, got %v", tile.Image, imgs[i])
+		}
+	}
+
+	if records[3] != nil || imgs[3] != nil {
+		t.Errorf("expected nothing for a missing tile, got %v, %v", records[3], imgs[3])
+	}
+	if _, err = s.Image(ctx, keys[3]); !errors.Is(err, ErrNoTile) {
+		t.Errorf("expected %v, got %v", ErrNoTile, err)
+	}
+
+	variants, err := s.Variants(ctx, keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(variants, []string{keys[2]}) {
+		t.Errorf("expected the variant %s, got %v", keys[2], variants)
+	}
+
+	for i, kind := range []string{KindTile, KindTile, KindVariant} {
+		if actual, _ := c.HGet(ctx, keys[i], FieldKind).Result(); actual != kind {
+			t.Errorf("expected %s to be a %s, got %q", keys[i], kind, actual)
+		}
+	}
+
+	if revision, err := s.Revision(ctx); err != nil || revision != int64(len(tiles)) {
+		t.Errorf("expected revision %d, got %d, %v", len(tiles), revision, err)
+	}
+}
+
+// Test_Delete needs redis stack on localhost:6378.
+func Test_Delete(t *testing.T) {
+	c := redisTestClient(t)
+	ctx := context.Background()
+
+	s := New(c, "tilestore-delete-test")
+	dropSet(t, s)
+
+	if err := s.EnsureIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	parent, err := s.Add(ctx, Tile{Image: []byte{0xff, 0xd8, 0x01}, Vector: [3]float64{10, 20, 30}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Add(ctx, Tile{Image: []byte{0xff, 0xd8, 0x02}, Vector: [3]float64{20, 30, 10}, Parent: parent, Transform: "hue120"}); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := s.Delete(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected 2 tiles deleted, got %d", deleted)
+	}
+
+	for _, pattern := range []string{s.Pattern(), BlobKey(s.Pattern()), VariantsKey(s.Pattern()), s.metaKey()} {
+		if keys, _ := c.Keys(ctx, pattern).Result(); len(keys) != 0 {
+			t.Errorf("expected nothing left under %s, got %v", pattern, keys)
+		}
+	}
+	if _, err = Open(ctx, c, s.Name); !errors.Is(err, ErrNoTileSet) {
+		t.Errorf("expected %v, got %v", ErrNoTileSet, err)
+	}
+
+	if deleted, err = s.Delete(ctx, 1); err != nil || deleted != 0 {
+		t.Errorf("expected deleting again to delete nothing, got %d, %v", deleted, err)
+	}
+
+	// a set created again continues the keys and revisions of the deleted one
+	if err = s.EnsureIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	key, err := s.Add(ctx, Tile{Image: []byte{0xff, 0xd8, 0x03}, Vector: [3]float64{30, 10, 20}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != s.Key(3) {
+		t.Errorf("expected %s, got %s", s.Key(3), key)
+	}
+	if revision, err := s.Revision(ctx); err != nil || revision != 3 {
+		t.Errorf("expected revision 3, got %d, %v", revision, err)
+	}
+}
+
+// Test_Migrate needs redis stack on localhost:6378.
+func Test_Migrate(t *testing.T) {
+	c := redisTestClient(t)
+	ctx := context.Background()
+
+	name := "tilestore-migrate-test"
+	s := New(c, name)
+	dropSet(t, s)
+
+	// a version 1 set, FLOAT64 vectors averaged from sRGB values, base64
+	// images and no meta hash
+	s.Schema = schemas[1]
+	s.Index.Vector = Float64
+	if err := s.createIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	imgs := [][]byte{checkerPNG(t, color.White, color.Black), checkerPNG(t, color.NRGBA{R: 200, G: 100, B: 50, A: 255}, color.Black)}
+	vectors := make([][3]float64, len(imgs))
+	for i, img := range imgs {
+		c.HSet(ctx, s.Key(int64(i+1)), FieldImage, base64.StdEncoding.EncodeToString(img), FieldVector, EncodeVector([3]float64{127.5, 127.5, 127.5}, Float64))
+
+		v, err := DecodeDescriptor(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vectors[i], _ = DecodeVector(EncodeVector(v, Float32))
+	}
+
+	if err := New(c, name).EnsureIndex(ctx); !errors.Is(err, ErrOutdatedSchema) {
+		t.Fatalf("expected %v, got %v", ErrOutdatedSchema, err)
+	}
+
+	from, err := Migrate(ctx, c, name, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 1 {
+		t.Errorf("expected to migrate from version 1, got %d", from)
+	}
+
+	migrated, err := Open(ctx, c, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated.Schema.Version != SchemaVersion {
+		t.Errorf("expected version %d, got %d", SchemaVersion, migrated.Schema.Version)
+	}
+	if migrated.Index.Vector != Float32 {
+		t.Errorf("expected %s vectors, got %s", Float32, migrated.Index.Vector)
+	}
+	if info, err := migrated.CheckIndex(ctx); err != nil || info.Prefixes[0] != migrated.Prefix+":" {
+		t.Errorf("expected the index to cover %s:, got %v", migrated.Prefix, err)
+	}
+
+	for i, v := range vectors {
+		b, err := c.HGet(ctx, s.Key(int64(i+1)), FieldVector).Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) != 12 {
+			t.Errorf("expected a FLOAT32 vector, got %d bytes", len(b))
+		}
+		if decoded, _ := DecodeVector(b); decoded != v {
+			t.Errorf("expected the descriptor recomputed in linear light %v, got %v", v, decoded)
+		}
+
+		if kind, _ := c.HGet(ctx, s.Key(int64(i+1)), FieldKind).Result(); kind != KindTile {
+			t.Errorf("expected the kind of a downloaded tile, got %q", kind)
+		}
+		if exists, _ := c.HExists(ctx, s.Key(int64(i+1)), FieldImage).Result(); exists {
+			t.Errorf("expected the image to be moved out of the tile hash")
+		}
+		stored, err := migrated.Image(ctx, s.Key(int64(i+1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(imgs[i], stored) {
+			t.Errorf("expected image %v, got %v", imgs[i], stored)
+		}
+	}
+
+	if from, err = Migrate(ctx, c, name, 1); err != nil || from != SchemaVersion {
+		t.Errorf("expected migrating again to do nothing, got %d, %v", from, err)
+	}
+}
+
+// dropSet removes the index, tiles and meta of s now and once the test is
+// done.
+func dropSet(t testing.TB, s *Set) {
+	ctx := context.Background()
+	drop := func() {
+		_ = s.DropIndex(ctx)
+		keys, _ := s.Client.Keys(ctx, s.Pattern()).Result()
+		blobs, _ := s.Client.Keys(ctx, BlobKey(s.Pattern())).Result()
+		variants, _ := s.Client.Keys(ctx, VariantsKey(s.Pattern())).Result()
+		keys = append(append(keys, blobs...), variants...)
+		s.Client.Del(ctx, append(keys, s.metaKey(), s.counterKey())...)
+	}
+
+	drop()
+	t.Cleanup(drop)
+}
+
+func redisTestClient(t testing.TB) *redis.Client {
+	opt, err := redis.ParseURL(redisTestURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := redis.NewClient(opt)
+	t.Cleanup(func() { c.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = c.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis unavailable: %v", err)
+	}
+
+	return c
+}
+
+// checkerPNG encodes a 2x2 PNG checkered with colors a and b.
+func checkerPNG(t *testing.T, a, b color.Color) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, a)
+	img.Set(1, 1, a)
+	img.Set(1, 0, b)
+	img.Set(0, 1, b)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,63 @@
+package tilestore
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var ErrInvalidVector = errors.New("invalid vector length")
+
+// VectorType is the element type of the vectors of a set, as named by
+// FT.CREATE.
+type VectorType string
+
+const (
+	Float32 VectorType = "FLOAT32"
+	Float64 VectorType = "FLOAT64"
+)
+
+// size is the number of bytes of an element.
+func (t VectorType) size() int {
+	if t == Float32 {
+		return 4
+	}
+	return 8
+}
+
+// EncodeVector lays v out as little endian elements of type t, the format
+// vector fields and KNN query parameters are stored in.
+func EncodeVector(v [3]float64, t VectorType) []byte {
+	size := t.size()
+	b := make([]byte, size*len(v))
+	for i, f := range v {
+		if t == Float32 {
+			binary.LittleEndian.PutUint32(b[i*size:], math.Float32bits(float32(f)))
+		} else {
+			binary.LittleEndian.PutUint64(b[i*size:], math.Float64bits(f))
+		}
+	}
+
+	return b
+}
+
+// DecodeVector reads a vector encoded by EncodeVector, the element type is
+// told apart by the length of b.
+func DecodeVector(b []byte) ([3]float64, error) {
+	var v [3]float64
+
+	switch len(b) {
+	case Float32.size() * len(v):
+		for i := range v {
+			v[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:])))
+		}
+	case Float64.size() * len(v):
+		for i := range v {
+			v[i] = math.Float64frombits(binary.LittleEndian.Uint64(b[i*8:]))
+		}
+	default:
+		return v, ErrInvalidVector
+	}
+
+	return v, nil
+}